@@ -0,0 +1,67 @@
+package calc
+
+import "math"
+
+// degreeSign is written after a number for an angle in degrees, as in 30°.
+const degreeSign = "°"
+
+// fullTurns are the sizes of a full turn in each unit of angle.
+var fullTurns = map[string]float64{"rad": 2 * math.Pi, "deg": 360, "grad": 400, "turn": 1}
+
+// angleFunctions are the builtins that give the size of an angle in the unit
+// of the same name, as deg(30 deg + 0.5 rad) does in degrees.
+var angleFunctions = map[string]bool{"deg": true, "rad": true, "grad": true}
+
+// angleUnit returns the unit of the angles of plain numbers, radians unless
+// AngleUnit names another.
+func (c *Calculator) angleUnit() string {
+	if _, ok := fullTurns[c.AngleUnit]; !ok {
+		return "rad"
+	}
+	return c.AngleUnit
+}
+
+// convertAngle converts an angle of x in the unit from to the unit to,
+// through the size of a full turn in both, so that 100 grad is exactly 90 deg.
+func convertAngle(x float64, from, to string) float64 {
+	if from == to {
+		return x
+	}
+	return x / fullTurns[from] * fullTurns[to]
+}
+
+// angleIn returns the size of the angle q in unit.
+func angleIn(q Quantity, unit string) float64 {
+	if len(q.units) == 1 && q.units[0].power == 1 {
+		if from := q.units[0].symbol; fullTurns[from] != 0 {
+			return convertAngle(q.amount, from, unit)
+		}
+	}
+	return q.amount * q.factor() / measures[unit].factor
+}
+
+// callWithAngle calls a builtin on an angle, such as sin(30 deg), which takes
+// it in radians whatever the angle mode, and deg, rad, and grad, which give
+// its size in their units. It reports false for any other call, which then
+// takes plain numbers.
+func (c *Calculator) callWithAngle(name string, values []Value) (Value, bool, error) {
+	if len(values) != 1 {
+		return nil, false, nil
+	}
+	q, ok := values[0].(Quantity)
+	if !ok || q.dimension() != angle {
+		return nil, false, nil
+	}
+	if angleFunctions[name] {
+		return Float(angleIn(q, name)), true, nil
+	}
+	function, ok := builtins[name]
+	if !ok || !function.angleArg {
+		return nil, false, nil
+	}
+	if err := function.checkArity(name, 1); err != nil {
+		return nil, true, err
+	}
+	result, err := function.call([]float64{angleIn(q, "rad")})
+	return Float(result), true, err
+}
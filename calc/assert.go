@@ -0,0 +1,39 @@
+package calc
+
+import (
+	"regexp"
+	"strings"
+)
+
+var assertRegex = regexp.MustCompile(`^assert\((.+?)(?:,\s*"([^"]*)")?\)$`)
+
+// AssertionError is the error of an assert(condition, "message") whose
+// condition does not hold.
+type AssertionError struct {
+	Message string
+}
+
+func (e *AssertionError) Error() string {
+	return "assertion failed: " + e.Message
+}
+
+// evaluateAssertion handles assert(condition) and assert(condition, "message"),
+// which fail with an AssertionError when the condition does not hold.
+func (c *Calculator) evaluateAssertion(input string) (string, bool, error) {
+	match := assertRegex.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return "", false, nil
+	}
+	holds, err := c.holds(match[1])
+	if err != nil {
+		return "", true, err
+	}
+	if !holds {
+		message := match[2]
+		if message == "" {
+			message = strings.TrimSpace(match[1])
+		}
+		return "", true, &AssertionError{Message: message}
+	}
+	return "passed", true, nil
+}
@@ -0,0 +1,122 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// hexExponentRegex matches the exponent of a hexadecimal float as strconv
+// writes it, with a leading zero to drop, as in p-04.
+var hexExponentRegex = regexp.MustCompile(`p([+-])0*(\d)`)
+
+// basePrefixes are the prefixes of integers written in the bases other than
+// decimal, as in 0xFF.
+var basePrefixes = map[int]string{2: "0b", 8: "0o", 16: "0x"}
+
+// ParseBase returns the base with the given name: hex, oct, bin, or dec, or
+// the number 16, 8, 2, or 10.
+func ParseBase(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "hex", "hexadecimal", "16":
+		return 16, true
+	case "oct", "octal", "8":
+		return 8, true
+	case "bin", "binary", "2":
+		return 2, true
+	case "dec", "decimal", "10":
+		return 10, true
+	}
+	return 0, false
+}
+
+// formatInBase writes a whole number in base 2, 8, or 16 with its prefix, as
+// in 0xFF or -0b101.
+func formatInBase(n *big.Int, base int) string {
+	sign := ""
+	if n.Sign() < 0 {
+		sign = "-"
+	}
+	return sign + basePrefixes[base] + strings.ToUpper(new(big.Int).Abs(n).Text(base))
+}
+
+// formatFloatInBase writes a number that is not whole as a float in base 16
+// or 2, with the digits of its mantissa and a power of two, as in
+// 0x1.999999999999ap-4 or 0b1.1p+1. It reports false for base 8, which has
+// no such form, and for infinities and NaN.
+func formatFloatInBase(f float64, base int) (string, bool) {
+	if math.IsInf(f, 0) || math.IsNaN(f) || (base != 16 && base != 2) {
+		return "", false
+	}
+	hex := hexExponentRegex.ReplaceAllString(strconv.FormatFloat(f, 'x', -1, 64), "p$1$2")
+	if base == 16 {
+		return hex, true
+	}
+	sign := ""
+	if f < 0 {
+		sign, f = "-", -f
+	}
+	fraction, exponent := math.Frexp(f)
+	mantissa := uint64(math.Ldexp(fraction, 53)) &^ (1 << 52)
+	digits := strings.TrimRight(fmt.Sprintf("%052b", mantissa), "0")
+	if digits != "" {
+		digits = "." + digits
+	}
+	return fmt.Sprintf("%s0b1%sp%+d", sign, digits, exponent-1), true
+}
+
+// wholeNumber returns the value of a number that is a whole number, reporting
+// false for other values, including truth values.
+func wholeNumber(v Value) (*big.Int, bool) {
+	switch v := v.(type) {
+	case Int:
+		return v.Int, true
+	case Bool:
+		return nil, false
+	}
+	f, ok := Real(v)
+	if !ok || math.IsInf(f, 0) || f != math.Trunc(f) {
+		return nil, false
+	}
+	n, _ := big.NewFloat(f).Int(nil)
+	return n, true
+}
+
+// formatNumberInBase writes a real number in base 2, 8, or 16, reporting
+// false for other values and for the numbers that cannot be written there.
+func formatNumberInBase(v Value, base int) (string, bool) {
+	if n, ok := wholeNumber(v); ok {
+		return formatInBase(n, base), true
+	}
+	if _, ok := v.(Bool); ok {
+		return "", false
+	}
+	if f, ok := Real(v); ok {
+		return formatFloatInBase(f, base)
+	}
+	return "", false
+}
+
+// formatBase returns the hex(x), oct(x), or bin(x) of a helper call: the
+// number x written in that base, as a float for hex and bin when it is not
+// whole.
+func (c *Calculator) formatBase(name string, args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("%s expects 1 argument, e.g. %s(255)", name, name)
+	}
+	value, err := c.EvaluateValue(args[0])
+	if err != nil {
+		return "", err
+	}
+	base, _ := ParseBase(name)
+	if output, ok := formatNumberInBase(value, base); ok {
+		return output, nil
+	}
+	if _, whole := wholeNumber(value); base == 8 && !whole {
+		return "", fmt.Errorf("%s takes a whole number, not %s", name, c.FormatValue(value))
+	}
+	return "", fmt.Errorf("%s takes a finite number, not %s", name, c.FormatValue(value))
+}
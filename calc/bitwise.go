@@ -0,0 +1,68 @@
+package calc
+
+import (
+	"fmt"
+	"math/big"
+)
+
+// bitwiseOperators are the operators that work on the bits of 64-bit
+// integers.
+var bitwiseOperators = map[string]bool{bitAndOperator: true, bitOrOperator: true, xorOperator: true, shiftLeftOperator: true, shiftRightOperator: true, bitNotOperator: true}
+
+// bits returns a whole number as the 64 bits of a two's complement integer.
+// Numbers from 2^63 up to 2^64 - 1 are read as unsigned, so 0xFFFFFFFFFFFFFFFF
+// has the same bits as -1.
+func (c *Calculator) bits(operator string, v Value) (int64, error) {
+	n, ok := wholeNumber(v)
+	if !ok {
+		return 0, fmt.Errorf("%s takes whole numbers, not %s", displayToken(operator), c.FormatValue(v))
+	}
+	switch {
+	case n.IsInt64():
+		return n.Int64(), nil
+	case n.IsUint64():
+		return int64(n.Uint64()), nil
+	}
+	return 0, fmt.Errorf("%s takes 64-bit integers, and %s does not fit in 64 bits", displayToken(operator), c.FormatValue(v))
+}
+
+// bitwiseBinary applies &, |, xor, <<, or >> to two whole numbers. The
+// result is a signed 64-bit integer, and >> keeps the sign.
+func (c *Calculator) bitwiseBinary(operator string, a, b Value) (Value, error) {
+	x, err := c.bits(operator, a)
+	if err != nil {
+		return nil, err
+	}
+	y, err := c.bits(operator, b)
+	if err != nil {
+		return nil, err
+	}
+	var result int64
+	switch operator {
+	case bitAndOperator:
+		result = x & y
+	case bitOrOperator:
+		result = x | y
+	case xorOperator:
+		result = x ^ y
+	case shiftLeftOperator, shiftRightOperator:
+		if y < 0 || y > 63 {
+			return nil, fmt.Errorf("%w: %s shifts by 0 to 63 bits, not %d", ErrDomain, operator, y)
+		}
+		if operator == shiftLeftOperator {
+			result = x << uint(y)
+		} else {
+			result = x >> uint(y)
+		}
+	}
+	return Int{big.NewInt(result)}, nil
+}
+
+// bitwiseNot returns ~v, the whole number v with every bit flipped.
+func (c *Calculator) bitwiseNot(v Value) (Value, error) {
+	x, err := c.bits(bitNotOperator, v)
+	if err != nil {
+		return nil, err
+	}
+	return Int{big.NewInt(^x)}, nil
+}
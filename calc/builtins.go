@@ -0,0 +1,209 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+)
+
+// variadic is the maxArgs of a builtin that takes any number of arguments.
+const variadic = -1
+
+// builtin is a function of the calculator, such as sqrt(x) or atan2(y, x),
+// that takes from minArgs to maxArgs arguments. A function whose argument is
+// an angle has angleArg set, one whose result is an angle angleResult.
+type builtin struct {
+	minArgs, maxArgs      int
+	angleArg, angleResult bool
+	call                  func(args []float64) (float64, error)
+}
+
+// constants are the named numbers that expressions can use. A variable, let
+// binding, or parameter of the same name shadows them.
+var constants = map[string]float64{
+	"pi":  math.Pi,
+	"e":   math.E,
+	"tau": 2 * math.Pi,
+	"phi": math.Phi,
+}
+
+// builtins are the functions that expressions can call by name.
+var builtins = map[string]builtin{
+	"sin":   trigonometric(math.Sin),
+	"cos":   trigonometric(math.Cos),
+	"tan":   trigonometric(math.Tan),
+	"asin":  inverseTrigonometric(unary(math.Asin)),
+	"acos":  inverseTrigonometric(unary(math.Acos)),
+	"atan":  inverseTrigonometric(unary(math.Atan)),
+	"atan2": inverseTrigonometric(binary(math.Atan2)),
+	"deg":   unary(func(x float64) float64 { return x * 180 / math.Pi }),
+	"rad":   unary(func(x float64) float64 { return x * math.Pi / 180 }),
+	"grad":  unary(func(x float64) float64 { return x * 200 / math.Pi }),
+	"sinh":  unary(math.Sinh),
+	"cosh":  unary(math.Cosh),
+	"tanh":  unary(math.Tanh),
+	"sqrt":  unary(math.Sqrt),
+	"cbrt":  unary(math.Cbrt),
+	"root":  {minArgs: 2, maxArgs: 2, call: root},
+	"hypot": binary(math.Hypot),
+	"exp":   unary(math.Exp),
+	"ln":    unary(math.Log),
+	"log":   {minArgs: 1, maxArgs: 2, call: logarithm},
+	"log2":  unary(math.Log2),
+	"log10": unary(math.Log10),
+	"abs":   unary(math.Abs),
+	"floor": unary(math.Floor),
+	"ceil":  unary(math.Ceil),
+	"round": unary(math.Round),
+	"trunc": unary(math.Trunc),
+	"sign":  unary(sign),
+	"gamma": {minArgs: 1, maxArgs: 1, call: gamma},
+	"max":   {minArgs: 1, maxArgs: variadic, call: extreme(math.Max)},
+	"min":   {minArgs: 1, maxArgs: variadic, call: extreme(math.Min)},
+
+	"sum":      {minArgs: 1, maxArgs: variadic, call: sum},
+	"mean":     {minArgs: 1, maxArgs: variadic, call: mean},
+	"median":   {minArgs: 1, maxArgs: variadic, call: median},
+	"mode":     {minArgs: 1, maxArgs: variadic, call: mode},
+	"variance": {minArgs: 2, maxArgs: variadic, call: variance},
+	"stddev":   {minArgs: 2, maxArgs: variadic, call: stddev},
+}
+
+func unary(f func(float64) float64) builtin {
+	return builtin{minArgs: 1, maxArgs: 1, call: func(args []float64) (float64, error) {
+		return f(args[0]), nil
+	}}
+}
+
+func binary(f func(float64, float64) float64) builtin {
+	return builtin{minArgs: 2, maxArgs: 2, call: func(args []float64) (float64, error) {
+		return f(args[0], args[1]), nil
+	}}
+}
+
+func trigonometric(f func(float64) float64) builtin {
+	function := unary(f)
+	function.angleArg = true
+	return function
+}
+
+func inverseTrigonometric(function builtin) builtin {
+	function.angleResult = true
+	return function
+}
+
+// extreme returns the function that applies pick to all of its arguments, as
+// max and min do.
+func extreme(pick func(float64, float64) float64) func([]float64) (float64, error) {
+	return func(args []float64) (float64, error) {
+		result := args[0]
+		for _, arg := range args[1:] {
+			result = pick(result, arg)
+		}
+		return result, nil
+	}
+}
+
+func sign(x float64) float64 {
+	switch {
+	case x > 0:
+		return 1
+	case x < 0:
+		return -1
+	default:
+		return x
+	}
+}
+
+// logarithm is log(x), the base 10 logarithm, or log(x, base).
+func logarithm(args []float64) (float64, error) {
+	if len(args) == 1 {
+		return math.Log10(args[0]), nil
+	}
+	if base := args[1]; base <= 0 || base == 1 {
+		return 0, fmt.Errorf("%w: log(x, %g) needs a positive base other than 1", ErrDomain, base)
+	}
+	return math.Log(args[0]) / math.Log(args[1]), nil
+}
+
+// logBaseRegex matches the name of a logarithm with its base written after an
+// underscore, as in log_2(8) or log_e(x).
+var logBaseRegex = regexp.MustCompile(`^log_(\d+|e)$`)
+
+// logBase returns the base of a logarithm named as logBaseRegex matches.
+func logBase(name string) (float64, bool) {
+	match := logBaseRegex.FindStringSubmatch(name)
+	if match == nil {
+		return 0, false
+	}
+	if match[1] == "e" {
+		return math.E, true
+	}
+	base, err := strconv.ParseFloat(match[1], 64)
+	return base, err == nil
+}
+
+// root returns the nth root of x, real for odd n when x is negative, and
+// exact when it is a whole number.
+func root(args []float64) (float64, error) {
+	x, n := args[0], args[1]
+	if n == 0 {
+		return 0, fmt.Errorf("%w: root(x, 0) has no index", ErrDomain)
+	}
+	negative := x < 0
+	if negative {
+		if n != math.Trunc(n) || math.Mod(n, 2) == 0 {
+			return math.NaN(), nil
+		}
+		x = -x
+	}
+	r := math.Pow(x, 1/n)
+	if rounded := math.Round(r); math.Pow(rounded, n) == x {
+		r = rounded
+	}
+	if negative {
+		r = -r
+	}
+	return r, nil
+}
+
+func gamma(args []float64) (float64, error) {
+	if x := args[0]; x <= 0 && x == math.Trunc(x) {
+		return 0, fmt.Errorf("%w: gamma(%g) is undefined at zero and the negative integers", ErrDomain, x)
+	}
+	return math.Gamma(args[0]), nil
+}
+
+// callBuiltin calls a builtin function after checking the number of arguments,
+// converting angles from and to degrees in degree mode.
+// checkArity reports whether the builtin name can be called with count
+// arguments.
+func (function builtin) checkArity(name string, count int) error {
+	switch {
+	case function.maxArgs == variadic && count < function.minArgs:
+		return fmt.Errorf("%s expects at least %s", name, pluralize(function.minArgs, "argument"))
+	case function.maxArgs != variadic && (count < function.minArgs || count > function.maxArgs):
+		expected := pluralize(function.minArgs, "argument")
+		if function.maxArgs > function.minArgs {
+			expected = fmt.Sprintf("%d or %d arguments", function.minArgs, function.maxArgs)
+		}
+		return fmt.Errorf("%s expects %s", name, expected)
+	}
+	return nil
+}
+
+func (c *Calculator) callBuiltin(name string, function builtin, args []float64) (float64, error) {
+	if err := function.checkArity(name, len(args)); err != nil {
+		return 0, err
+	}
+	unit := c.angleUnit()
+	if function.angleArg {
+		args[0] = convertAngle(args[0], unit, "rad")
+	}
+	result, err := function.call(args)
+	if function.angleResult {
+		result = convertAngle(result, "rad", unit)
+	}
+	return result, err
+}
@@ -0,0 +1,557 @@
+// Package calc evaluates arithmetic expressions such as "3 + 5 * (2 - 4)" or
+// "sin(3.14 / 2)". Expressions are tokenized, parsed into a syntax tree by
+// recursive descent, and evaluated by walking the tree.
+//
+// Evaluate handles plain expressions. A Calculator additionally carries
+// settings such as fraction mode and accepts the calculator's extended input
+// through EvaluateInput: calculations in words, feet and inches, kitchen unit
+// conversions, date math, and the everyday helper functions.
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	addOperator      = "+"
+	subtractOperator = "-"
+	multiplyOperator = "*"
+	divideOperator   = "/"
+	powerOperator    = "^"
+	floorDivOperator = "//"
+	// moduloOperator is written as % or mod between two operands, as in 7 % 3.
+	moduloOperator = "mod"
+	// moduloSign is the tokenizer's form of the word mod, as in 7 mod -3. It
+	// takes the same three bytes.
+	moduloSign = "⊘"
+	leftParen  = "("
+	rightParen = ")"
+	comma      = ","
+	// leftBracket and rightBracket enclose a list, as in [1, 2, 3], and an
+	// index into one, as in xs[2].
+	leftBracket  = "["
+	rightBracket = "]"
+	// pieceSeparator separates the condition of a piece from its value, as
+	// in piecewise(x < 0: -x, x >= 0: x).
+	pieceSeparator = ":"
+	// equationSign separates the sides of the equation that solve takes, as
+	// in solve(x^2 - 4 = 0, x).
+	equationSign = "="
+
+	// implicitMultiplyOperator is never typed by the user; the tokenizer inserts
+	// it between adjacent operands such as 2(3) so its precedence can differ from *.
+	implicitMultiplyOperator = "·"
+	// unitMultiplyOperator is the implicit multiplication of a number by the
+	// unit after it, as in 10 m / 2 s, which binds tighter than * and / so
+	// that it reads as (10 m) / (2 s).
+	unitMultiplyOperator = "⋅"
+	// negateOperator is the tokenizer's form of a unary minus, as in -(4+1) or 2*-3.
+	negateOperator = "−"
+	// percentOperator is a % that follows its operand, as in 200 * 15%, rather
+	// than standing between two. After + or - it is a percentage of the left
+	// operand, so 120 - 10% is 108.
+	percentOperator = "%"
+	// factorialOperator follows its operand, as in 5!.
+	factorialOperator = "!"
+	// rootOperator precedes its operand for a square root, as in √2, and
+	// follows a number for a root of that index, as in 3√27.
+	rootOperator = "√"
+
+	// The bitwise operators work on 64-bit integers and bind more loosely
+	// than arithmetic, as in C: 1 << 2 + 1 is 8.
+	bitAndOperator     = "&"
+	bitOrOperator      = "|"
+	shiftLeftOperator  = "<<"
+	shiftRightOperator = ">>"
+	// bitNotOperator precedes its operand, as in ~5.
+	bitNotOperator = "~"
+	// xorOperator is the tokenizer's form of xor, which is written as a word
+	// between two operands, as in 5 xor 3. It takes the same three bytes.
+	xorOperator = "⊻"
+	// convertOperator is the tokenizer's form of in, which converts a
+	// quantity to other units, as in 60 mph in km/h. It binds more loosely
+	// than arithmetic, so that a comparison can convert both sides.
+	convertOperator = "->"
+
+	// The comparison operators compare two numbers, giving true or false, and
+	// bind more loosely than arithmetic: x + 1 > 2 * y.
+	equalOperator        = "=="
+	notEqualOperator     = "!="
+	lessOperator         = "<"
+	lessEqualOperator    = "<="
+	greaterOperator      = ">"
+	greaterEqualOperator = ">="
+	// andOperator, orOperator, and notOperator are the tokenizer's forms of
+	// the words and, or, and not, which combine conditions. They bind the
+	// most loosely of all, not before and and and before or.
+	andOperator = "&&"
+	orOperator  = "||"
+	notOperator = "¬"
+)
+
+var (
+	operators     = []string{addOperator, subtractOperator, multiplyOperator, divideOperator, floorDivOperator, moduloOperator, powerOperator, implicitMultiplyOperator, unitMultiplyOperator, negateOperator, percentOperator, factorialOperator, rootOperator, bitAndOperator, bitOrOperator, xorOperator, shiftLeftOperator, shiftRightOperator, bitNotOperator, convertOperator, equalOperator, notEqualOperator, lessOperator, lessEqualOperator, greaterOperator, greaterEqualOperator, andOperator, orOperator, notOperator}
+	precedence    = map[string]int{orOperator: 1, andOperator: 2, notOperator: 3, equalOperator: 4, notEqualOperator: 4, lessOperator: 4, lessEqualOperator: 4, greaterOperator: 4, greaterEqualOperator: 4, convertOperator: 5, bitOrOperator: 6, xorOperator: 7, bitAndOperator: 8, shiftLeftOperator: 9, shiftRightOperator: 9, addOperator: 10, subtractOperator: 10, multiplyOperator: 11, divideOperator: 11, floorDivOperator: 11, moduloOperator: 11, implicitMultiplyOperator: 11, negateOperator: 12, bitNotOperator: 12, unitMultiplyOperator: 13, powerOperator: 14, rootOperator: 14}
+	associativity = map[string]string{orOperator: "L", andOperator: "L", notOperator: "R", equalOperator: "L", notEqualOperator: "L", lessOperator: "L", lessEqualOperator: "L", greaterOperator: "L", greaterEqualOperator: "L", convertOperator: "L", bitOrOperator: "L", xorOperator: "L", bitAndOperator: "L", shiftLeftOperator: "L", shiftRightOperator: "L", addOperator: "L", subtractOperator: "L", multiplyOperator: "L", divideOperator: "L", floorDivOperator: "L", moduloOperator: "L", implicitMultiplyOperator: "L", unitMultiplyOperator: "L", negateOperator: "R", bitNotOperator: "R", powerOperator: "R", rootOperator: "R"}
+
+	ErrInvalidOperator    = fmt.Errorf("invalid operator. Use +, -, *, /, //, %%, or ^")
+	ErrDivideByZero       = fmt.Errorf("cannot divide by zero")
+	ErrInsufficientValues = fmt.Errorf("insufficient values for operation")
+	ErrMismatchedParens   = fmt.Errorf("mismatched parentheses")
+	ErrDomain             = fmt.Errorf("argument outside the domain of the function")
+	ErrIncompatibleUnits  = fmt.Errorf("incompatible units")
+	ErrInvalidCharacter   = fmt.Errorf("invalid character")
+	ErrDeadline           = fmt.Errorf("the calculation did not finish in time")
+)
+
+// SyntaxError is the error of input that cannot be read as an expression, such
+// as 2 + * 3 or an undefined name, as opposed to one that fails to evaluate.
+type SyntaxError struct {
+	Err error
+	// Input is the expression that was read and Offset the byte offset in it
+	// of the token at fault, or len(Input) when the expression ended early.
+	Input  string
+	Offset int
+}
+
+func (e *SyntaxError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SyntaxError) Unwrap() error {
+	return e.Err
+}
+
+// Column returns the column of the token at fault, counting from 1.
+func (e *SyntaxError) Column() int {
+	return utf8.RuneCountInString(e.Input[:e.Offset]) + 1
+}
+
+// SyntaxErrors is the error of an expression with more than one problem, such
+// as two undefined names or a call with the wrong number of arguments. It
+// lists them in the order they appear in the input.
+type SyntaxErrors struct {
+	Errors []*SyntaxError
+}
+
+func (e *SyntaxErrors) Error() string {
+	messages := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Unwrap returns the first problem, so that errors.As finds a SyntaxError.
+func (e *SyntaxErrors) Unwrap() error {
+	return e.Errors[0]
+}
+
+// Calculator evaluates expressions with a set of user settings. Create one with
+// New; the zero value works too but has no grade scale for gpa() and shows
+// decimal results without decimal places.
+type Calculator struct {
+	// ImplicitTight makes implicit multiplication bind more tightly than * and /,
+	// so 1/2(3) reads as 1/(2*3). By default it shares their precedence.
+	ImplicitTight bool
+	// FractionMode accepts mixed numbers like 1 1/2 and formats results as fractions.
+	FractionMode bool
+	// AngleUnit is the unit of the angles trigonometric functions take and
+	// inverse trigonometric functions return: "rad", the default, "deg",
+	// "grad", or "turn".
+	AngleUnit string
+	// Decimals is the number of decimal places decimal results are shown
+	// with, in fixed and scientific notation. It only affects display;
+	// calculations keep full precision.
+	Decimals int
+	// Notation is how decimal results are written: fixed, scientific, or
+	// shortest.
+	Notation Notation
+	// TrimZeros drops the trailing zeros of decimal results, so 7.000000 is
+	// shown as 7.
+	TrimZeros bool
+	// Grouping separates the thousands of results with commas.
+	Grouping bool
+	// ExplainGrouping makes EvaluateInput warn about expressions that could be
+	// read another way, such as a/b*c and -x^2, saying how they were grouped
+	// and what the other reading gives.
+	ExplainGrouping bool
+	// CollectStats makes EvaluateInput report the EvalStats of each
+	// evaluation in its Result.
+	CollectStats bool
+	// TrackProvenance makes assignments record the expression each variable
+	// was computed from and the variables it used, which Why explains.
+	TrackProvenance bool
+	// Base is the base results are shown in: 2, 8, or 16, or decimal for 10
+	// and the zero value. Results that are not whole are shown as floats in
+	// bases 2 and 16 and stay decimal in base 8.
+	Base int
+	// Deadline, when set, stops an evaluation with ErrDeadline at the first
+	// step it takes after that time, so that a server can give up on a
+	// calculation without leaving it running.
+	Deadline time.Time
+	// Holidays is the calendar skipped by business-day date math, if any.
+	Holidays HolidayCalendar
+	// Rates is the source of exchange rates for currency conversions such as
+	// 100 USD in EUR; without one the bundled rates are used.
+	Rates RateSource
+	// GradeScale maps letter grades to grade points for gpa().
+	GradeScale map[string]float64
+	// Variables holds the values assigned with statements such as x = 3.5 or
+	// p = poly(1, -3, 2).
+	Variables map[string]Value
+	// Functions holds the functions defined with statements such as f(x) = x^2 + 1,
+	// with the overloads of each name ordered by their number of parameters.
+	Functions map[string][]Function
+	// Workspaces are the other calculators whose variables expressions can
+	// refer to as workspace::name, such as budget::total.
+	Workspaces map[string]*Calculator
+
+	// answers are the last results of EvaluateInput that have a value, the
+	// latest first, which expressions refer to as ans or _ and ans(2),
+	// ans(3), and so on.
+	answers []Value
+	// session holds the session variables while Variables holds the bindings
+	// of a function call or let expression.
+	session map[string]Value
+	// derivations are how the session variables assigned while
+	// TrackProvenance was set got their values.
+	derivations map[string]*derivation
+	// definitions are the expressions the session variables were last
+	// assigned, and assignments counts the assignments made so far.
+	definitions map[string]assignment
+	assignments int
+	// callDepth counts the user function calls in progress.
+	callDepth int
+	// extensions are the kinds of values added with Register.
+	extensions []Extension
+	// packs are the names of the extensions added with Register and the
+	// paths of the formula packs loaded with LoadPack.
+	packs []string
+	// rateWarning says why the bundled exchange rates were used instead of
+	// those of Rates, until the result it applies to takes it.
+	rateWarning string
+	// sandbox names the formula pack function whose call is in progress, if
+	// any, and stepsLeft is the number of steps the call may still take.
+	sandbox   string
+	stepsLeft int
+	// stats collects the EvalStats of the EvaluateInput call in progress
+	// when CollectStats is set.
+	stats *EvalStats
+	// rng gives the random numbers of rand, randint, and randnorm, and
+	// drewRandom reports whether the input being evaluated used any.
+	rng        *rand.Rand
+	drewRandom bool
+}
+
+// Result is the outcome of EvaluateInput.
+type Result struct {
+	// Interpretation is the expression that input written in words was
+	// translated to, or empty when no translation took place.
+	Interpretation string
+	// Value is the numeric result of an arithmetic expression.
+	Value float64
+	// Typed is the numeric result as a typed value, which keeps integer and
+	// fractional results exact.
+	Typed Value
+	// Numeric reports whether the input had a real numeric result in Value, as
+	// opposed to results such as dates or values of an extension's kind that
+	// only have Text and Typed.
+	Numeric bool
+	// Text is the result formatted for display.
+	Text string
+	// Warnings lists anything ambiguous about the input or its result.
+	Warnings []string
+	// Stats is the work the evaluation took, when the Calculator has
+	// CollectStats set, and nil otherwise.
+	Stats *EvalStats
+}
+
+// New returns a Calculator with the default settings, changed by options
+// such as WithNotation(ShortestNotation).
+func New(options ...Option) *Calculator {
+	scale, _ := GradeScale("4.0")
+	c := &Calculator{Decimals: defaultDecimals, GradeScale: scale, Variables: map[string]Value{}, Functions: map[string][]Function{}}
+	c.extensions = []Extension{c.matrixExtension(), c.listExtension(), c.polynomialExtension(), c.momentExtension(), c.quantityExtension(), c.tupleExtension(), c.recordExtension(), c.randomExtension(), c.combinatoricsExtension(), c.helperExtension()}
+	for _, option := range options {
+		option(c)
+	}
+	return c
+}
+
+// Evaluate evaluates an arithmetic expression with the default settings.
+func Evaluate(expr string) (float64, error) {
+	return New().Evaluate(expr)
+}
+
+// Evaluate evaluates an arithmetic expression. A complex result is NaN, and
+// a result of an extension's kind, such as a quantity, is an error.
+func (c *Calculator) Evaluate(input string) (float64, error) {
+	value, err := c.EvaluateValue(input)
+	if err != nil {
+		return 0, err
+	}
+	if !isNumeric(value) {
+		return 0, fmt.Errorf("expected a number, not %s", article(value.Kind()))
+	}
+	return toFloat(value), nil
+}
+
+// EvaluateValue evaluates an arithmetic expression to a typed value, which
+// keeps integer and fractional results exact.
+func (c *Calculator) EvaluateValue(input string) (Value, error) {
+	tree, err := c.compileExpression(input)
+	if err != nil {
+		return nil, err
+	}
+	return c.evaluateNode(tree)
+}
+
+// compileExpression compiles input, which may also be a let expression or a
+// piecewise expression in braces, into its syntax tree.
+func (c *Calculator) compileExpression(input string) (node, error) {
+	if names, values, body, ok := parseLet(input); ok {
+		return c.compileLet(names, values, body)
+	}
+	if isPiecewise(input) {
+		return c.compilePiecewise(input)
+	}
+	return c.compile(expandMixedNumbers(input))
+}
+
+// compile parses an expression into its syntax tree and checks its names and
+// function calls. An expression with one problem fails with a SyntaxError and
+// one with several with SyntaxErrors.
+func (c *Calculator) compile(input string) (node, error) {
+	// mod becomes % padded to the same length so offsets still match input,
+	// and xor and in their operators of three and two bytes.
+	squeezed, offsets := squeeze(replaceWordOperators(input))
+	var tokens []string
+	var problems []*SyntaxError
+	var tree node
+	err := c.withUnknowns(squeezed, func() error {
+		var positions []int
+		tokens, positions, problems = c.tokenize(squeezed)
+		if invalid := countInvalidCharacters(problems); invalid > maxInvalidCharacters {
+			return fmt.Errorf("%w: %d of its characters cannot be read", ErrNotExpression, invalid)
+		}
+		var err error
+		if tree, err = c.parse(tokens, positions, len(squeezed)); err != nil {
+			problems = append(problems, err.(*SyntaxError))
+		} else {
+			problems = append(problems, c.checkCalls(tree)...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) == 0 {
+		c.recordTree(len(tokens), tree)
+		return tree, nil
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Offset < problems[j].Offset })
+	for _, problem := range problems {
+		problem.Input = input
+		problem.Offset = offsets[problem.Offset]
+	}
+	if len(problems) == 1 {
+		return nil, problems[0]
+	}
+	return nil, &SyntaxErrors{Errors: problems}
+}
+
+// unknownCalls are the functions that take the name of an unknown, by the
+// index of the argument that holds it, as x in integrate(sin(x), x, 0, pi),
+// or -1 when the unknown is always x.
+var unknownCalls = map[string]int{integrateName: 1, solveName: 1, proportionName: -1}
+
+// callNameRegex matches the name and opening paren of each call in an input.
+var callNameRegex = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*\(`)
+
+// withUnknowns runs fn with the unknowns of the calls in input bound as
+// variables, so that an expression such as sin(x) in integrate(sin(x), x, 0,
+// pi) reads x as one. An unknown not given, as in solve(cos(x) = x), is x.
+func (c *Calculator) withUnknowns(input string, fn func() error) error {
+	var names []string
+	for _, match := range callNameRegex.FindAllStringIndex(input, -1) {
+		index, ok := unknownCalls[input[match[0]:match[1]-1]]
+		if !ok {
+			continue
+		}
+		end, closed := closingParen(input, match[1])
+		if closed {
+			end--
+		}
+		name := "x"
+		if args := splitArguments(input[match[1]:end]); index >= 0 && index < len(args) {
+			name = args[index]
+		}
+		if identifierRegex.FindString(name) == name && c.checkAssignable(name) == nil {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return fn()
+	}
+	return c.withBindings(names, placeholders(len(names)), fn)
+}
+
+// EvaluateInput evaluates one line of calculator input. Besides arithmetic
+// expressions it understands calculations in words, feet and inches, kitchen
+// unit conversions, date and time math, and the helper functions. The value of
+// a successful result becomes that of ans in later input.
+func (c *Calculator) EvaluateInput(input string) (Result, error) {
+	if err := checkInput(input); err != nil {
+		return Result{}, err
+	}
+	var start time.Time
+	if c.CollectStats {
+		c.stats, start = &EvalStats{}, time.Now()
+	}
+	result, err := c.evaluateStatement(strings.TrimSpace(input))
+	if c.stats != nil {
+		c.stats.Duration = time.Since(start)
+		result.Stats, c.stats = c.stats, nil
+	}
+	if err == nil && result.Typed != nil {
+		c.answers = append([]Value{result.Typed}, c.answers...)
+		if len(c.answers) > maxAnswers {
+			c.answers = c.answers[:maxAnswers]
+		}
+	}
+	return result, err
+}
+
+func (c *Calculator) evaluateStatement(input string) (Result, error) {
+	if _, _, _, ok := parseLet(input); ok || isPiecewise(input) {
+		return c.evaluateNumericInput(input)
+	}
+	if def, ok := parseFunctionDefinition(input); ok {
+		return c.defineFunction(def)
+	}
+	if names, expression, ok := parseDestructuring(input); ok {
+		return c.evaluateDestructuring(names, expression)
+	}
+	if name, expression, ok := parseAssignment(input); ok {
+		return c.evaluateAssignment(name, expression)
+	}
+	if output, ok, err := c.evaluateSpecialInput(input); ok {
+		return Result{Text: output}, err
+	}
+	return c.evaluateNumericInput(input)
+}
+
+// evaluateNumericInput evaluates input whose result is a number: arithmetic,
+// calculations in words, and feet and inches.
+func (c *Calculator) evaluateNumericInput(input string) (Result, error) {
+	var result Result
+	expression := input
+	if c.isSpokenInput(input) {
+		translated, warnings, err := c.translateSpoken(input)
+		if err != nil {
+			return result, err
+		}
+		result.Interpretation = translated
+		result.Warnings = warnings
+		expression = translated
+	}
+
+	isLength := hasFeetAndInches(expression)
+	if isLength {
+		expression = expandFeetAndInches(expression)
+	}
+
+	c.rateWarning, c.drewRandom = "", false
+	typed, err := c.EvaluateValue(expression)
+	if c.rateWarning != "" {
+		result.Warnings = append(result.Warnings, c.rateWarning)
+	}
+	if err != nil {
+		return result, err
+	}
+	value := toFloat(typed)
+	// Input that drew random numbers is not evaluated again, which would
+	// give a different result anyway and use up more of them.
+	if !c.drewRandom {
+		if warning := c.implicitMultiplicationWarning(expression, value); warning != "" {
+			result.Warnings = append(result.Warnings, warning)
+		}
+		if c.ExplainGrouping {
+			result.Warnings = append(result.Warnings, c.groupingWarnings(expression, typed)...)
+		}
+	}
+
+	result.Value = value
+	result.Typed = typed
+	result.Numeric = isReal(typed)
+	if isLength {
+		result.Text = formatFeetAndInches(value)
+	} else {
+		result.Text = c.FormatValue(typed)
+	}
+	return result, nil
+}
+
+func (c *Calculator) evaluateSpecialInput(input string) (string, bool, error) {
+	handlers := []func(string) (string, bool, error){c.evaluateAssertion, c.evaluateHelperFunction, c.evaluateDateTime, c.convertKitchenUnits}
+	for _, handler := range handlers {
+		if output, ok, err := handler(input); ok {
+			return output, true, err
+		}
+	}
+	return "", false, nil
+}
+
+// implicitMultiplicationWarning re-evaluates an expression that divides by an
+// implicit multiplication under the other implicit multiplication setting and
+// describes the difference, if any.
+func (c *Calculator) implicitMultiplicationWarning(expression string, result float64) string {
+	if !c.dividesImplicitly(expression) {
+		return ""
+	}
+	stats := c.stats
+	c.ImplicitTight, c.stats = !c.ImplicitTight, nil
+	alternative, err := c.Evaluate(expression)
+	c.ImplicitTight, c.stats = !c.ImplicitTight, stats
+	if err != nil || alternative == result || math.IsNaN(alternative) && math.IsNaN(result) {
+		return ""
+	}
+
+	other := "tight"
+	if c.ImplicitTight {
+		other = "loose"
+	}
+	return fmt.Sprintf("this result depends on implicit multiplication precedence; with 'implicit %s' it would be %f", other, alternative)
+}
+
+// dividesImplicitly reports whether an implicit multiplication follows a /,
+// //, or % in expression, as in 1/2(3), the only place where its precedence
+// can change the result.
+func (c *Calculator) dividesImplicitly(expression string) bool {
+	if c.FractionMode {
+		expression = expandMixedNumbers(expression)
+	}
+	squeezed, _ := squeeze(replaceWordOperators(expression))
+	tokens, _, _ := c.tokenize(squeezed)
+	divided := false
+	for _, token := range tokens {
+		switch token {
+		case divideOperator, floorDivOperator, moduloOperator:
+			divided = true
+		case implicitMultiplyOperator:
+			if divided {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,89 @@
+package calc
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+// concurrentInputs cover the registries and tables that every calculator
+// shares: functions, extensions, units, currencies, dates, and formatting.
+var concurrentInputs = []string{
+	"2 + 3 * 4",
+	"sqrt(2) * sin(pi / 4)",
+	"2^70 + 1",
+	"1/3 + 1/6",
+	"nCr(10, 3) + fib(20)",
+	"mean([1, 2, 3, 4])",
+	"5 km in mi",
+	"1 kg in lb",
+	"2025-03-01 + 30 days",
+	"0x1f + 0b101",
+	"f(n) = n * 2",
+	"f(21)",
+	"solve(x^2 = 9)",
+	"integrate(x^2, x, 0, 3)",
+	"[1, 2, 3][2]",
+	"divmod(17, 5)",
+	"1 / 0",
+	"undefined_name + 1",
+}
+
+// evaluateAll evaluates concurrentInputs in a new calculator and returns the
+// text of each result or error.
+func evaluateAll() []string {
+	c := New()
+	results := make([]string, len(concurrentInputs))
+	for i, input := range concurrentInputs {
+		result, err := c.EvaluateInput(input)
+		if err != nil {
+			results[i] = "error: " + err.Error()
+		} else {
+			results[i] = result.Text
+		}
+	}
+	return results
+}
+
+// TestConcurrentCalculators checks that calculators used at the same time
+// give the results they give one at a time. Run it with -race.
+func TestConcurrentCalculators(t *testing.T) {
+	want := evaluateAll()
+	const goroutines = 64
+	var wg sync.WaitGroup
+	got := make([][]string, goroutines)
+	for g := 0; g < goroutines; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			got[g] = evaluateAll()
+		}(g)
+	}
+	wg.Wait()
+	for g, results := range got {
+		for i, result := range results {
+			if result != want[i] {
+				t.Errorf("goroutine %d: %s = %q, want %q", g, concurrentInputs[i], result, want[i])
+			}
+		}
+	}
+}
+
+// TestDeadline checks that an evaluation stops at the Deadline, even inside
+// a calculation that takes many steps.
+func TestDeadline(t *testing.T) {
+	c := New()
+	if _, err := c.EvaluateInput("f(n) = if(n < 2, n, f(n - 1) + f(n - 2))"); err != nil {
+		t.Fatal(err)
+	}
+	c.Deadline = time.Now().Add(50 * time.Millisecond)
+	start := time.Now()
+	_, err := c.EvaluateInput("f(40)")
+	if !errors.Is(err, ErrDeadline) {
+		t.Fatalf("got error %v, want %v", err, ErrDeadline)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("the evaluation stopped after %s", elapsed)
+	}
+}
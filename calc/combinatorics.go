@@ -0,0 +1,244 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// maxFactorBits bounds the size of the numbers factor takes, which Pollard's
+// rho method splits quickly up to about this size.
+const maxFactorBits = 64
+
+// combinatoricsExtension provides the combinatorics and number theory
+// functions nCr, nPr, gcd, lcm, isprime, fib, and factor, which work on whole
+// numbers and give exact results.
+func (c *Calculator) combinatoricsExtension() Extension {
+	return Extension{
+		Name: "combinatorics",
+		Functions: map[string]func([]Value) (Value, error){
+			"nCr": func(args []Value) (Value, error) {
+				n, k, err := choiceArguments(args)
+				if err != nil {
+					return nil, err
+				}
+				if k > n {
+					return Int{big.NewInt(0)}, nil
+				}
+				if k > n-k {
+					k = n - k
+				}
+				if logarithm := logFactorialRatio(n, n-k) - logFactorialRatio(k, 0); logarithm/math.Ln2 > maxExactBits {
+					return Float(math.Exp(logarithm)), nil
+				}
+				return Int{new(big.Int).Binomial(n, k)}, nil
+			},
+			"nPr": func(args []Value) (Value, error) {
+				n, k, err := choiceArguments(args)
+				if err != nil {
+					return nil, err
+				}
+				if k > n {
+					return Int{big.NewInt(0)}, nil
+				}
+				if k == 0 {
+					return Int{big.NewInt(1)}, nil
+				}
+				if logarithm := logFactorialRatio(n, n-k); logarithm/math.Ln2 > maxExactBits {
+					return Float(math.Exp(logarithm)), nil
+				}
+				return Int{new(big.Int).MulRange(n-k+1, n)}, nil
+			},
+			"gcd": func(args []Value) (Value, error) {
+				numbers, err := wholeArguments(args)
+				if err != nil {
+					return nil, err
+				}
+				result := new(big.Int)
+				for _, n := range numbers {
+					result.GCD(nil, nil, result, new(big.Int).Abs(n))
+				}
+				return Int{result}, nil
+			},
+			"lcm": func(args []Value) (Value, error) {
+				numbers, err := wholeArguments(args)
+				if err != nil {
+					return nil, err
+				}
+				result := big.NewInt(1)
+				for _, n := range numbers {
+					if n.Sign() == 0 {
+						return Int{big.NewInt(0)}, nil
+					}
+					gcd := new(big.Int).GCD(nil, nil, result, new(big.Int).Abs(n))
+					result.Mul(result, new(big.Int).Quo(new(big.Int).Abs(n), gcd))
+				}
+				return Int{result}, nil
+			},
+			"isprime": func(args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+				}
+				n, ok := wholeNumber(args[0])
+				if !ok {
+					return nil, fmt.Errorf("takes a whole number, not %s", c.FormatValue(args[0]))
+				}
+				return Bool(n.Sign() > 0 && n.ProbablyPrime(20)), nil
+			},
+			"fib": func(args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+				}
+				n, ok := wholeNumber(args[0])
+				if !ok || n.Sign() < 0 {
+					return nil, fmt.Errorf("takes a whole number of at least 0, not %s", c.FormatValue(args[0]))
+				}
+				// fib(n) has about 0.694n bits.
+				if !n.IsInt64() || float64(n.Int64())*0.694 > maxExactBits {
+					return Float(math.Pow(math.Phi, toFloat(args[0])) / math.Sqrt(5)), nil
+				}
+				return Int{fibonacci(n.Int64())}, nil
+			},
+			"factor": c.factorNumber,
+		},
+	}
+}
+
+// choiceArguments returns the n and k of nCr(n, k) and nPr(n, k).
+func choiceArguments(args []Value) (int64, int64, error) {
+	if len(args) != 2 {
+		return 0, 0, fmt.Errorf("expected 2 arguments, got %d", len(args))
+	}
+	n, nOk := wholeNumber(args[0])
+	k, kOk := wholeNumber(args[1])
+	if !nOk || !kOk || n.Sign() < 0 || k.Sign() < 0 || !n.IsInt64() || !k.IsInt64() {
+		return 0, 0, fmt.Errorf("takes two whole numbers of at least 0")
+	}
+	return n.Int64(), k.Int64(), nil
+}
+
+// wholeArguments returns the arguments of gcd and lcm, of which there must be
+// two or more.
+func wholeArguments(args []Value) ([]*big.Int, error) {
+	if len(args) < 2 {
+		return nil, fmt.Errorf("expected at least 2 arguments, got %d", len(args))
+	}
+	numbers := make([]*big.Int, len(args))
+	for i, arg := range args {
+		n, ok := wholeNumber(arg)
+		if !ok {
+			return nil, fmt.Errorf("takes whole numbers, not %s", arg)
+		}
+		numbers[i] = n
+	}
+	return numbers, nil
+}
+
+// logFactorialRatio returns the natural logarithm of n!/m!, to tell whether an
+// exact result would be too large.
+func logFactorialRatio(n, m int64) float64 {
+	a, _ := math.Lgamma(float64(n) + 1)
+	b, _ := math.Lgamma(float64(m) + 1)
+	return a - b
+}
+
+// fibonacci returns the nth Fibonacci number by fast doubling: fib(2m) is
+// fib(m)·(2fib(m+1) − fib(m)) and fib(2m+1) is fib(m)² + fib(m+1)².
+func fibonacci(n int64) *big.Int {
+	a, b := big.NewInt(0), big.NewInt(1)
+	for bit := 62; bit >= 0; bit-- {
+		twice := new(big.Int).Lsh(b, 1)
+		twice.Sub(twice, a)
+		even := new(big.Int).Mul(a, twice)
+		odd := new(big.Int).Add(new(big.Int).Mul(a, a), new(big.Int).Mul(b, b))
+		a, b = even, odd
+		if n>>uint(bit)&1 == 1 {
+			a, b = b, new(big.Int).Add(a, b)
+		}
+	}
+	return a
+}
+
+// factorNumber gives factor(360), the prime factorization of a whole number
+// as a list of prime and exponent pairs: [(2, 3), (3, 2), (5, 1)] for
+// 2^3 * 3^2 * 5. factor(1) is the empty list.
+func (c *Calculator) factorNumber(args []Value) (Value, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	n, ok := wholeNumber(args[0])
+	if !ok || n.Sign() <= 0 {
+		return nil, fmt.Errorf("takes a whole number of at least 1, not %s", c.FormatValue(args[0]))
+	}
+	if n.BitLen() > maxFactorBits {
+		return nil, fmt.Errorf("takes numbers of up to %d bits, and %s has %d", maxFactorBits, n, n.BitLen())
+	}
+	factors := List{}
+	if n.Cmp(big.NewInt(1)) == 0 {
+		return factors, nil
+	}
+
+	primes := primeFactors(new(big.Int).Set(n))
+	for i := 0; i < len(primes); {
+		j := i
+		for j < len(primes) && primes[j].Cmp(primes[i]) == 0 {
+			j++
+		}
+		factors = append(factors, Tuple{Int{primes[i]}, Int{big.NewInt(int64(j - i))}})
+		i = j
+	}
+	return factors, nil
+}
+
+// primeFactors returns the prime factors of n, which is greater than 1, in
+// ascending order and repeated by their multiplicity.
+func primeFactors(n *big.Int) []*big.Int {
+	var factors []*big.Int
+	for _, p := range []int64{2, 3, 5, 7, 11, 13, 17, 19, 23, 29, 31, 37} {
+		prime := big.NewInt(p)
+		for new(big.Int).Mod(n, prime).Sign() == 0 {
+			factors = append(factors, prime)
+			n.Quo(n, prime)
+		}
+	}
+	var split func(n *big.Int)
+	split = func(n *big.Int) {
+		if n.Cmp(big.NewInt(1)) == 0 {
+			return
+		}
+		if n.ProbablyPrime(20) {
+			factors = append(factors, n)
+			return
+		}
+		d := pollardRho(n)
+		split(d)
+		split(new(big.Int).Quo(n, d))
+	}
+	split(n)
+	sort.Slice(factors, func(i, j int) bool { return factors[i].Cmp(factors[j]) < 0 })
+	return factors
+}
+
+// pollardRho returns a factor of the composite n other than 1 and n, found
+// with Pollard's rho method.
+func pollardRho(n *big.Int) *big.Int {
+	one := big.NewInt(1)
+	for constant := int64(1); ; constant++ {
+		step := func(x *big.Int) *big.Int {
+			next := new(big.Int).Mul(x, x)
+			next.Add(next, big.NewInt(constant))
+			return next.Mod(next, n)
+		}
+		x, y := big.NewInt(2), big.NewInt(2)
+		d := big.NewInt(1)
+		for d.Cmp(one) == 0 {
+			x = step(x)
+			y = step(step(y))
+			d.GCD(nil, nil, new(big.Int).Abs(new(big.Int).Sub(x, y)), n)
+		}
+		if d.Cmp(n) != 0 {
+			return d
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package calc
+
+import (
+	"sort"
+	"strings"
+)
+
+// wordOperators are the reserved names that are operators rather than
+// functions.
+var wordOperators = map[string]bool{moduloOperator: true, "xor": true, "in": true, "and": true, "or": true, "not": true}
+
+// Completions returns the names that complete prefix in an expression: the
+// builtin, helper, extension, and user functions, written with their opening
+// parenthesis as in sin(, the operators written as words, such as and, with
+// a space after them, and the constants and variables. They are sorted and
+// without duplicates.
+func (c *Calculator) Completions(prefix string) []string {
+	seen := map[string]bool{}
+	var completions []string
+	add := func(name, suffix string) {
+		if strings.HasPrefix(name, prefix) && !seen[name+suffix] {
+			seen[name+suffix] = true
+			completions = append(completions, name+suffix)
+		}
+	}
+
+	for name := range builtins {
+		add(name, leftParen)
+	}
+	for name := range reservedNames {
+		if wordOperators[name] {
+			add(name, " ")
+		} else {
+			add(name, leftParen)
+		}
+	}
+	for _, extension := range c.extensions {
+		for name := range extension.Functions {
+			add(name, leftParen)
+		}
+	}
+	for name := range c.Functions {
+		add(name, leftParen)
+	}
+	for name := range constants {
+		add(name, "")
+	}
+	for name := range c.Variables {
+		add(name, "")
+	}
+	if len(c.answers) > 0 {
+		add(ansName, "")
+	}
+	sort.Strings(completions)
+	return completions
+}
@@ -0,0 +1,163 @@
+package calc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// currencyCodeRegex matches the ISO 4217 code of a currency, such as EUR.
+var currencyCodeRegex = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// RateSource gives exchange rates: the number of units of each currency, by
+// its code, that one unit of a common base currency buys. StaticRates is a
+// fixed table, and HTTPRates fetches live rates.
+type RateSource interface {
+	Rates() (map[string]float64, error)
+}
+
+// StaticRates is a fixed table of exchange rates.
+type StaticRates map[string]float64
+
+func (r StaticRates) Rates() (map[string]float64, error) {
+	return r, nil
+}
+
+// BundledRatesDate is the day the rates of BundledRates were taken.
+const BundledRatesDate = "2025-01-02"
+
+// BundledRates are the rates used when a Calculator has no other source, in
+// US dollars.
+var BundledRates = StaticRates{
+	"USD": 1, "EUR": 0.96, "GBP": 0.80, "JPY": 157.2, "CNY": 7.30, "CHF": 0.91, "CAD": 1.44, "AUD": 1.61, "NZD": 1.78,
+	"SEK": 11.05, "NOK": 11.35, "DKK": 7.19, "ISK": 139, "PLN": 4.12, "CZK": 24.2, "HUF": 396, "TRY": 35.3,
+	"INR": 85.6, "KRW": 1470, "SGD": 1.36, "HKD": 7.77, "THB": 34.3, "ILS": 3.65, "BHD": 0.376, "KWD": 0.308,
+	"MXN": 20.5, "BRL": 6.18, "ZAR": 18.8,
+}
+
+// currencyDecimals are the decimal places of the currencies that do not have
+// 2, such as the yen, which has none.
+var currencyDecimals = map[string]int{"JPY": 0, "KRW": 0, "ISK": 0, "CLP": 0, "VND": 0, "BHD": 3, "KWD": 3, "JOD": 3, "OMR": 3, "TND": 3}
+
+const (
+	// defaultRatesTTL is how long HTTPRates keeps the rates it fetched when
+	// its TTL is zero.
+	defaultRatesTTL = time.Hour
+	// ratesRetryDelay is how long HTTPRates waits after failing to fetch the
+	// rates before it tries again.
+	ratesRetryDelay = time.Minute
+)
+
+// HTTPRates fetches exchange rates from URL, which must answer with a JSON
+// object holding them by currency code under "rates", along with the code of
+// the base currency under "base" or "base_code", as many free services do. It
+// keeps the rates for TTL, or an hour when TTL is zero, and keeps using them
+// when fetching newer ones fails.
+type HTTPRates struct {
+	URL    string
+	TTL    time.Duration
+	Client *http.Client
+
+	mu      sync.Mutex
+	rates   map[string]float64
+	fetched time.Time
+	err     error
+	failed  time.Time
+}
+
+func (h *HTTPRates) Rates() (map[string]float64, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ttl := h.TTL
+	if ttl == 0 {
+		ttl = defaultRatesTTL
+	}
+	if h.rates != nil && time.Since(h.fetched) < ttl {
+		return h.rates, nil
+	}
+	if h.err == nil || time.Since(h.failed) >= ratesRetryDelay {
+		if rates, err := h.fetch(); err != nil {
+			h.err, h.failed = err, time.Now()
+		} else {
+			h.rates, h.fetched, h.err = rates, time.Now(), nil
+		}
+	}
+	if h.rates != nil {
+		return h.rates, nil
+	}
+	return nil, h.err
+}
+
+// fetch gets the rates from h.URL.
+func (h *HTTPRates) fetch() (map[string]float64, error) {
+	client := h.Client
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	response, err := client.Get(h.URL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s answered %s", h.URL, response.Status)
+	}
+	var body struct {
+		Base     string             `json:"base"`
+		BaseCode string             `json:"base_code"`
+		Rates    map[string]float64 `json:"rates"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("reading the rates of %s: %w", h.URL, err)
+	}
+	if len(body.Rates) == 0 {
+		return nil, fmt.Errorf("%s gave no rates", h.URL)
+	}
+	for _, base := range []string{body.Base, body.BaseCode} {
+		if base != "" {
+			body.Rates[base] = 1
+		}
+	}
+	return body.Rates, nil
+}
+
+// currencyMeasure returns the currency with the given code as a unit of
+// money, worth the base currency of the rates divided by its rate. When the
+// source of rates fails the bundled rates are used, with a warning.
+func (c *Calculator) currencyMeasure(code string) (measure, bool) {
+	if !currencyCodeRegex.MatchString(code) {
+		return measure{}, false
+	}
+	rates := map[string]float64(BundledRates)
+	if c.Rates != nil {
+		live, err := c.Rates.Rates()
+		if err != nil {
+			c.rateWarning = fmt.Sprintf("could not get exchange rates (%s); using the bundled rates of %s", err, BundledRatesDate)
+		} else {
+			rates = live
+		}
+	}
+	rate, ok := rates[code]
+	if !ok || rate <= 0 {
+		return measure{}, false
+	}
+	return measure{1 / rate, money}, true
+}
+
+// formatMoney writes an amount of a currency with the decimal places of the
+// currency, as in 92.00 EUR or 15720 JPY.
+func (c *Calculator) formatMoney(amount float64, code string) string {
+	places, ok := currencyDecimals[code]
+	if !ok {
+		places = 2
+	}
+	text := strconv.FormatFloat(amount, 'f', places, 64)
+	if c.Grouping {
+		text = groupThousands(text)
+	}
+	return text + " " + code
+}
@@ -0,0 +1,582 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	_ "time/tzdata"
+	"unicode"
+)
+
+var (
+	dateRegex     = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+	clockRegex    = regexp.MustCompile(`^\d{1,2}:\d{2}(:\d{2})?$`)
+	durationRegex = regexp.MustCompile(`(\d+(?:\.\d+)?)(days|day|d|hours|hour|h|minutes|minute|min|m|seconds|second|sec|s)`)
+)
+
+// dateTimeValue is either a moment in a time zone or a duration. Durations keep
+// whole days apart from the exact part so that adding days preserves the
+// wall-clock time across daylight saving changes.
+type dateTimeValue struct {
+	isMoment bool
+	moment   time.Time
+	days     int
+	exact    time.Duration
+}
+
+// HolidayCalendar decides which dates are public holidays for business-day
+// calculations. LoadHolidayCalendar reads one from a file.
+type HolidayCalendar interface {
+	Name() string
+	IsHoliday(date time.Time) bool
+}
+
+type fileHolidayCalendar struct {
+	name  string
+	dates map[string]string
+}
+
+func (h *fileHolidayCalendar) Name() string {
+	return h.name
+}
+
+func (h *fileHolidayCalendar) IsHoliday(date time.Time) bool {
+	_, ok := h.dates[date.Format("2006-01-02")]
+	return ok
+}
+
+// LoadHolidayCalendar reads the calendar <name>.txt from dir. Each line holds a
+// YYYY-MM-DD date and an optional description; lines starting with # are
+// comments.
+func LoadHolidayCalendar(dir, name string) (HolidayCalendar, error) {
+	data, err := os.ReadFile(filepath.Join(dir, name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("cannot load holiday calendar '%s' from %s", name, dir)
+	}
+
+	calendar := &fileHolidayCalendar{name: name, dates: map[string]string{}}
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if _, err := time.Parse("2006-01-02", fields[0]); err != nil {
+			return nil, fmt.Errorf("%s.txt line %d: invalid date %s", name, number+1, fields[0])
+		}
+		description := ""
+		if len(fields) == 2 {
+			description = strings.TrimSpace(fields[1])
+		}
+		calendar.dates[fields[0]] = description
+	}
+	return calendar, nil
+}
+
+// addDaysName is the name of adddays(date, n[, business]), whose third
+// argument is one of the dayKinds.
+const addDaysName = "adddays"
+
+// dayKinds are the kinds of days adddays can count, which are written as
+// words.
+var dayKinds = map[string]bool{"business": true, "calendar": true}
+
+// dateFunctions are the calendar functions of dates: workdays(start, end)
+// counts business days, age(date[, on]) gives the years, months, and days
+// since a date, and until(date) the days until it.
+func (c *Calculator) dateFunctions() map[string]func([]Value) (Value, error) {
+	return map[string]func([]Value) (Value, error){
+		"workdays": func(args []Value) (Value, error) {
+			if len(args) != 2 {
+				return nil, fmt.Errorf("workdays expects 2 arguments: workdays(start, end)")
+			}
+			dates, err := dateArguments("workdays", args)
+			if err != nil {
+				return nil, err
+			}
+			return Int{big.NewInt(int64(c.countWorkdays(dates[0], dates[1])))}, nil
+		},
+		"age": func(args []Value) (Value, error) {
+			if len(args) != 1 && len(args) != 2 {
+				return nil, fmt.Errorf("age expects 1 or 2 arguments: age(birthdate[, on])")
+			}
+			on, _ := parseDate("today")
+			dates, err := dateArguments("age", append(args, Moment{time: on, dateOnly: true}))
+			if err != nil {
+				return nil, err
+			}
+			birth, on := dates[0], dates[1]
+			if on.Before(birth) {
+				return nil, fmt.Errorf("%s is in the future", formatDate(birth))
+			}
+			years, months, days := dateDifference(birth, on)
+			age := newRecord([]string{"years", "months", "days"}, []Value{Int{big.NewInt(int64(years))}, Int{big.NewInt(int64(months))}, Int{big.NewInt(int64(days))}})
+			age.display = humanizeDateDifference(birth, on)
+			return age, nil
+		},
+		"until": func(args []Value) (Value, error) {
+			if len(args) != 1 {
+				return nil, fmt.Errorf("until expects 1 argument: until(date)")
+			}
+			dates, err := dateArguments("until", args)
+			if err != nil {
+				return nil, err
+			}
+			today, _ := parseDate("today")
+			days := civilDays(dates[0]) - civilDays(today)
+			return Quantity{amount: float64(days), units: []unitPower{{"day", 1, measures["day"]}}, display: humanizeCountdown(today, dates[0])}, nil
+		},
+	}
+}
+
+// evaluateAddDays evaluates adddays(date, n[, business]), which moves a date
+// by n calendar days, or by n business days when the third argument is
+// business.
+func (c *Calculator) evaluateAddDays(n callNode) (Value, error) {
+	business := false
+	if len(n.args) == 3 {
+		kind, _ := n.args[2].(nameNode)
+		if !dayKinds[kind.name] {
+			return nil, fmt.Errorf("the third argument of %s is the kind of day, business or calendar", n.name)
+		}
+		business = kind.name == "business"
+	}
+	values, err := c.evaluateArguments(n.args[:2])
+	if err != nil {
+		return nil, err
+	}
+	dates, err := dateArguments(n.name, values[:1])
+	if err != nil {
+		return nil, err
+	}
+	days, ok := wholeNumber(values[1])
+	if !ok {
+		return nil, fmt.Errorf("%s expects a whole number of days, not %s", n.name, c.FormatValue(values[1]))
+	}
+	if !days.IsInt64() || days.Int64() > maxShiftDays || days.Int64() < -maxShiftDays {
+		return nil, errDateRange
+	}
+	date := dates[0].AddDate(0, 0, int(days.Int64()))
+	if business {
+		date = c.addWorkdays(dates[0], int(days.Int64()))
+	}
+	if err := checkDateRange(date); err != nil {
+		return nil, err
+	}
+	return Moment{time: date, dateOnly: true}, nil
+}
+
+// dateArguments returns the calendar dates of the arguments of the function
+// name, which must be dates or moments, at midnight UTC like parseDate.
+func dateArguments(name string, args []Value) ([]time.Time, error) {
+	dates := make([]time.Time, len(args))
+	for i, arg := range args {
+		m, ok := arg.(Moment)
+		if !ok {
+			return nil, fmt.Errorf("%s takes dates such as 2025-01-01 or today, not %s", name, article(arg.Kind()))
+		}
+		year, month, day := m.time.Date()
+		dates[i] = time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	}
+	return dates, nil
+}
+
+// dateDifference splits the calendar distance between two dates, from not
+// after to, into whole years, months, and days. Month steps that land past the
+// end of a shorter month stop at its last day, so Jan 31 to Mar 1 is 1 month
+// and 1 day in a leap year.
+func dateDifference(from, to time.Time) (int, int, int) {
+	months := (to.Year()-from.Year())*12 + int(to.Month()) - int(from.Month())
+	if to.Day() < from.Day() {
+		months--
+	}
+	anchorMonth := time.Date(from.Year(), from.Month()+time.Month(months), 1, 0, 0, 0, 0, time.UTC)
+	lastDay := anchorMonth.AddDate(0, 1, -1).Day()
+	day := from.Day()
+	if day > lastDay {
+		day = lastDay
+	}
+	anchor := time.Date(anchorMonth.Year(), anchorMonth.Month(), day, 0, 0, 0, 0, time.UTC)
+	return months / 12, months % 12, int(civilDays(to) - civilDays(anchor))
+}
+
+// humanizeCountdown describes the time from today until date, as
+// in 2 months, 8 days (69 days) or 3 days ago (3 days).
+func humanizeCountdown(today, date time.Time) string {
+	days := civilDays(date) - civilDays(today)
+	switch {
+	case days == 0:
+		return "today (0 days)"
+	case days > 0:
+		return fmt.Sprintf("in %s (%s)", humanizeDateDifference(today, date), pluralize(int(days), "day"))
+	}
+	return fmt.Sprintf("%s ago (%s)", humanizeDateDifference(date, today), pluralize(int(-days), "day"))
+}
+
+// humanizeDateDifference writes the years, months, and days from one date to
+// a later one, leaving out those that are zero.
+func humanizeDateDifference(from, to time.Time) string {
+	years, months, days := dateDifference(from, to)
+	var parts []string
+	if years > 0 {
+		parts = append(parts, pluralize(years, "year"))
+	}
+	if months > 0 {
+		parts = append(parts, pluralize(months, "month"))
+	}
+	if days > 0 || len(parts) == 0 {
+		parts = append(parts, pluralize(days, "day"))
+	}
+	return strings.Join(parts, ", ")
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+func parseDate(text string) (time.Time, error) {
+	if strings.ToLower(text) == "today" {
+		year, month, day := time.Now().Date()
+		return time.Date(year, month, day, 0, 0, 0, 0, time.UTC), nil
+	}
+	date, err := time.Parse("2006-01-02", text)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date: %s, expected YYYY-MM-DD or today", text)
+	}
+	return date, nil
+}
+
+func formatDate(date time.Time) string {
+	return date.Format("Mon 2006-01-02")
+}
+
+func (c *Calculator) isWorkday(date time.Time) bool {
+	if date.Weekday() == time.Saturday || date.Weekday() == time.Sunday {
+		return false
+	}
+	return c.Holidays == nil || !c.Holidays.IsHoliday(date)
+}
+
+// countWorkdays counts business days between two dates, including both ends.
+// The count is negative when end comes before start.
+func (c *Calculator) countWorkdays(start, end time.Time) int {
+	sign := 1
+	if end.Before(start) {
+		start, end = end, start
+		sign = -1
+	}
+	count := 0
+	for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+		if c.isWorkday(date) {
+			count++
+		}
+	}
+	return sign * count
+}
+
+func (c *Calculator) addWorkdays(date time.Time, days int) time.Time {
+	step := 1
+	if days < 0 {
+		step, days = -1, -days
+	}
+	for days > 0 {
+		date = date.AddDate(0, 0, step)
+		if c.isWorkday(date) {
+			days--
+		}
+	}
+	return date
+}
+
+func looksLikeDateTime(input string) bool {
+	for _, word := range strings.Fields(input) {
+		lower := strings.ToLower(word)
+		if lower == "now" || clockRegex.MatchString(word) {
+			return true
+		}
+		if _, ok, _ := parseDuration(word); ok {
+			return true
+		}
+		if strings.Contains(word, "/") && unicode.IsLetter(rune(word[0])) {
+			if _, err := time.LoadLocation(word); err == nil {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// evaluateDateTime handles world clock input such as "now in Asia/Tokyo",
+// "09:00 America/New_York in Europe/Berlin", and adding or subtracting
+// durations and moments with spaced + and - operators. Input it cannot read
+// but that is an expression, such as today + 45 days, is left to the
+// expression's dates and quantities.
+func (c *Calculator) evaluateDateTime(input string) (string, bool, error) {
+	if !looksLikeDateTime(input) {
+		return "", false, nil
+	}
+
+	text := input
+	var target *time.Location
+	if i := strings.LastIndex(text, " in "); i >= 0 {
+		location, err := loadTimeZone(strings.TrimSpace(text[i+4:]))
+		if err != nil {
+			if _, compileErr := c.compile(input); compileErr == nil {
+				return "", false, nil
+			}
+			return "", true, err
+		}
+		target = location
+		text = text[:i]
+	}
+
+	value, err := parseDateTimeExpression(text)
+	if err != nil {
+		if _, compileErr := c.compile(input); compileErr == nil {
+			return "", false, nil
+		}
+		return "", true, err
+	}
+	if !value.isMoment {
+		if target != nil {
+			return "", true, fmt.Errorf("a duration cannot be converted to a time zone")
+		}
+		return formatDuration(value), true, nil
+	}
+	if target != nil {
+		value.moment = value.moment.In(target)
+	}
+	return formatMoment(value.moment), true, nil
+}
+
+func parseDateTimeExpression(text string) (dateTimeValue, error) {
+	fields := strings.Fields(text)
+	var result dateTimeValue
+	var term []string
+	operator := addOperator
+	first := true
+
+	apply := func() error {
+		if len(term) == 0 {
+			return fmt.Errorf("missing value after '%s'", operator)
+		}
+		value, err := parseDateTimeTerm(term)
+		if err != nil {
+			return err
+		}
+		term = nil
+		if first {
+			result = value
+			first = false
+			return nil
+		}
+		result, err = combineDateTimes(result, operator, value)
+		return err
+	}
+
+	for _, field := range fields {
+		if field == addOperator || field == subtractOperator {
+			if err := apply(); err != nil {
+				return dateTimeValue{}, err
+			}
+			operator = field
+			continue
+		}
+		term = append(term, field)
+	}
+	if err := apply(); err != nil {
+		return dateTimeValue{}, err
+	}
+	return result, nil
+}
+
+func combineDateTimes(a dateTimeValue, operator string, b dateTimeValue) (dateTimeValue, error) {
+	sign := 1
+	if operator == subtractOperator {
+		sign = -1
+	}
+	switch {
+	case a.isMoment && b.isMoment:
+		if sign > 0 {
+			return dateTimeValue{}, fmt.Errorf("two times cannot be added; subtract them to get a duration")
+		}
+		return dateTimeValue{exact: a.moment.Sub(b.moment)}, nil
+	case a.isMoment:
+		moment := a.moment.AddDate(0, 0, sign*b.days).Add(time.Duration(sign) * b.exact)
+		return dateTimeValue{isMoment: true, moment: moment}, checkDateRange(moment)
+	case b.isMoment:
+		if sign < 0 {
+			return dateTimeValue{}, fmt.Errorf("a time cannot be subtracted from a duration")
+		}
+		return combineDateTimes(b, addOperator, a)
+	default:
+		return dateTimeValue{days: a.days + sign*b.days, exact: a.exact + time.Duration(sign)*b.exact}, nil
+	}
+}
+
+func parseDateTimeTerm(fields []string) (dateTimeValue, error) {
+	if len(fields) == 1 {
+		if duration, ok, err := parseDuration(fields[0]); ok {
+			return duration, err
+		}
+	}
+
+	var date, clock, zone string
+	now := false
+	for _, field := range fields {
+		switch lower := strings.ToLower(field); {
+		case lower == "now":
+			now = true
+		case lower == "today":
+			date = lower
+		case dateRegex.MatchString(field):
+			date = field
+		case clockRegex.MatchString(field):
+			clock = field
+		case zone == "":
+			zone = field
+		default:
+			return dateTimeValue{}, fmt.Errorf("unexpected '%s' in date or time", field)
+		}
+	}
+
+	location := time.Local
+	if zone != "" {
+		var err error
+		if location, err = loadTimeZone(zone); err != nil {
+			return dateTimeValue{}, err
+		}
+	}
+	if now {
+		if date != "" || clock != "" {
+			return dateTimeValue{}, fmt.Errorf("'now' cannot be combined with a date or time")
+		}
+		return dateTimeValue{isMoment: true, moment: time.Now().In(location)}, nil
+	}
+	if date == "" && clock == "" {
+		return dateTimeValue{}, fmt.Errorf("expected a date, a time, or a duration")
+	}
+
+	year, month, day := time.Now().In(location).Date()
+	if date != "" && date != "today" {
+		parsed, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			return dateTimeValue{}, fmt.Errorf("invalid date: %s", date)
+		}
+		year, month, day = parsed.Date()
+	}
+	hour, minute, second := 0, 0, 0
+	if clock != "" {
+		parts := strings.Split(clock, ":")
+		hour, _ = strconv.Atoi(parts[0])
+		minute, _ = strconv.Atoi(parts[1])
+		if len(parts) == 3 {
+			second, _ = strconv.Atoi(parts[2])
+		}
+		if hour > 23 || minute > 59 || second > 59 {
+			return dateTimeValue{}, fmt.Errorf("invalid time: %s", clock)
+		}
+	}
+
+	return dateTimeValue{isMoment: true, moment: time.Date(year, month, day, hour, minute, second, 0, location)}, nil
+}
+
+// parseDuration reads durations such as 45min, 1h30m, or 2d. Whole days are
+// kept separately; fractional days are converted to hours. Durations too long
+// to move a date by are read with an error.
+func parseDuration(text string) (dateTimeValue, bool, error) {
+	matches := durationRegex.FindAllStringSubmatchIndex(strings.ToLower(text), -1)
+	if len(matches) == 0 {
+		return dateTimeValue{}, false, nil
+	}
+	var result dateTimeValue
+	position := 0
+	for _, match := range matches {
+		if match[0] != position {
+			return dateTimeValue{}, false, nil
+		}
+		position = match[1]
+		amount, _ := strconv.ParseFloat(text[match[2]:match[3]], 64)
+		unit := time.Second
+		switch strings.ToLower(text[match[4]:match[5]]) {
+		case "d", "day", "days":
+			if amount > maxShiftDays {
+				return dateTimeValue{}, true, fmt.Errorf("the duration %s is too long: %w", text, errDateRange)
+			}
+			whole := math.Trunc(amount)
+			result.days += int(whole)
+			amount, unit = amount-whole, 24*time.Hour
+		case "h", "hour", "hours":
+			unit = time.Hour
+		case "m", "min", "minute", "minutes":
+			unit = time.Minute
+		}
+		if amount*unit.Seconds() >= maxDurationSeconds/2 {
+			return dateTimeValue{}, true, fmt.Errorf("the duration %s is too long, write it in days", text)
+		}
+		result.exact += time.Duration(amount * float64(unit))
+	}
+	return result, position == len(text), nil
+}
+
+func loadTimeZone(name string) (*time.Location, error) {
+	switch strings.ToLower(name) {
+	case "local":
+		return time.Local, nil
+	case "utc", "gmt", "z":
+		return time.UTC, nil
+	}
+	location, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone: %s", name)
+	}
+	return location, nil
+}
+
+func formatMoment(moment time.Time) string {
+	formatted := moment.Format("Mon 2006-01-02 15:04:05 MST")
+	if name := moment.Location().String(); name != "Local" && name != moment.Format("MST") {
+		formatted += " (" + name + ")"
+	}
+	return formatted
+}
+
+func formatDuration(value dateTimeValue) string {
+	total := value.exact + time.Duration(value.days)*24*time.Hour
+	sign := ""
+	if total < 0 {
+		sign = "-"
+		total = -total
+	}
+	days := total / (24 * time.Hour)
+	total -= days * 24 * time.Hour
+	hours := total / time.Hour
+	total -= hours * time.Hour
+	minutes := total / time.Minute
+	total -= minutes * time.Minute
+	seconds := total / time.Second
+
+	var parts []string
+	if days > 0 {
+		parts = append(parts, fmt.Sprintf("%dd", days))
+	}
+	if hours > 0 {
+		parts = append(parts, fmt.Sprintf("%dh", hours))
+	}
+	if minutes > 0 {
+		parts = append(parts, fmt.Sprintf("%dm", minutes))
+	}
+	if seconds > 0 || len(parts) == 0 {
+		parts = append(parts, fmt.Sprintf("%ds", seconds))
+	}
+	return sign + strings.Join(parts, " ")
+}
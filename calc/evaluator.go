@@ -0,0 +1,483 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"strconv"
+)
+
+// evaluateNode evaluates a syntax tree.
+func (c *Calculator) evaluateNode(n node) (Value, error) {
+	if err := c.countStep(); err != nil {
+		return nil, err
+	}
+	c.countOperation(n)
+	switch n := n.(type) {
+	case numberNode:
+		return parseNumber(n.text)
+
+	case nameNode:
+		if value, ok := c.lookupValue(n.name); ok {
+			return value, nil
+		}
+		return c.lookupVariable(n.name)
+
+	case dateNode:
+		return parseMoment(n.text)
+
+	case listNode:
+		elements, err := c.evaluateArguments(n.elements)
+		if err != nil {
+			return nil, err
+		}
+		if m, ok := toMatrix(elements); ok {
+			return m, nil
+		}
+		return List(elements), nil
+
+	case indexNode:
+		value, err := c.evaluateNode(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		index, err := c.evaluateNode(n.index)
+		if err != nil {
+			return nil, err
+		}
+		return c.index(value, index)
+
+	case fieldNode:
+		value, err := c.evaluateNode(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return c.field(value, n.name)
+
+	case unaryNode:
+		value, err := c.evaluateNode(n.operand)
+		if err != nil {
+			return nil, err
+		}
+		return c.applyUnary(n.operator, value)
+
+	case letNode:
+		return c.evaluateLet(n)
+
+	case equationNode:
+		left, err := c.evaluateNode(n.left)
+		if err != nil {
+			return nil, err
+		}
+		right, err := c.evaluateNode(n.right)
+		if err != nil {
+			return nil, err
+		}
+		return c.applyBinary(subtractOperator, left, right)
+
+	case binaryNode:
+		if n.operator == andOperator || n.operator == orOperator {
+			return c.evaluateLogical(n)
+		}
+		a, err := c.evaluateNode(n.left)
+		if err != nil {
+			return nil, err
+		}
+		b, err := c.evaluateNode(n.right)
+		if err != nil {
+			return nil, err
+		}
+		if (n.operator == addOperator || n.operator == subtractOperator) && isPercentage(n.right) {
+			if b, err = c.applyBinary(multiplyOperator, b, a); err != nil {
+				return nil, err
+			}
+		}
+		return c.applyBinary(n.operator, a, b)
+
+	case callNode:
+		if n.name == ansName || n.name == ansShortName {
+			value, err := c.evaluateNode(n.args[0])
+			if err != nil {
+				return nil, err
+			}
+			index, ok := wholeNumber(value)
+			if !ok || !index.IsInt64() {
+				return nil, fmt.Errorf("%s(n) takes a whole number, not %s", n.name, c.FormatValue(value))
+			}
+			return c.answer(n.name, int(index.Int64()))
+		}
+		if n.name == conditionalName {
+			return c.evaluateConditional(n)
+		}
+		if n.name == piecewiseName {
+			return c.evaluatePieces(n)
+		}
+		if n.name == integrateName {
+			return c.evaluateIntegral(n)
+		}
+		if n.name == solveName {
+			return c.evaluateSolve(n)
+		}
+		if n.name == gpaName {
+			return c.evaluateGPA(n)
+		}
+		if n.name == proportionName {
+			return c.evaluateProportion(n)
+		}
+		if n.name == withinName || n.name == intolName {
+			return c.evaluateTolerance(n)
+		}
+		if n.name == addDaysName {
+			return c.evaluateAddDays(n)
+		}
+		if _, ok := c.Functions[n.name]; ok {
+			args, err := c.evaluateArguments(n.args)
+			if err != nil {
+				return nil, err
+			}
+			return c.callFunction(n.name, args)
+		}
+		if extension, ok := c.extensionFunction(n.name); ok {
+			args, err := c.evaluateArguments(n.args)
+			if err != nil {
+				return nil, err
+			}
+			return c.callExtension(extension, n.name, args)
+		}
+		if value, ok := c.lookupValue(n.name); ok && !c.isMeasure(n.name) {
+			args, err := c.evaluateArguments(n.args)
+			if err != nil {
+				return nil, err
+			}
+			return c.callValue(n.name, value, args)
+		}
+		values, err := c.evaluateArguments(n.args)
+		if err != nil {
+			return nil, err
+		}
+		if result, ok, err := c.callWithAngle(n.name, values); ok {
+			return result, err
+		}
+		if function, ok := builtins[n.name]; ok && function.maxArgs == variadic {
+			values = flattenLists(values)
+		} else if len(values) == 1 {
+			if l, ok := values[0].(List); ok {
+				return mapList(l, func(v Value) (Value, error) {
+					if result, ok, err := c.callWithAngle(n.name, []Value{v}); ok {
+						return result, err
+					}
+					return c.callReal(n.name, []Value{v})
+				})
+			}
+		}
+		return c.callReal(n.name, values)
+	}
+	return nil, fmt.Errorf("error evaluating expression")
+}
+
+// callReal calls the builtin or user function name with real arguments.
+func (c *Calculator) callReal(name string, values []Value) (Value, error) {
+	args := make([]float64, len(values))
+	for i, value := range values {
+		var err error
+		if args[i], err = realArgument(name, value); err != nil {
+			return nil, err
+		}
+	}
+	result, err := c.evaluateFunction(name, args)
+	return Float(result), err
+}
+
+// evaluateArguments evaluates the arguments of a call.
+func (c *Calculator) evaluateArguments(nodes []node) ([]Value, error) {
+	args := make([]Value, len(nodes))
+	for i, arg := range nodes {
+		value, err := c.evaluateNode(arg)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	return args, nil
+}
+
+// applyUnary applies the negation, ~, percent, or factorial operator to value.
+func (c *Calculator) applyUnary(operator string, value Value) (Value, error) {
+	if !isNumeric(value) {
+		return c.extensionUnary(operator, value)
+	}
+	if operator == bitNotOperator {
+		return c.bitwiseNot(value)
+	}
+	if operator == notOperator {
+		truth, err := operandTruth(operator, value)
+		return Bool(!truth), err
+	}
+	if operator == percentOperator {
+		return c.applyBinary(divideOperator, value, Int{big.NewInt(100)})
+	}
+	if operator == rootOperator {
+		return c.callReal("sqrt", []Value{value})
+	}
+	value = promote(value, intRank)
+	if operator == negateOperator {
+		switch v := value.(type) {
+		case Int:
+			return Int{new(big.Int).Neg(v.Int)}, nil
+		case Rational:
+			return Rational{new(big.Rat).Neg(v.Rat)}, nil
+		case Float:
+			return -v, nil
+		case Complex:
+			return -v, nil
+		}
+	}
+
+	if z, ok := value.(Complex); ok {
+		return nil, fmt.Errorf("%s! is not defined for complex numbers", z)
+	}
+	if n, ok := value.(Int); ok && n.Sign() >= 0 && n.IsInt64() {
+		if bits, _ := math.Lgamma(float64(n.Int64()) + 1); bits/math.Ln2 <= maxExactBits {
+			return normalize(Int{new(big.Int).MulRange(1, n.Int64())}), nil
+		}
+	}
+	result, err := c.factorial(toFloat(value))
+	return Float(result), err
+}
+
+// applyBinary applies a binary operator to a and b after promoting them to
+// the same kind. Int and Rational operands are calculated exactly where the
+// result is exact, and as Floats otherwise. Operands of an extension's kind
+// are left to the extension, and the bitwise operators work on whole numbers
+// alone.
+func (c *Calculator) applyBinary(operator string, a, b Value) (Value, error) {
+	if !isNumeric(a) || !isNumeric(b) {
+		return c.extensionBinary(operator, a, b)
+	}
+	if bitwiseOperators[operator] {
+		return c.bitwiseBinary(operator, a, b)
+	}
+	if isComparison(operator) {
+		return c.compareValues(operator, a, b)
+	}
+	if operator == convertOperator {
+		return nil, fmt.Errorf("in converts a quantity to other units, e.g. 60 mph in km/h")
+	}
+	if operator == rootOperator {
+		return c.callReal("root", []Value{b, a})
+	}
+	to := rank(a)
+	if rank(b) > to {
+		to = rank(b)
+	}
+	if to < intRank {
+		to = intRank
+	}
+	a, b = promote(a, to), promote(b, to)
+
+	switch to {
+	case complexRank:
+		return complexBinary(operator, complex128(a.(Complex)), complex128(b.(Complex)))
+	case intRank, rationalRank:
+		x, _ := exactRational(a)
+		y, _ := exactRational(b)
+		if result, ok := exactBinary(operator, x, y); ok {
+			return normalize(Rational{result}), nil
+		}
+	}
+
+	x, y := toFloat(a), toFloat(b)
+	switch operator {
+	case addOperator:
+		return Float(c.add(x, y)), nil
+	case subtractOperator:
+		return Float(c.subtract(x, y)), nil
+	case multiplyOperator, implicitMultiplyOperator, unitMultiplyOperator:
+		return Float(c.multiply(x, y)), nil
+	case divideOperator:
+		result, err := c.divide(x, y)
+		return Float(result), err
+	case floorDivOperator:
+		result, err := c.divide(x, y)
+		return Float(math.Floor(result)), err
+	case moduloOperator:
+		result, err := c.modulo(x, y)
+		return Float(result), err
+	case powerOperator:
+		return Float(c.power(x, y)), nil
+	default:
+		return nil, ErrInvalidOperator
+	}
+}
+
+// exactBinary applies a binary operator to two fractions, reporting false
+// when the result has no exact value or would be too large.
+func exactBinary(operator string, a, b *big.Rat) (*big.Rat, bool) {
+	result := new(big.Rat)
+	switch operator {
+	case addOperator:
+		result.Add(a, b)
+	case subtractOperator:
+		result.Sub(a, b)
+	case multiplyOperator, implicitMultiplyOperator, unitMultiplyOperator:
+		result.Mul(a, b)
+	case divideOperator:
+		if b.Sign() == 0 {
+			return nil, false
+		}
+		result.Quo(a, b)
+	case floorDivOperator, moduloOperator:
+		if b.Sign() == 0 {
+			return nil, false
+		}
+		quotient := new(big.Rat).Quo(a, b)
+		if operator == floorDivOperator {
+			result.SetInt(new(big.Int).Div(quotient.Num(), quotient.Denom()))
+		} else {
+			truncated := new(big.Rat).SetInt(new(big.Int).Quo(quotient.Num(), quotient.Denom()))
+			result.Sub(a, truncated.Mul(truncated, b))
+		}
+	case powerOperator:
+		return ratPower(a, b)
+	default:
+		return nil, false
+	}
+	return result, true
+}
+
+// complexBinary applies a binary operator to two complex numbers.
+func complexBinary(operator string, a, b complex128) (Value, error) {
+	var result complex128
+	switch operator {
+	case addOperator:
+		result = a + b
+	case subtractOperator:
+		result = a - b
+	case multiplyOperator, implicitMultiplyOperator, unitMultiplyOperator:
+		result = a * b
+	case divideOperator:
+		if b == 0 {
+			return nil, ErrDivideByZero
+		}
+		result = a / b
+	case powerOperator:
+		result = cmplx.Pow(a, b)
+	default:
+		return nil, fmt.Errorf("%s is not defined for complex numbers", displayToken(operator))
+	}
+	return normalize(Complex(result)), nil
+}
+
+// isPercentage reports whether n is a percentage such as 10% or -10%, which
+// after + or - is a percentage of the left operand.
+func isPercentage(n node) bool {
+	for {
+		unary, ok := n.(unaryNode)
+		if !ok || unary.operator == factorialOperator {
+			return false
+		}
+		if unary.operator == percentOperator {
+			return true
+		}
+		n = unary.operand
+	}
+}
+
+func (c *Calculator) isNumber(token string) bool {
+	_, err := strconv.ParseFloat(token, 64)
+	return err == nil
+}
+
+// isValueName reports whether token is a variable that holds something other
+// than a real number.
+func (c *Calculator) isValueName(token string) bool {
+	_, ok := c.lookupValue(token)
+	return ok && token != moduloOperator
+}
+
+func (c *Calculator) isOperator(token string) bool {
+	return isOperatorOrParen(token) && token != leftParen && token != rightParen
+}
+
+// isFunction reports whether token opens the arguments of a function call,
+// such as sqrt(.
+func (c *Calculator) isFunction(token string) bool {
+	name := identifierRegex.FindString(token)
+	rest := token[len(name):]
+	return name != "" && (rest == leftParen || rest == inverseSuffix+leftParen)
+}
+
+func (c *Calculator) evaluateFunction(name string, args []float64) (float64, error) {
+	if _, ok := c.Functions[name]; ok {
+		values := make([]Value, len(args))
+		for i, arg := range args {
+			values[i] = Float(arg)
+		}
+		result, err := c.callFunction(name, values)
+		if err != nil {
+			return 0, err
+		}
+		if !isReal(result) {
+			return 0, fmt.Errorf("%s gives %s, where a real number is needed", name, article(result.Kind()))
+		}
+		return toFloat(result), nil
+	}
+	if function, ok := builtins[name]; ok {
+		return c.callBuiltin(name, function, args)
+	}
+	if base, ok := inverseOf(name); ok {
+		return c.evaluateInverse(base, args)
+	}
+	if base, ok := logBase(name); ok {
+		return logarithm([]float64{args[0], base})
+	}
+	return 0, fmt.Errorf("unsupported function: %s", name)
+}
+
+func (c *Calculator) add(a, b float64) float64 {
+	return a + b
+}
+
+func (c *Calculator) subtract(a, b float64) float64 {
+	return a - b
+}
+
+func (c *Calculator) multiply(a, b float64) float64 {
+	return a * b
+}
+
+func (c *Calculator) divide(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivideByZero
+	}
+	return a / b, nil
+}
+
+func (c *Calculator) modulo(a, b float64) (float64, error) {
+	if b == 0 {
+		return 0, ErrDivideByZero
+	}
+	return math.Mod(a, b), nil
+}
+
+func (c *Calculator) power(a, b float64) float64 {
+	return math.Pow(a, b)
+}
+
+// factorial returns n! as a float64, which is +Inf from 171! on; applyUnary
+// calculates integer factorials exactly instead. Factorials too large for
+// that are an error.
+func (c *Calculator) factorial(n float64) (float64, error) {
+	if n < 0 || n != math.Trunc(n) {
+		return 0, fmt.Errorf("%w: %g! needs a non-negative integer, use gamma(x+1) for other values", ErrDomain, n)
+	}
+	if lgamma, _ := math.Lgamma(n + 1); lgamma/math.Ln2 > maxExactBits {
+		return 0, fmt.Errorf("%g! is too large to calculate", n)
+	}
+	result := 1.0
+	for i := 2.0; i <= n && !math.IsInf(result, 0); i++ {
+		result *= i
+	}
+	return result, nil
+}
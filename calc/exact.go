@@ -0,0 +1,71 @@
+package calc
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+const (
+	// maxSafeInteger is the largest magnitude below which every integer is
+	// exactly representable as a float64.
+	maxSafeInteger = 1 << 53
+	// maxExactBits bounds the size of exact results, so that 9^9^9 falls back
+	// to floating point instead of exhausting memory.
+	maxExactBits = 1 << 17
+	// maxExactDigits is the number of significant digits up to which a decimal
+	// number is taken at face value. Longer ones such as 0.3333333333333333
+	// come from floating point results and are read back as Floats.
+	maxExactDigits = 15
+)
+
+// ratPower raises a to an integer power b.
+func ratPower(a, b *big.Rat) (*big.Rat, bool) {
+	if !b.IsInt() || !b.Num().IsInt64() {
+		return nil, false
+	}
+	exponent := b.Num().Int64()
+	negative := exponent < 0
+	if negative {
+		exponent = -exponent
+		if a.Sign() == 0 {
+			return nil, false
+		}
+	}
+	if exponent*int64(a.Num().BitLen()+a.Denom().BitLen()) > maxExactBits {
+		return nil, false
+	}
+	power := big.NewInt(exponent)
+	result := new(big.Rat).SetFrac(new(big.Int).Exp(a.Num(), power, nil), new(big.Int).Exp(a.Denom(), power, nil))
+	if negative {
+		result.Inv(result)
+	}
+	return result, true
+}
+
+func significantDigits(token string) int {
+	if i := strings.IndexAny(token, "eE"); i >= 0 {
+		token = token[:i]
+	}
+	digits := strings.TrimLeft(strings.NewReplacer("-", "", "+", "", ".", "").Replace(token), "0")
+	return len(digits)
+}
+
+// formatRational renders an exact fraction as a whole number or mixed fraction
+// such as 4 1/4.
+func formatRational(value *big.Rat) string {
+	sign := ""
+	if value.Sign() < 0 {
+		sign = "-"
+	}
+	numerator := new(big.Int).Abs(value.Num())
+	whole, remainder := new(big.Int).QuoRem(numerator, value.Denom(), new(big.Int))
+	switch {
+	case remainder.Sign() == 0:
+		return sign + whole.String()
+	case whole.Sign() == 0:
+		return fmt.Sprintf("%s%s/%s", sign, remainder, value.Denom())
+	default:
+		return fmt.Sprintf("%s%s %s/%s", sign, whole, remainder, value.Denom())
+	}
+}
@@ -0,0 +1,171 @@
+package calc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Extension adds a kind of value, such as quaternions or money, to a
+// Calculator: the functions that create and work with its values and the
+// operators that apply to them. Values of the kind implement Value, and their
+// String method is how they are shown.
+type Extension struct {
+	// Name identifies the extension in error messages.
+	Name string
+	// Functions are called with their arguments as values, as in quat(1, 0, 1, 0).
+	Functions map[string]func(args []Value) (Value, error)
+	// Constants are the names the extension gives values, such as the units
+	// i, j, and k of quaternions, as in 1 + 2j. A variable of the same name
+	// takes the place of a constant.
+	Constants map[string]Value
+	// Inverses name the extension's functions that are the inverses of
+	// builtins, such as acosh of cosh, so that cosh^-1 gives its principal
+	// value rather than a value found by search.
+	Inverses map[string]string
+	// Binary applies a binary operator, one of + - * / // % and ^ or a
+	// comparison such as ==, to two values of which at least one is not a
+	// number. It reports false for operands it does not handle.
+	Binary func(operator string, a, b Value) (Value, bool, error)
+	// Unary applies the negation -, percent %, or factorial ! operator to a
+	// value that is not a number. It reports false for operands it does not
+	// handle.
+	Unary func(operator string, a Value) (Value, bool, error)
+	// Call calls a value of the extension's kind that is stored in a
+	// variable, as in p(4). It reports false for values it does not handle.
+	Call func(v Value, args []Value) (Value, bool, error)
+}
+
+// Register adds an extension to the calculator. Its function names must not
+// already mean something in the input.
+func (c *Calculator) Register(extension Extension) error {
+	for name := range extension.Functions {
+		if identifierRegex.FindString(name) != name {
+			return fmt.Errorf("cannot register '%s', it is not a valid function name", name)
+		}
+		if isReserved(name) || name == ansName || name == ansShortName {
+			return fmt.Errorf("cannot register '%s', it is a built-in name", name)
+		}
+		if _, ok := c.Functions[name]; ok {
+			return fmt.Errorf("cannot register '%s', it is a defined function", name)
+		}
+		if other, ok := c.extensionFunction(name); ok {
+			return fmt.Errorf("cannot register '%s', it is a function of the %s extension", name, other.Name)
+		}
+	}
+	for name := range extension.Constants {
+		if identifierRegex.FindString(name) != name {
+			return fmt.Errorf("cannot register '%s', it is not a valid name", name)
+		}
+		_, isConstant := constants[name]
+		if _, ok := c.extensionConstant(name); ok || isConstant || isReserved(name) {
+			return fmt.Errorf("cannot register '%s', it is already a constant", name)
+		}
+	}
+	for name, inverse := range extension.Inverses {
+		if _, ok := builtins[name]; !ok {
+			return fmt.Errorf("cannot register an inverse of '%s', it is not a built-in function", name)
+		}
+		if _, ok := extension.Functions[inverse]; !ok {
+			return fmt.Errorf("cannot register '%s' as the inverse of '%s', it is not a function of the extension", inverse, name)
+		}
+	}
+	c.extensions = append(c.extensions, extension)
+	c.packs = append(c.packs, extension.Name)
+	return nil
+}
+
+// extensionConstant returns the value of the extension constant name.
+func (c *Calculator) extensionConstant(name string) (Value, bool) {
+	for _, extension := range c.extensions {
+		if value, ok := extension.Constants[name]; ok {
+			return value, true
+		}
+	}
+	return nil, false
+}
+
+// inverseFunction returns the function that is the inverse of the builtin
+// name, such as asin of sin, if there is one.
+func (c *Calculator) inverseFunction(name string) (string, bool) {
+	if inverse, ok := inverses[name]; ok {
+		return inverse, true
+	}
+	for _, extension := range c.extensions {
+		if inverse, ok := extension.Inverses[name]; ok {
+			return inverse, true
+		}
+	}
+	return "", false
+}
+
+// extensionFunction returns the extension that provides the function name.
+func (c *Calculator) extensionFunction(name string) (Extension, bool) {
+	for _, extension := range c.extensions {
+		if _, ok := extension.Functions[name]; ok {
+			return extension, true
+		}
+	}
+	return Extension{}, false
+}
+
+// callExtension calls the extension function name with the values of its arguments.
+func (c *Calculator) callExtension(extension Extension, name string, args []Value) (Value, error) {
+	result, err := extension.Functions[name](args)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return result, nil
+}
+
+// extensionBinary applies a binary operator to operands of which at least one
+// is of an extension's kind.
+func (c *Calculator) extensionBinary(operator string, a, b Value) (Value, error) {
+	operator = displayToken(operator)
+	for _, extension := range c.extensions {
+		if extension.Binary == nil {
+			continue
+		}
+		if result, ok, err := extension.Binary(operator, a, b); ok {
+			return result, err
+		}
+	}
+	return nil, fmt.Errorf("%s is not defined between %s and %s", operator, article(a.Kind()), article(b.Kind()))
+}
+
+// extensionUnary applies a unary operator to a value of an extension's kind.
+func (c *Calculator) extensionUnary(operator string, value Value) (Value, error) {
+	operator = displayToken(operator)
+	for _, extension := range c.extensions {
+		if extension.Unary == nil {
+			continue
+		}
+		if result, ok, err := extension.Unary(operator, value); ok {
+			return result, err
+		}
+	}
+	return nil, fmt.Errorf("%s is not defined for %s", operator, article(value.Kind()))
+}
+
+// callValue calls the value of the variable name with args.
+func (c *Calculator) callValue(name string, value Value, args []Value) (Value, error) {
+	for _, extension := range c.extensions {
+		if extension.Call == nil {
+			continue
+		}
+		if result, ok, err := extension.Call(value, args); ok {
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("%s is %s and cannot be called", name, article(value.Kind()))
+}
+
+// article prefixes a kind with a or an.
+func article(kind string) string {
+	if strings.ContainsAny(kind[:1], "aeiou") {
+		return "an " + kind
+	}
+	return "a " + kind
+}
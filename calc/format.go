@@ -0,0 +1,201 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// fractionMaxDenominator bounds the denominators shown in fraction mode.
+	fractionMaxDenominator = 100000
+	// defaultDecimals is the number of decimal places results are shown with.
+	defaultDecimals = 6
+)
+
+// Notation is how decimal results are written.
+type Notation int
+
+const (
+	// FixedNotation writes Decimals decimal places, as in 1234.500000.
+	FixedNotation Notation = iota
+	// ScientificNotation writes a mantissa with Decimals decimal places and an
+	// exponent, as in 1.234500e+03.
+	ScientificNotation
+	// ShortestNotation writes the fewest digits that read back as the same
+	// number, as in 1234.5, switching to an exponent for very large and very
+	// small numbers.
+	ShortestNotation
+)
+
+func (n Notation) String() string {
+	switch n {
+	case ScientificNotation:
+		return "scientific"
+	case ShortestNotation:
+		return "shortest"
+	}
+	return "fixed"
+}
+
+// ParseNotation returns the notation with the given name: fixed, scientific
+// (or sci), or shortest.
+func ParseNotation(name string) (Notation, bool) {
+	switch strings.ToLower(name) {
+	case "fixed":
+		return FixedNotation, true
+	case "scientific", "sci":
+		return ScientificNotation, true
+	case "shortest":
+		return ShortestNotation, true
+	}
+	return FixedNotation, false
+}
+
+// Option is a setting of a Calculator created with New.
+type Option func(*Calculator)
+
+// WithDecimals shows decimal results with the given number of decimal places.
+func WithDecimals(places int) Option {
+	return func(c *Calculator) { c.Decimals = places }
+}
+
+// WithNotation writes decimal results in the given notation.
+func WithNotation(notation Notation) Option {
+	return func(c *Calculator) { c.Notation = notation }
+}
+
+// WithTrimZeros drops the trailing zeros of decimal results.
+func WithTrimZeros() Option {
+	return func(c *Calculator) { c.TrimZeros = true }
+}
+
+// WithGrouping separates the thousands of results with commas.
+func WithGrouping() Option {
+	return func(c *Calculator) { c.Grouping = true }
+}
+
+// Format formats a number the way EvaluateInput displays results, as a
+// fraction in fraction mode and otherwise as a decimal in the notation and
+// with the decimal places, trimming, and grouping of the settings.
+func (c *Calculator) Format(result float64) string {
+	if c.FractionMode {
+		if fraction, ok := formatMixedFraction(result, fractionMaxDenominator); ok {
+			return fraction
+		}
+	}
+	var text string
+	switch {
+	case c.Notation == ScientificNotation:
+		text = strconv.FormatFloat(result, 'e', c.Decimals, 64)
+	case c.Notation == ShortestNotation && result != 0 && (math.Abs(result) < 1e-4 || math.Abs(result) >= 1e21):
+		text = strconv.FormatFloat(result, 'e', -1, 64)
+	case c.Notation == ShortestNotation:
+		text = strconv.FormatFloat(result, 'f', -1, 64)
+	default:
+		text = fmt.Sprintf("%.*f", c.Decimals, result)
+	}
+	if c.TrimZeros {
+		text = trimZeros(text)
+	}
+	if c.Grouping {
+		text = groupThousands(text)
+	}
+	return text
+}
+
+// trimZeros drops the trailing zeros after the decimal point of a number,
+// and the point itself when nothing follows it.
+func trimZeros(text string) string {
+	mantissa, exponent := text, ""
+	if i := strings.IndexByte(text, 'e'); i >= 0 {
+		mantissa, exponent = text[:i], text[i:]
+	}
+	if strings.Contains(mantissa, ".") {
+		mantissa = strings.TrimRight(strings.TrimRight(mantissa, "0"), ".")
+	}
+	return mantissa + exponent
+}
+
+// groupThousands separates the thousands of the whole part of a number with
+// commas, as in 1,234,567.5.
+func groupThousands(text string) string {
+	start := 0
+	if strings.HasPrefix(text, "-") {
+		start = 1
+	}
+	end := start
+	for end < len(text) && text[end] >= '0' && text[end] <= '9' {
+		end++
+	}
+	digits := text[start:end]
+	if len(digits) <= 3 {
+		return text
+	}
+	var grouped strings.Builder
+	for i, digit := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(digit)
+	}
+	return text[:start] + grouped.String() + text[end:]
+}
+
+// formatMixedFraction renders value as a whole number or mixed fraction such as
+// 4 1/4. It reports false when no fraction with a denominator up to
+// maxDenominator represents the value closely enough.
+func formatMixedFraction(value float64, maxDenominator int64) (string, bool) {
+	if math.IsNaN(value) || math.IsInf(value, 0) || math.Abs(value) >= 1<<53 {
+		return "", false
+	}
+	numerator, denominator := rationalApproximation(math.Abs(value), maxDenominator)
+	if math.Abs(float64(numerator)/float64(denominator)-math.Abs(value)) > 1e-12*math.Max(1, math.Abs(value)) {
+		return "", false
+	}
+
+	sign := ""
+	if value < 0 && numerator != 0 {
+		sign = "-"
+	}
+	whole, remainder := numerator/denominator, numerator%denominator
+	switch {
+	case remainder == 0:
+		return fmt.Sprintf("%s%d", sign, whole), true
+	case whole == 0:
+		return fmt.Sprintf("%s%d/%d", sign, remainder, denominator), true
+	default:
+		return fmt.Sprintf("%s%d %d/%d", sign, whole, remainder, denominator), true
+	}
+}
+
+// rationalApproximation returns the continued-fraction convergent of a
+// non-negative value with the largest denominator not exceeding maxDenominator.
+func rationalApproximation(value float64, maxDenominator int64) (int64, int64) {
+	var previousNumerator, numerator int64 = 0, 1
+	var previousDenominator, denominator int64 = 1, 0
+	remaining := value
+	for {
+		term := math.Floor(remaining)
+		if term > float64(math.MaxInt64/2) {
+			break
+		}
+		a := int64(term)
+		nextDenominator := a*denominator + previousDenominator
+		if nextDenominator > maxDenominator {
+			break
+		}
+		previousNumerator, numerator = numerator, a*numerator+previousNumerator
+		previousDenominator, denominator = denominator, nextDenominator
+		fraction := remaining - term
+		if fraction < 1e-12 {
+			break
+		}
+		remaining = 1 / fraction
+	}
+	if denominator == 0 {
+		return int64(math.Round(value)), 1
+	}
+	return numerator, denominator
+}
@@ -0,0 +1,136 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+)
+
+const (
+	// maxContinuedFractionTerms bounds the terms cfrac can be asked for.
+	maxContinuedFractionTerms = 100
+	// defaultApproximationTolerance is the tolerance of approx_frac when none
+	// is given.
+	defaultApproximationTolerance = 1e-6
+)
+
+// continuedFraction gives cfrac(x, terms): the list of the first terms of the
+// continued fraction of x, as [3, 7, 15, 1, 292] for cfrac(pi, 5).
+func (c *Calculator) continuedFraction(args []Value) (Value, error) {
+	if len(args) != 2 {
+		return nil, fmt.Errorf("expected 2 arguments: cfrac(x, terms), e.g. cfrac(pi, 5)")
+	}
+	x, err := exactArgument("cfrac", args[0])
+	if err != nil {
+		return nil, err
+	}
+	count, ok := wholeNumber(args[1])
+	if !ok || count.Sign() < 1 || count.Cmp(big.NewInt(maxContinuedFractionTerms)) > 0 {
+		return nil, fmt.Errorf("takes a whole number of terms from 1 to %d", maxContinuedFractionTerms)
+	}
+
+	var terms List
+	remaining := new(big.Rat).Set(x)
+	for len(terms) < int(count.Int64()) {
+		term := floorRat(remaining)
+		terms = append(terms, Int{term})
+		remaining.Sub(remaining, new(big.Rat).SetInt(term))
+		if remaining.Sign() == 0 {
+			break
+		}
+		remaining.Inv(remaining)
+	}
+	return terms, nil
+}
+
+// approximateFraction gives approx_frac(x[, tolerance]): the fraction with the
+// smallest denominator within tolerance of x, exact so that fraction mode
+// shows it as one.
+func (c *Calculator) approximateFraction(args []Value) (Value, error) {
+	if len(args) != 1 && len(args) != 2 {
+		return nil, fmt.Errorf("expected 1 or 2 arguments: approx_frac(x, tolerance), e.g. approx_frac(0.333333, 1e-6)")
+	}
+	x, err := exactArgument("approx_frac", args[0])
+	if err != nil {
+		return nil, err
+	}
+	tolerance := defaultApproximationTolerance
+	if len(args) == 2 {
+		if tolerance, err = realArgument("approx_frac", args[1]); err != nil {
+			return nil, err
+		}
+		if !(tolerance > 0) || math.IsInf(tolerance, 0) {
+			return nil, fmt.Errorf("the tolerance must be a positive number")
+		}
+	}
+	return normalize(Rational{bestApproximation(x, new(big.Rat).SetFloat64(tolerance))}), nil
+}
+
+// exactArgument returns the exact fraction of the value of an argument of
+// name.
+func exactArgument(name string, value Value) (*big.Rat, error) {
+	if x, ok := exactRational(value); ok {
+		return x, nil
+	}
+	f, err := realArgument(name, value)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return nil, fmt.Errorf("takes a finite number")
+	}
+	return new(big.Rat).SetFloat64(f), nil
+}
+
+// bestApproximation returns the fraction with the smallest denominator that
+// is within tolerance of x. It walks the convergents of the continued
+// fraction of x, trying the semiconvergents before each one, since the best
+// approximations are among them.
+func bestApproximation(x, tolerance *big.Rat) *big.Rat {
+	within := func(numerator, denominator *big.Int) bool {
+		difference := new(big.Rat).Sub(x, new(big.Rat).SetFrac(numerator, denominator))
+		return difference.Abs(difference).Cmp(tolerance) <= 0
+	}
+	semiconvergent := func(t, h, previousH, k, previousK *big.Int) (*big.Int, *big.Int) {
+		numerator := new(big.Int).Add(new(big.Int).Mul(t, h), previousH)
+		denominator := new(big.Int).Add(new(big.Int).Mul(t, k), previousK)
+		return numerator, denominator
+	}
+
+	previousH, h := big.NewInt(0), big.NewInt(1)
+	previousK, k := big.NewInt(1), big.NewInt(0)
+	remaining := new(big.Rat).Set(x)
+	for {
+		a := floorRat(remaining)
+		if k.Sign() > 0 {
+			// The semiconvergents with t from 1 to a move steadily towards x,
+			// so the first one within tolerance is found by bisection.
+			low, high := big.NewInt(1), new(big.Int).Set(a)
+			if numerator, denominator := semiconvergent(high, h, previousH, k, previousK); within(numerator, denominator) {
+				for low.Cmp(high) < 0 {
+					middle := new(big.Int).Rsh(new(big.Int).Add(low, high), 1)
+					if numerator, denominator := semiconvergent(middle, h, previousH, k, previousK); within(numerator, denominator) {
+						high = middle
+					} else {
+						low = middle.Add(middle, big.NewInt(1))
+					}
+				}
+				numerator, denominator := semiconvergent(low, h, previousH, k, previousK)
+				return new(big.Rat).SetFrac(numerator, denominator)
+			}
+		}
+		previousH, h = h, new(big.Int).Add(new(big.Int).Mul(a, h), previousH)
+		previousK, k = k, new(big.Int).Add(new(big.Int).Mul(a, k), previousK)
+		remaining.Sub(remaining, new(big.Rat).SetInt(a))
+		if remaining.Sign() == 0 || within(h, k) {
+			return new(big.Rat).SetFrac(h, k)
+		}
+		remaining.Inv(remaining)
+	}
+}
+
+// floorRat returns the largest integer not above x. Euclidean division by the
+// positive denominator rounds down.
+func floorRat(x *big.Rat) *big.Int {
+	return new(big.Int).Div(x.Num(), x.Denom())
+}
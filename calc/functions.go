@@ -0,0 +1,426 @@
+package calc
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// captureKeyword starts a definition whose function keeps the values the
+	// session variables had when it was defined, as in capture f(x) = x*rate.
+	captureKeyword = "capture"
+	// memoKeyword starts a definition whose function remembers its results, as
+	// in memo ways(n) = ...
+	memoKeyword = "memo"
+
+	// maxCallDepth bounds how deeply user functions may call each other, so
+	// that runaway recursion ends in an error instead of a crash.
+	maxCallDepth = 1000
+)
+
+var (
+	definitionRegex = regexp.MustCompile(`^\s*=\s*([^=].*)$`)
+	freeNameRegex   = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*(::[A-Za-z_][A-Za-z0-9_]*|\()?`)
+)
+
+// Function is a function defined by the user, such as f(x) = x^2 + 1 or
+// f(x, k=2) = k*x^2. Defaults holds the default value expression of each
+// parameter, or "" for a parameter that must be passed.
+//
+// A function reads the session variables it uses when it is called, so it
+// sees later changes to them, but never the parameters or let bindings of its
+// caller. A function defined with the capture keyword instead keeps the values
+// those variables had at definition in Captured.
+//
+// A Memo function remembers the result for each list of arguments until a
+// variable or function changes, which makes recursive definitions such as
+// ways(n) fast. A Sandboxed function comes from a formula pack and has a
+// limited number of steps per call.
+type Function struct {
+	Params    []string
+	Defaults  []string
+	Body      string
+	Captured  map[string]Value
+	Memo      bool
+	Sandboxed bool
+
+	cache    map[string]Value
+	compiled *compiledFunction
+}
+
+// compiledFunction holds the syntax trees of the default values and the body
+// of a function, which calls evaluate rather than reading the text again, and
+// the settings that change how they parse as they were when compiled.
+type compiledFunction struct {
+	defaults []node
+	body     node
+	tight    bool
+	fraction bool
+}
+
+// definition is a parsed function definition statement.
+type definition struct {
+	name    string
+	params  []string
+	body    string
+	capture bool
+	memo    bool
+	// sandboxed marks a definition loaded from a formula pack.
+	sandboxed bool
+}
+
+func (f Function) String() string {
+	return f.signature() + " = " + f.Body
+}
+
+// Definition returns a statement that defines the function again. The values
+// of captured variables are written out as let expressions, so the statement
+// does not depend on the variables at the time it is replayed.
+func (f Function) Definition(name string) string {
+	prefix := ""
+	if f.Memo {
+		prefix = memoKeyword + " "
+	}
+	if len(f.Captured) == 0 {
+		return prefix + name + f.String()
+	}
+	expanded := Function{Params: f.Params, Defaults: make([]string, len(f.Defaults)), Body: f.bindCaptured(f.Body)}
+	for i, value := range f.Defaults {
+		if value != "" {
+			expanded.Defaults[i] = f.bindCaptured(value)
+		}
+	}
+	return prefix + name + expanded.String()
+}
+
+// bindCaptured wraps expression in a let binding for each captured variable it
+// uses.
+func (f Function) bindCaptured(expression string) string {
+	used := map[string]bool{}
+	for _, match := range freeNameRegex.FindAllStringSubmatch(expression, -1) {
+		if _, ok := f.Captured[match[0]]; ok && match[1] == "" {
+			used[match[0]] = true
+		}
+	}
+	names := make([]string, 0, len(used))
+	for name := range used {
+		names = append(names, name)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		expression = fmt.Sprintf("let %s = %s in %s", name, valueExpression(f.Captured[name]), expression)
+	}
+	return expression
+}
+
+// signature is the parameter list of the function, such as (x, k=2).
+func (f Function) signature() string {
+	params := make([]string, len(f.Params))
+	for i, param := range f.Params {
+		params[i] = param
+		if f.Defaults[i] != "" {
+			params[i] += "=" + f.Defaults[i]
+		}
+	}
+	return "(" + strings.Join(params, ", ") + ")"
+}
+
+// Required is the number of parameters without a default value.
+func (f Function) Required() int {
+	required := 0
+	for required < len(f.Defaults) && f.Defaults[required] == "" {
+		required++
+	}
+	return required
+}
+
+// accepts reports whether the function can be called with count arguments.
+func (f Function) accepts(count int) bool {
+	return count >= f.Required() && count <= len(f.Params)
+}
+
+// parseFunctionDefinition splits a statement such as g(a, b=2) = a*b - 2 into
+// the function name, its parameter list, and its body, along with the capture
+// and memo keywords it starts with.
+func parseFunctionDefinition(input string) (definition, bool) {
+	var def definition
+	for {
+		fields := strings.Fields(input)
+		if len(fields) < 2 || fields[0] != captureKeyword && fields[0] != memoKeyword {
+			break
+		}
+		def.capture = def.capture || fields[0] == captureKeyword
+		def.memo = def.memo || fields[0] == memoKeyword
+		input = strings.TrimSpace(input[len(fields[0]):])
+	}
+	def.name = identifierRegex.FindString(input)
+	if def.name == "" || !strings.HasPrefix(input[len(def.name):], leftParen) {
+		return definition{}, false
+	}
+	end, ok := closingParen(input, len(def.name)+1)
+	if !ok {
+		return definition{}, false
+	}
+	match := definitionRegex.FindStringSubmatch(input[end:])
+	if match == nil {
+		return definition{}, false
+	}
+	if list := strings.TrimSpace(input[len(def.name)+1 : end-1]); list != "" {
+		def.params = splitArguments(list)
+	}
+	def.body = strings.TrimSpace(match[1])
+	return def, true
+}
+
+// defineFunction adds a function, or replaces the one with the same number of
+// parameters. Functions with the same name are overloads told apart by the
+// number of arguments of a call, so their argument counts may not overlap.
+// A function with the name of an extension's function is called in its place.
+func (c *Calculator) defineFunction(def definition) (Result, error) {
+	name := def.name
+	if isReserved(name) || name == ansName || name == ansShortName {
+		return Result{}, fmt.Errorf("cannot define '%s', it is a built-in name", name)
+	}
+	if _, ok := c.Variables[name]; ok {
+		return Result{}, fmt.Errorf("cannot define '%s', it is a variable", name)
+	}
+	function, err := parseParams(def.params)
+	if err != nil {
+		return Result{}, err
+	}
+	function.Body = def.body
+	if def.capture {
+		function.Captured = c.captureVariables(function)
+	}
+	if def.memo {
+		function.Memo = true
+		function.cache = map[string]Value{}
+	}
+	function.Sandboxed = def.sandboxed
+	function.compiled = &compiledFunction{}
+
+	var overloads []Function
+	for _, overload := range c.Functions[name] {
+		if len(overload.Params) == len(function.Params) {
+			continue
+		}
+		for count := function.Required(); count <= len(function.Params); count++ {
+			if overload.accepts(count) {
+				return Result{}, fmt.Errorf("%s%s would be ambiguous with %s%s for %s", name, function.signature(), name, overload.signature(), pluralize(count, "argument"))
+			}
+		}
+		overloads = append(overloads, overload)
+	}
+	overloads = append(overloads, function)
+	sort.Slice(overloads, func(i, j int) bool { return len(overloads[i].Params) < len(overloads[j].Params) })
+
+	if c.Functions == nil {
+		c.Functions = map[string][]Function{}
+	}
+	previous, existed := c.Functions[name]
+	c.Functions[name] = overloads
+	compiled, err := c.compileFunction(function)
+	if err != nil {
+		if existed {
+			c.Functions[name] = previous
+		} else {
+			delete(c.Functions, name)
+		}
+		return Result{}, err
+	}
+	*function.compiled = *compiled
+	c.clearMemos()
+
+	text := name + function.String()
+	if def.memo {
+		text = memoKeyword + " " + text
+	}
+	if def.capture {
+		text = captureKeyword + " " + text
+	}
+	return Result{Text: text}, nil
+}
+
+// clearMemos forgets the remembered results of all memo functions, as they may
+// depend on variables or functions that changed.
+func (c *Calculator) clearMemos() {
+	for _, overloads := range c.Functions {
+		for _, function := range overloads {
+			for key := range function.cache {
+				delete(function.cache, key)
+			}
+		}
+	}
+}
+
+// captureVariables returns the current values of the session variables that
+// the default values and body of function use.
+func (c *Calculator) captureVariables(function Function) map[string]Value {
+	params := map[string]bool{}
+	for _, param := range function.Params {
+		params[param] = true
+	}
+	captured := map[string]Value{}
+	text := strings.Join(function.Defaults, " ") + " " + function.Body
+	for _, match := range freeNameRegex.FindAllStringSubmatch(text, -1) {
+		name := match[0]
+		if match[1] != "" || params[name] {
+			continue
+		}
+		if value, ok := c.Variables[name]; ok {
+			captured[name] = value
+		}
+	}
+	return captured
+}
+
+// parseParams reads a parameter list such as x, k=2. Parameters with a default
+// value must come after those without one.
+func parseParams(params []string) (Function, error) {
+	var function Function
+	seen := map[string]bool{}
+	for _, param := range params {
+		name, value := param, ""
+		if assignedName, assignedValue, ok := parseAssignment(param); ok {
+			name, value = assignedName, assignedValue
+		}
+		if identifierRegex.FindString(name) != name {
+			return Function{}, fmt.Errorf("invalid parameter: %s", param)
+		}
+		if seen[name] {
+			return Function{}, fmt.Errorf("parameter '%s' appears more than once", name)
+		}
+		if value == "" && len(function.Defaults) > 0 && function.Defaults[len(function.Defaults)-1] != "" {
+			return Function{}, fmt.Errorf("parameter '%s' needs a default value, as it follows one that has one", name)
+		}
+		seen[name] = true
+		function.Params = append(function.Params, name)
+		function.Defaults = append(function.Defaults, value)
+	}
+	return function, nil
+}
+
+// compileFunction compiles the default values and the body of function with
+// its parameters bound, so syntax errors and unknown names are reported when
+// the function is defined rather than when it is called.
+func (c *Calculator) compileFunction(function Function) (*compiledFunction, error) {
+	answers := c.answers
+	if len(answers) == 0 {
+		c.answers = placeholders(1)
+	}
+	defer func() { c.answers = answers }()
+	compiled := &compiledFunction{defaults: make([]node, len(function.Defaults)), tight: c.ImplicitTight, fraction: c.FractionMode}
+	for i, value := range function.Defaults {
+		if value == "" {
+			continue
+		}
+		err := c.withBindings(function.Params[:i], placeholders(i), func() error {
+			var err error
+			compiled.defaults[i], err = c.compileExpression(value)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	err := c.withBindings(function.Params, placeholders(len(function.Params)), func() error {
+		var err error
+		compiled.body, err = c.compileExpression(function.Body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return compiled, nil
+}
+
+// resolveOverload picks the overload of name that takes count arguments.
+func (c *Calculator) resolveOverload(name string, count int) (Function, error) {
+	var arities []string
+	for _, overload := range c.Functions[name] {
+		if overload.accepts(count) {
+			return overload, nil
+		}
+		arity := fmt.Sprint(overload.Required())
+		if len(overload.Params) > overload.Required() {
+			arity += fmt.Sprintf("-%d", len(overload.Params))
+		}
+		arities = append(arities, arity)
+	}
+	return Function{}, fmt.Errorf("%s does not take %s, it takes %s", name, pluralize(count, "argument"), strings.Join(arities, " or "))
+}
+
+// callFunction evaluates the body of the overload of name that takes the
+// arguments of a call, with the parameters bound to them. Default values are
+// evaluated at call time and can use the parameters before them. The body is
+// compiled again only when a setting that changes how it parses has changed
+// since it was.
+func (c *Calculator) callFunction(name string, values []Value) (Value, error) {
+	function, err := c.resolveOverload(name, len(values))
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, len(values))
+	for i, value := range values {
+		keys[i] = value.Kind() + " " + value.String()
+	}
+	key := strings.Join(keys, ",")
+	if result, ok := function.cache[key]; ok {
+		return result, nil
+	}
+	if c.callDepth >= maxCallDepth {
+		return nil, fmt.Errorf("%s: calls nested more than %d deep, check for recursion that never ends", name, maxCallDepth)
+	}
+	c.callDepth++
+	defer func() { c.callDepth-- }()
+	if function.Sandboxed && c.sandbox == "" {
+		c.sandbox, c.stepsLeft = name, maxPackSteps
+		defer func() { c.sandbox = "" }()
+	}
+
+	scope := c.Variables
+	if c.session != nil {
+		scope = c.session
+	}
+	if function.Captured != nil {
+		merged := make(map[string]Value, len(scope)+len(function.Captured))
+		for name, value := range scope {
+			merged[name] = value
+		}
+		for name, value := range function.Captured {
+			merged[name] = value
+		}
+		scope = merged
+	}
+
+	var result Value
+	err = c.withScope(scope, function.Params[:len(values)], values, func() error {
+		compiled := function.compiled
+		if compiled == nil || compiled.tight != c.ImplicitTight || compiled.fraction != c.FractionMode {
+			recompiled, err := c.compileFunction(function)
+			if err != nil {
+				return err
+			}
+			if compiled != nil {
+				*compiled = *recompiled
+			}
+			compiled = recompiled
+		}
+		for i := len(values); i < len(function.Params); i++ {
+			value, err := c.evaluateNode(compiled.defaults[i])
+			if err != nil {
+				return err
+			}
+			c.Variables[function.Params[i]] = value
+		}
+		var err error
+		result, err = c.evaluateNode(compiled.body)
+		return err
+	})
+	if err == nil && function.Memo {
+		function.cache[key] = result
+	}
+	return result, err
+}
@@ -0,0 +1,69 @@
+package calc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// grouping is a part of an expression that could be read another way, such as
+// a/b*c, given by the token ranges that parentheses enclose in the reading
+// used, (a/b)*c, and in the other one, a/(b*c).
+type grouping struct {
+	grouped, other [2]int
+}
+
+// enclosed reports whether the tokens from start to end are a single
+// parenthesized group.
+func (p *parser) enclosed(start, end int) bool {
+	if p.tokens[start] != leftParen {
+		return false
+	}
+	depth := 0
+	for i := start; i < end; i++ {
+		switch token := p.tokens[i]; {
+		case opensGroup(token) || token == leftBracket:
+			depth++
+		case token == rightParen || token == rightBracket:
+			depth--
+		}
+		if depth == 0 {
+			return i == end-1
+		}
+	}
+	return false
+}
+
+// groupingWarnings describes how the parts of an expression that could be
+// read another way, such as a/b*c and -x^2, were grouped, along with the result
+// of the other reading where it differs.
+func (c *Calculator) groupingWarnings(input string, result Value) []string {
+	squeezed, offsets := squeeze(replaceWordOperators(input))
+	tokens, positions, problems := c.tokenize(squeezed)
+	if len(problems) > 0 || len(tokens) == 0 {
+		return nil
+	}
+	p := &parser{c: c, tokens: tokens, positions: positions, end: len(squeezed)}
+	if _, err := p.expression(1); err != nil {
+		return nil
+	}
+
+	// parenthesize encloses the tokens of span in parentheses in input.
+	parenthesize := func(span [2]int) string {
+		from := offsets[p.offset(span[0])]
+		to := from + len(strings.TrimRight(input[from:offsets[p.offset(span[1])]], " "))
+		return strings.TrimSpace(input[:from] + "(" + input[from:to] + ")" + input[to:])
+	}
+	stats := c.stats
+	c.stats = nil
+	defer func() { c.stats = stats }()
+	var warnings []string
+	for _, g := range p.groupings {
+		other := parenthesize(g.other)
+		alternative, err := c.EvaluateValue(other)
+		if err != nil || c.FormatValue(alternative) == c.FormatValue(result) {
+			continue
+		}
+		warnings = append(warnings, fmt.Sprintf("read as %s; %s would be %s", parenthesize(g.grouped), other, c.FormatValue(alternative)))
+	}
+	return warnings
+}
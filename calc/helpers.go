@@ -0,0 +1,430 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var callRegex = regexp.MustCompile(`^(\w+)\((.*)\)$`)
+
+// gradeScales are the built-in letter grade scales returned by GradeScale.
+var gradeScales = map[string]map[string]float64{
+	"4.0": {"A+": 4.0, "A": 4.0, "A-": 3.7, "B+": 3.3, "B": 3.0, "B-": 2.7, "C+": 2.3, "C": 2.0, "C-": 1.7, "D+": 1.3, "D": 1.0, "D-": 0.7, "F": 0},
+	"4.3": {"A+": 4.3, "A": 4.0, "A-": 3.7, "B+": 3.3, "B": 3.0, "B-": 2.7, "C+": 2.3, "C": 2.0, "C-": 1.7, "D+": 1.3, "D": 1.0, "D-": 0.7, "F": 0},
+}
+
+// displayHelpers are the helpers that only show their result, as text that is
+// not a value, so that each must be the whole input: hex(255) writes a number
+// in a base, and base hex shows the results of expressions in it.
+var displayHelpers = map[string]bool{"plot": true, "table": true, "seed": true, "hex": true, "oct": true, "bin": true}
+
+// evaluateHelperFunction handles everyday utility functions whose arguments
+// are not plain numbers, such as hex(255, 8), or that only show their result,
+// such as plot(sin(x), x, -pi, pi).
+func (c *Calculator) evaluateHelperFunction(input string) (string, bool, error) {
+	match := callRegex.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return "", false, nil
+	}
+	args := splitArguments(match[2])
+
+	switch strings.ToLower(match[1]) {
+	case "plot":
+		output, err := c.plotExpression(args)
+		return output, true, err
+
+	case "table":
+		output, err := c.tableExpression(args)
+		return output, true, err
+
+	case "seed":
+		output, err := c.seedRandom(args)
+		return output, true, err
+
+	case "hex", "oct", "bin":
+		output, err := c.formatBase(strings.ToLower(match[1]), args)
+		return output, true, err
+	}
+	return "", false, nil
+}
+
+// The names of the helpers whose arguments are read in their own way: the
+// grades of gpa(A, B+:4), the unknown x of proportion(3, 4, x, 20), and the
+// relative tolerances of within(9.98, 10, 0.5%) and intol.
+const (
+	gpaName        = "gpa"
+	proportionName = "proportion"
+	withinName     = "within"
+	intolName      = "intol"
+)
+
+// gradeRegex matches a letter grade in gpa, such as B+ in gpa(A, B+:4), along
+// with the separator after it.
+var gradeRegex = regexp.MustCompile(`^[A-Za-z][+-]?(?:[:,)]|$)`)
+
+// insideCall reports whether tokens end inside the arguments of a call to
+// name, as gpa(A, does.
+func insideCall(tokens []string, name string) bool {
+	depth := 0
+	for i := len(tokens) - 1; i >= 0; i-- {
+		switch token := tokens[i]; {
+		case token == rightParen || token == rightBracket:
+			depth++
+		case opensGroup(token) || token == leftBracket:
+			if depth == 0 {
+				return token == name+leftParen
+			}
+			depth--
+		}
+	}
+	return false
+}
+
+// helperExtension provides the everyday helpers that take values:
+// weightedavg([90, 80], [1, 3]), splitratio(100, 2, 3, 5), unitprice(4.99,
+// 750ml), better(4.99/750ml, 6.49/1l), cfrac(pi, 5), and approx_frac(pi).
+func (c *Calculator) helperExtension() Extension {
+	return Extension{
+		Name: "helpers",
+		Functions: map[string]func([]Value) (Value, error){
+			"weightedavg": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected a list of values and a list of weights, as in weightedavg([90, 80], [1, 3])")
+				}
+				var lists [2][]float64
+				for i, arg := range args {
+					l, ok := arg.(List)
+					if !ok {
+						return nil, fmt.Errorf("expected a list of values and a list of weights, not %s", article(arg.Kind()))
+					}
+					numbers, err := realList("weightedavg", l)
+					if err != nil {
+						return nil, err
+					}
+					lists[i] = numbers
+				}
+				result, err := weightedAverage(lists[0], lists[1])
+				return Float(result), err
+			},
+			"splitratio": func(args []Value) (Value, error) {
+				numbers, err := realList("splitratio", flattenLists(args))
+				if err != nil {
+					return nil, err
+				}
+				if len(numbers) < 3 {
+					return nil, fmt.Errorf("expected a total and at least 2 parts, as in splitratio(100, 2, 3, 5)")
+				}
+				shares, err := splitByRatio(numbers[0], numbers[1:])
+				if err != nil {
+					return nil, err
+				}
+				result := make(List, len(shares))
+				for i, share := range shares {
+					result[i] = Float(share)
+				}
+				return result, nil
+			},
+			"unitprice": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected a price and a quantity, as in unitprice(4.99, 750ml)")
+				}
+				offer, err := c.applyBinary(divideOperator, args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				price, _, err := c.unitPrice(offer)
+				return price, err
+			},
+			"better": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected 2 offers, as in better(4.99/750ml, 6.49/1l)")
+				}
+				return c.compareOffers(args[0], args[1])
+			},
+			"cfrac":       c.continuedFraction,
+			"approx_frac": c.approximateFraction,
+		},
+	}
+}
+
+// realList returns the elements of l, which must be real numbers.
+func realList(name string, l List) ([]float64, error) {
+	numbers := make([]float64, len(l))
+	for i, v := range l {
+		var err error
+		if numbers[i], err = realArgument(name, v); err != nil {
+			return nil, err
+		}
+	}
+	return numbers, nil
+}
+
+// evaluateGPA evaluates gpa(A, B+:4, A-:3), whose arguments alternate between
+// grade points and credits, giving the grade point average to two decimals.
+func (c *Calculator) evaluateGPA(n callNode) (Value, error) {
+	values, err := c.evaluateArguments(n.args)
+	if err != nil {
+		return nil, err
+	}
+	var points, credits []float64
+	for i := 0; i+1 < len(values); i += 2 {
+		point, err := realArgument(gpaName, values[i])
+		if err != nil {
+			return nil, err
+		}
+		credit, err := realArgument(gpaName, values[i+1])
+		if err != nil {
+			return nil, err
+		}
+		if credit < 0 {
+			return nil, fmt.Errorf("the credits of a grade must not be negative, not %s", c.Format(credit))
+		}
+		points, credits = append(points, point), append(credits, credit)
+	}
+	result, err := weightedAverage(points, credits)
+	if err != nil {
+		return nil, err
+	}
+	return Float(math.Round(result*100) / 100), nil
+}
+
+// evaluateTolerance evaluates within(measured, nominal, tolerance) and intol,
+// which check a measurement against a nominal value. A tolerance is absolute
+// or, written as a percentage, relative to the nominal value, and a fourth
+// argument makes the band asymmetric, the third being the lower tolerance.
+// Quantities are compared in the units of the nominal value. within gives
+// whether the measurement passes, and intol a record of that and the
+// deviation.
+func (c *Calculator) evaluateTolerance(n callNode) (Value, error) {
+	values, err := c.evaluateArguments(n.args)
+	if err != nil {
+		return nil, err
+	}
+	numbers := make([]float64, len(values))
+	for i, v := range values {
+		if i >= 2 && isPercentage(n.args[i]) {
+			if numbers[i], err = realArgument(n.name, v); err != nil {
+				return nil, err
+			}
+			numbers[i] *= math.Abs(numbers[1])
+		} else if numbers[i], err = c.inUnitsOf(n.name, v, values[1]); err != nil {
+			return nil, err
+		}
+		if i >= 2 && numbers[i] < 0 {
+			return nil, fmt.Errorf("tolerances must not be negative")
+		}
+	}
+	measured, nominal := numbers[0], numbers[1]
+	lower, upper := numbers[2], numbers[len(numbers)-1]
+
+	deviation := measured - nominal
+	pass := deviation >= -lower-1e-12*math.Abs(nominal) && deviation <= upper+1e-12*math.Abs(nominal)
+	if n.name == withinName {
+		return Bool(pass), nil
+	}
+	names := []string{"pass", "deviation"}
+	fields := []Value{Bool(pass), Float(roundSignificant(deviation, 12))}
+	if nominal != 0 {
+		names = append(names, "relative")
+		fields = append(fields, Float(roundSignificant(deviation/nominal*100, 12)))
+	}
+	return newRecord(names, fields), nil
+}
+
+// inUnitsOf returns v as a number in the units of nominal, or as a real
+// number when nominal has no units.
+func (c *Calculator) inUnitsOf(name string, v, nominal Value) (float64, error) {
+	unit, ok := nominal.(Quantity)
+	if !ok {
+		return realArgument(name, v)
+	}
+	q, ok := v.(Quantity)
+	if !ok {
+		return 0, fmt.Errorf("%s needs %s to be in units of %s too", name, c.FormatValue(v), unit.describe())
+	}
+	converted, err := convertQuantity(q, Quantity{amount: 1, units: unit.units})
+	if err != nil {
+		return 0, err
+	}
+	return converted.(Quantity).amount, nil
+}
+
+// roundSignificant drops floating-point noise such as 9.98-10 =
+// -0.019999999999999574 before a value is shown.
+func roundSignificant(value float64, digits int) float64 {
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(value, 'g', digits, 64), 64)
+	return rounded
+}
+
+func weightedAverage(values, weights []float64) (float64, error) {
+	if len(values) != len(weights) {
+		return 0, fmt.Errorf("got %d values but %d weights", len(values), len(weights))
+	}
+	var sum, totalWeight float64
+	for i, value := range values {
+		sum += value * weights[i]
+		totalWeight += weights[i]
+	}
+	if totalWeight == 0 {
+		return 0, fmt.Errorf("weights must not add up to zero")
+	}
+	return sum / totalWeight, nil
+}
+
+// splitArguments splits a function argument list on commas that are not
+// nested inside parentheses, brackets, or braces.
+func splitArguments(text string) []string {
+	return splitTopLevel(text, ",")
+}
+
+// evaluateProportion evaluates proportion(3, 4, x, 20), solving a/b = c/d
+// for the single argument written as x, exactly where the others are exact.
+func (c *Calculator) evaluateProportion(n callNode) (Value, error) {
+	unknown := -1
+	values := make([]Value, 4)
+	for i, arg := range n.args {
+		if name, ok := arg.(nameNode); ok && name.name == "x" {
+			if unknown >= 0 {
+				return nil, fmt.Errorf("proportion needs exactly one unknown x")
+			}
+			unknown = i
+			continue
+		}
+		value, err := c.evaluateNode(arg)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	if unknown < 0 {
+		return nil, fmt.Errorf("proportion needs one argument to be the unknown x")
+	}
+	if (unknown != 1 && isZero(values[1])) || (unknown != 3 && isZero(values[3])) {
+		return nil, ErrDivideByZero
+	}
+
+	// a*d = b*c, so the unknown is the product of the other pair divided by
+	// the value it pairs with.
+	across := map[int][3]int{0: {1, 2, 3}, 1: {0, 3, 2}, 2: {0, 3, 1}, 3: {1, 2, 0}}[unknown]
+	product, err := c.applyBinary(multiplyOperator, values[across[0]], values[across[1]])
+	if err != nil {
+		return nil, err
+	}
+	return c.applyBinary(divideOperator, product, values[across[2]])
+}
+
+func splitByRatio(total float64, parts []float64) ([]float64, error) {
+	var sum float64
+	for _, part := range parts {
+		if part < 0 {
+			return nil, fmt.Errorf("ratio parts must not be negative")
+		}
+		sum += part
+	}
+	if sum == 0 {
+		return nil, fmt.Errorf("ratio parts must not all be zero")
+	}
+	shares := make([]float64, len(parts))
+	for i, part := range parts {
+		shares[i] = total * part / sum
+	}
+	return shares, nil
+}
+
+// unitPrice returns the price per liter, kilogram, or item of an offer, a
+// price divided by the quantity it buys, along with the unit it is per. A
+// price per liter or kilogram is a quantity such as 6.65 USD/l, shown as
+// 6.65 USD per l.
+func (c *Calculator) unitPrice(offer Value) (Value, string, error) {
+	for _, per := range []string{"l", "kg"} {
+		unit, _ := c.lookupMeasure(per)
+		price, err := c.applyBinary(multiplyOperator, offer, unit)
+		if err != nil || !isPrice(price) {
+			continue
+		}
+		perUnit, err := c.applyBinary(divideOperator, price, unit)
+		if err != nil {
+			return nil, "", err
+		}
+		q, ok := perUnit.(Quantity)
+		if !ok {
+			return nil, "", fmt.Errorf("cannot give a price per %s of %s", per, c.FormatValue(offer))
+		}
+		q.display = c.FormatValue(price) + " per " + per
+		return q, per, nil
+	}
+	if isPrice(offer) {
+		return offer, "item", nil
+	}
+	return nil, "", fmt.Errorf("an offer is a price for a volume, a mass, or a number of items, as in 4.99/750ml, not %s", c.FormatValue(offer))
+}
+
+// isPrice reports whether v is a real number or an amount of money.
+func isPrice(v Value) bool {
+	if q, ok := v.(Quantity); ok {
+		return q.dimension() == money
+	}
+	return isReal(v)
+}
+
+// compareOffers compares two offers, each a price divided by the quantity it
+// buys, giving a record of their prices per liter, kilogram, or item, which
+// of them is cheaper, 1 or 2 or 0 when neither, and by what percentage. The
+// record is shown as a sentence, such as the second offer is 2.45% cheaper.
+func (c *Calculator) compareOffers(first, second Value) (Value, error) {
+	var prices [2]Value
+	var pers [2]string
+	for i, offer := range []Value{first, second} {
+		var err error
+		if prices[i], pers[i], err = c.unitPrice(offer); err != nil {
+			return nil, err
+		}
+	}
+	if pers[0] != pers[1] {
+		return nil, fmt.Errorf("cannot compare a price per %s with a price per %s", pers[0], pers[1])
+	}
+	ratio, err := c.applyBinary(divideOperator, prices[0], prices[1])
+	if err != nil {
+		return nil, err
+	}
+	r, ok := Real(ratio)
+	if !ok {
+		return nil, fmt.Errorf("cannot compare a price in %s with one in %s", c.FormatValue(prices[0]), c.FormatValue(prices[1]))
+	}
+	cheaper, saving := 0, 0.0
+	switch {
+	case r < 1:
+		cheaper, saving = 1, (1-r)*100
+	case r > 1:
+		cheaper, saving = 2, (1-1/r)*100
+	}
+	comparison := newRecord([]string{"first", "second", "cheaper", "saving"}, []Value{prices[0], prices[1], Int{big.NewInt(int64(cheaper))}, Float(saving)})
+	shown := [2]string{c.FormatValue(prices[0]), c.FormatValue(prices[1])}
+	switch cheaper {
+	case 0:
+		comparison.display = fmt.Sprintf("both offers cost %s", shown[0])
+	case 1:
+		comparison.display = fmt.Sprintf("the first offer is cheaper by %.2f%%: %s against %s", saving, shown[0], shown[1])
+	case 2:
+		comparison.display = fmt.Sprintf("the second offer is cheaper by %.2f%%: %s against %s", saving, shown[1], shown[0])
+	}
+	return comparison, nil
+}
+
+// GradeScale returns a copy of the built-in grade scale "4.0" or "4.3", which
+// map letter grades such as A- to grade points.
+func GradeScale(name string) (map[string]float64, bool) {
+	scale, ok := gradeScales[name]
+	if !ok {
+		return nil, false
+	}
+	copied := make(map[string]float64, len(scale))
+	for grade, points := range scale {
+		copied[grade] = points
+	}
+	return copied, true
+}
@@ -0,0 +1,78 @@
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// MaxInputLength is the length in bytes of the longest input EvaluateInput
+	// reads.
+	MaxInputLength = 10000
+	// maxInvalidCharacters is the number of characters an expression may have
+	// that cannot be read before it is taken for something other than math.
+	maxInvalidCharacters = 10
+)
+
+var (
+	ErrNotExpression = fmt.Errorf("input does not look like a math expression")
+	ErrInputTooLong  = fmt.Errorf("input is too long")
+
+	// base64Regex matches a run of base64 text long enough that it is not a
+	// name or number.
+	base64Regex = regexp.MustCompile(`[A-Za-z0-9+/]{64,}={0,2}`)
+)
+
+// checkInput rejects input that is too long to be a calculation or is clearly
+// something else, such as binary data or base64 text pasted by mistake, before
+// it is read as an expression.
+func checkInput(input string) error {
+	if len(input) > MaxInputLength {
+		return fmt.Errorf("%w: it is %d bytes, and the limit is %d", ErrInputTooLong, len(input), MaxInputLength)
+	}
+	if !utf8.ValidString(input) {
+		return fmt.Errorf("%w: it is not valid text", ErrNotExpression)
+	}
+	for _, r := range input {
+		if unicode.IsControl(r) && r != '\t' {
+			return fmt.Errorf("%w: it contains the control character %U", ErrNotExpression, r)
+		}
+	}
+	for _, run := range base64Regex.FindAllString(input, -1) {
+		if isBase64(run) {
+			return fmt.Errorf("%w: it contains %d characters of what looks like base64 data", ErrNotExpression, len(run))
+		}
+	}
+	return nil
+}
+
+func countInvalidCharacters(problems []*SyntaxError) int {
+	count := 0
+	for _, problem := range problems {
+		if errors.Is(problem.Err, ErrInvalidCharacter) {
+			count++
+		}
+	}
+	return count
+}
+
+// isBase64 reports whether a run of base64 characters mixes upper and lower
+// case letters and digits the way encoded data does and names and numbers do
+// not.
+func isBase64(run string) bool {
+	var upper, lower, digit bool
+	for _, r := range run {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
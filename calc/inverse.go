@@ -0,0 +1,81 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+)
+
+// inverseSuffix marks a call of the inverse of a function, such as f⁻¹(y).
+const inverseSuffix = "⁻¹"
+
+// inverseRegex matches the inverse notation after a function name, up to the
+// opening parenthesis of its arguments: sin^-1( or sin⁻¹(.
+var inverseRegex = regexp.MustCompile(`^(\^-1|⁻¹)\(`)
+
+// inverses are the builtins whose inverse is itself a builtin, so that sin⁻¹
+// is asin, with the same principal values.
+var inverses = map[string]string{
+	"sin": "asin", "cos": "acos", "tan": "atan",
+	"asin": "sin", "acos": "cos", "atan": "tan",
+	"exp": "ln", "ln": "exp",
+}
+
+// inverseOf returns the function that name, such as f⁻¹, is the inverse of.
+func inverseOf(name string) (string, bool) {
+	if !strings.HasSuffix(name, inverseSuffix) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, inverseSuffix), true
+}
+
+// evaluateInverse returns the x for which the function name gives args[0],
+// found numerically: between args[1] and args[2] when they are given, and
+// otherwise where solve looks for roots. There must be exactly one such x.
+func (c *Calculator) evaluateInverse(name string, args []float64) (float64, error) {
+	if len(args) != 1 && len(args) != 3 {
+		return 0, fmt.Errorf("%s%s expects 1 argument, or 3 with a range, got %d", name, inverseSuffix, len(args))
+	}
+	var err error
+	if _, ok := c.Functions[name]; ok {
+		_, err = c.resolveOverload(name, 1)
+	} else if function, ok := builtins[name]; ok {
+		err = function.checkArity(name, 1)
+	} else if _, ok := logBase(name); !ok {
+		return 0, fmt.Errorf("unsupported function: %s", name)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("%s%s needs a function of one argument", name, inverseSuffix)
+	}
+
+	y := args[0]
+	f := func(x float64) float64 {
+		v, err := c.evaluateFunction(name, []float64{x})
+		if err != nil {
+			return math.NaN()
+		}
+		return v - y
+	}
+	var roots []float64
+	if len(args) == 3 {
+		a, b := args[1], args[2]
+		if !(a < b) || math.IsInf(a, 0) || math.IsInf(b, 0) {
+			return 0, fmt.Errorf("%s%s expects a range from a lower to a higher finite bound", name, inverseSuffix)
+		}
+		points := make([]float64, 1001)
+		for i := range points {
+			points[i] = a + (b-a)*float64(i)/1000
+		}
+		roots = findRoots(f, points)
+		if len(roots) == 0 {
+			return 0, fmt.Errorf("%s never gives %g between %g and %g", name, y, a, b)
+		}
+	} else if roots = searchRoots(f); len(roots) == 0 {
+		return 0, fmt.Errorf("%s never gives %g between %g and %g", name, y, -solveLimit, solveLimit)
+	}
+	if len(roots) > 1 {
+		return 0, fmt.Errorf("%s gives %g more than once, at %g and %g; give a range where it is monotonic, as in %s%s(%g, a, b)", name, y, roots[0], roots[1], name, inverseSuffix, y)
+	}
+	return roots[0], nil
+}
@@ -0,0 +1,197 @@
+package calc
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// List is a list of values written as [1, 2, 3]. Arithmetic on two lists of
+// the same length works element by element, and a number with a list applies
+// to each element, so [1, 2, 3] * 2 is [2, 4, 6].
+type List []Value
+
+func (l List) Kind() string { return "list" }
+
+func (l List) String() string {
+	parts := make([]string, len(l))
+	for i, v := range l {
+		parts[i] = v.String()
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// Expression writes l as a list literal that gives it.
+func (l List) Expression() string {
+	parts := make([]string, len(l))
+	for i, v := range l {
+		if e, ok := v.(interface{ Expression() string }); ok {
+			parts[i] = e.Expression()
+		} else {
+			parts[i] = v.String()
+		}
+	}
+	return "[" + strings.Join(parts, ", ") + "]"
+}
+
+// listOperators turns the operators that extensions are given back into the
+// tokens that applyBinary and applyUnary take.
+var listOperators = map[string]string{
+	percentOperator: moduloOperator, "xor": xorOperator, "in": convertOperator,
+}
+
+// index returns the element of l at the position given by v, counting from 1.
+func (c *Calculator) index(v, i Value) (Value, error) {
+	l, ok := v.(List)
+	if m, isMatrix := v.(Matrix); isMatrix {
+		l, ok = make(List, len(m)), true
+		for j, row := range m {
+			l[j] = List(row)
+		}
+	}
+	if !ok {
+		return nil, fmt.Errorf("%s cannot be indexed, only a list such as [1, 2, 3] can", article(v.Kind()))
+	}
+	n, ok := wholeNumber(i)
+	if !ok {
+		return nil, fmt.Errorf("a list index must be a whole number, not %s", c.FormatValue(i))
+	}
+	if n.Sign() <= 0 || n.Cmp(big.NewInt(int64(len(l)))) > 0 {
+		return nil, fmt.Errorf("index %s is out of range for a list of %s, which counts from 1", n, pluralize(len(l), "element"))
+	}
+	return l[n.Int64()-1], nil
+}
+
+// mapList applies f to each element of l.
+func mapList(l List, f func(Value) (Value, error)) (Value, error) {
+	result := make(List, len(l))
+	for i, v := range l {
+		var err error
+		if result[i], err = f(v); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// flattenLists replaces the lists among args by their elements, so that
+// sum([1, 2], 3) adds all three.
+func flattenLists(args []Value) []Value {
+	var result []Value
+	for _, arg := range args {
+		if l, ok := arg.(List); ok {
+			result = append(result, flattenLists(l)...)
+		} else {
+			result = append(result, arg)
+		}
+	}
+	return result
+}
+
+// listArgument returns the single list argument of a list function.
+func listArgument(args []Value) (List, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 list, got %s", pluralize(len(args), "argument"))
+	}
+	l, ok := args[0].(List)
+	if !ok {
+		return nil, fmt.Errorf("expected a list, not %s", article(args[0].Kind()))
+	}
+	return l, nil
+}
+
+// listExtension adds lists and the functions len, sort, and dot.
+func (c *Calculator) listExtension() Extension {
+	return Extension{
+		Name: "list",
+		Functions: map[string]func([]Value) (Value, error){
+			"len": func(args []Value) (Value, error) {
+				l, err := listArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return Int{big.NewInt(int64(len(l)))}, nil
+			},
+			"sort": func(args []Value) (Value, error) {
+				l, err := listArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				for _, v := range l {
+					if !isReal(v) {
+						return nil, fmt.Errorf("only real numbers can be sorted, not %s", article(v.Kind()))
+					}
+				}
+				sorted := append(List(nil), l...)
+				sort.SliceStable(sorted, func(i, j int) bool { return toFloat(sorted[i]) < toFloat(sorted[j]) })
+				return sorted, nil
+			},
+			"dot": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected 2 lists, got %s", pluralize(len(args), "argument"))
+				}
+				a, aIsList := args[0].(List)
+				b, bIsList := args[1].(List)
+				if !aIsList || !bIsList {
+					return nil, fmt.Errorf("expected 2 lists")
+				}
+				if len(a) != len(b) {
+					return nil, fmt.Errorf("the lists have different lengths, %d and %d", len(a), len(b))
+				}
+				var total Value = Int{big.NewInt(0)}
+				for i := range a {
+					product, err := c.applyBinary(multiplyOperator, a[i], b[i])
+					if err != nil {
+						return nil, err
+					}
+					if total, err = c.applyBinary(addOperator, total, product); err != nil {
+						return nil, err
+					}
+				}
+				return total, nil
+			},
+		},
+		Binary: c.listBinary,
+		Unary: func(operator string, a Value) (Value, bool, error) {
+			l, ok := a.(List)
+			if !ok {
+				return nil, false, nil
+			}
+			if operator == subtractOperator {
+				operator = negateOperator
+			}
+			result, err := mapList(l, func(v Value) (Value, error) { return c.applyUnary(operator, v) })
+			return result, true, err
+		},
+	}
+}
+
+func (c *Calculator) listBinary(operator string, a, b Value) (Value, bool, error) {
+	l, isList := a.(List)
+	m, otherIsList := b.(List)
+	if token, ok := listOperators[operator]; ok {
+		operator = token
+	}
+	switch {
+	case isList && otherIsList:
+		if len(l) != len(m) {
+			return nil, true, fmt.Errorf("%s needs lists of the same length, not %d and %d", displayToken(operator), len(l), len(m))
+		}
+		result := make(List, len(l))
+		for i := range l {
+			var err error
+			if result[i], err = c.applyBinary(operator, l[i], m[i]); err != nil {
+				return nil, true, err
+			}
+		}
+		return result, true, nil
+	case isList:
+		result, err := mapList(l, func(v Value) (Value, error) { return c.applyBinary(operator, v, b) })
+		return result, true, err
+	case otherIsList:
+		result, err := mapList(m, func(v Value) (Value, error) { return c.applyBinary(operator, a, v) })
+		return result, true, err
+	}
+	return nil, false, nil
+}
@@ -0,0 +1,141 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+)
+
+// conditionalName is the name of if(condition, then, else).
+const conditionalName = "if"
+
+// logicalOperators are the comparison operators and the operators that
+// combine conditions, which give true or false.
+var logicalOperators = map[string]bool{equalOperator: true, notEqualOperator: true, lessOperator: true, lessEqualOperator: true, greaterOperator: true, greaterEqualOperator: true, andOperator: true, orOperator: true, notOperator: true}
+
+// isComparison reports whether operator compares two values.
+func isComparison(operator string) bool {
+	return logicalOperators[operator] && operator != andOperator && operator != orOperator && operator != notOperator
+}
+
+// truth returns whether a number counts as true, which every number other
+// than zero does, reporting false when v is not a number.
+func truth(v Value) (bool, bool) {
+	if !isNumeric(v) {
+		return false, false
+	}
+	if z, ok := v.(Complex); ok {
+		return z != 0, true
+	}
+	return toFloat(v) != 0, true
+}
+
+// operandTruth returns the truth of an operand of and, or, or not.
+func operandTruth(operator string, v Value) (bool, error) {
+	truth, ok := truth(v)
+	if !ok {
+		return false, fmt.Errorf("%s takes numbers or true and false, not %s", displayToken(operator), article(v.Kind()))
+	}
+	return truth, nil
+}
+
+// compareValues applies a comparison operator to two numbers. Integers and
+// fractions are compared exactly, and complex numbers only for equality.
+func (c *Calculator) compareValues(operator string, a, b Value) (Value, error) {
+	to := rank(a)
+	if rank(b) > to {
+		to = rank(b)
+	}
+	if to < intRank {
+		to = intRank
+	}
+	a, b = promote(a, to), promote(b, to)
+
+	var order int
+	switch to {
+	case complexRank:
+		equal := a.(Complex) == b.(Complex)
+		switch operator {
+		case equalOperator:
+			return Bool(equal), nil
+		case notEqualOperator:
+			return Bool(!equal), nil
+		}
+		return nil, fmt.Errorf("%s is not defined for complex numbers, which have no order", displayToken(operator))
+	case intRank, rationalRank:
+		x, _ := exactRational(a)
+		y, _ := exactRational(b)
+		order = x.Cmp(y)
+	default:
+		x, y := toFloat(a), toFloat(b)
+		if math.IsNaN(x) || math.IsNaN(y) {
+			return Bool(operator == notEqualOperator), nil
+		}
+		switch {
+		case x < y:
+			order = -1
+		case x > y:
+			order = 1
+		}
+	}
+
+	return ordered(operator, order), nil
+}
+
+// ordered applies a comparison operator to two values whose order is given as
+// -1, 0, or 1 for the first being less than, equal to, or greater than the
+// second.
+func ordered(operator string, order int) Bool {
+	switch operator {
+	case equalOperator:
+		return order == 0
+	case notEqualOperator:
+		return order != 0
+	case lessOperator:
+		return order < 0
+	case lessEqualOperator:
+		return order <= 0
+	case greaterOperator:
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
+
+// evaluateLogical evaluates a and b or a or b, leaving b unevaluated when a
+// decides the result, so that x != 0 and 1/x > 2 cannot divide by zero.
+func (c *Calculator) evaluateLogical(n binaryNode) (Value, error) {
+	left, err := c.evaluateNode(n.left)
+	if err != nil {
+		return nil, err
+	}
+	decided, err := operandTruth(n.operator, left)
+	if err != nil {
+		return nil, err
+	}
+	if decided == (n.operator == orOperator) {
+		return Bool(decided), nil
+	}
+	right, err := c.evaluateNode(n.right)
+	if err != nil {
+		return nil, err
+	}
+	result, err := operandTruth(n.operator, right)
+	return Bool(result), err
+}
+
+// evaluateConditional evaluates if(condition, then, else), leaving the branch
+// not taken unevaluated, so that if(x > 0, sqrt(x), 0) works for any x.
+func (c *Calculator) evaluateConditional(n callNode) (Value, error) {
+	condition, err := c.evaluateNode(n.args[0])
+	if err != nil {
+		return nil, err
+	}
+	holds, ok := truth(condition)
+	if !ok {
+		return nil, fmt.Errorf("%s takes a condition that is a number or true or false, not %s", conditionalName, article(condition.Kind()))
+	}
+	if holds {
+		return c.evaluateNode(n.args[1])
+	}
+	return c.evaluateNode(n.args[2])
+}
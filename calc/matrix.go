@@ -0,0 +1,384 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// ErrSingular is the error of inverting a matrix whose determinant is zero.
+var ErrSingular = fmt.Errorf("%w: the matrix is singular", ErrDomain)
+
+// Matrix is a matrix of numbers, written as a list of its rows such as
+// [[1, 2], [3, 4]]. * multiplies matrices, and + and - work element by
+// element.
+type Matrix [][]Value
+
+func (m Matrix) Kind() string { return "matrix" }
+
+func (m Matrix) String() string {
+	rows := make([]string, len(m))
+	for i, row := range m {
+		rows[i] = List(row).String()
+	}
+	return "[" + strings.Join(rows, ", ") + "]"
+}
+
+// Expression writes m as a matrix literal that gives it.
+func (m Matrix) Expression() string {
+	return m.String()
+}
+
+// toMatrix returns l as a matrix when it is a list of rows of numbers of the
+// same length.
+func toMatrix(l List) (Matrix, bool) {
+	if len(l) == 0 {
+		return nil, false
+	}
+	m := make(Matrix, len(l))
+	for i, v := range l {
+		row, ok := v.(List)
+		if !ok || len(row) == 0 || len(row) != len(m[0]) && i > 0 {
+			return nil, false
+		}
+		for _, element := range row {
+			if !isNumeric(element) {
+				return nil, false
+			}
+		}
+		m[i] = row
+	}
+	return m, true
+}
+
+// formatMatrix shows m with one row on each line and its columns lined up.
+func (c *Calculator) formatMatrix(m Matrix) string {
+	cells := make([][]string, len(m))
+	widths := make([]int, len(m[0]))
+	for i, row := range m {
+		cells[i] = make([]string, len(row))
+		for j, v := range row {
+			cells[i][j] = c.FormatValue(v)
+			if len(cells[i][j]) > widths[j] {
+				widths[j] = len(cells[i][j])
+			}
+		}
+	}
+	rows := make([]string, len(m))
+	for i, row := range cells {
+		for j, cell := range row {
+			row[j] = strings.Repeat(" ", widths[j]-len(cell)) + cell
+		}
+		rows[i] = "[" + strings.Join(row, ", ") + "]"
+	}
+	return "[\n  " + strings.Join(rows, ",\n  ") + "\n]"
+}
+
+func (m Matrix) columns() int {
+	return len(m[0])
+}
+
+func (m Matrix) size() string {
+	return fmt.Sprintf("%dx%d", len(m), m.columns())
+}
+
+func (m Matrix) transpose() Matrix {
+	result := make(Matrix, m.columns())
+	for j := range result {
+		result[j] = make([]Value, len(m))
+		for i := range m {
+			result[j][i] = m[i][j]
+		}
+	}
+	return result
+}
+
+func identity(n int) Matrix {
+	m := make(Matrix, n)
+	for i := range m {
+		m[i] = make([]Value, n)
+		for j := range m[i] {
+			m[i][j] = Int{big.NewInt(0)}
+		}
+		m[i][i] = Int{big.NewInt(1)}
+	}
+	return m
+}
+
+// multiplyMatrices returns the matrix product of a and b.
+func (c *Calculator) multiplyMatrices(a, b Matrix) (Matrix, error) {
+	if a.columns() != len(b) {
+		return nil, fmt.Errorf("cannot multiply a %s matrix by a %s matrix; the columns of the first must match the rows of the second", a.size(), b.size())
+	}
+	result := make(Matrix, len(a))
+	for i := range a {
+		result[i] = make([]Value, b.columns())
+		for j := range result[i] {
+			var sum Value = Int{big.NewInt(0)}
+			for k := range b {
+				product, err := c.applyBinary(multiplyOperator, a[i][k], b[k][j])
+				if err != nil {
+					return nil, err
+				}
+				if sum, err = c.applyBinary(addOperator, sum, product); err != nil {
+					return nil, err
+				}
+			}
+			result[i][j] = sum
+		}
+	}
+	return result, nil
+}
+
+// eliminate reduces the rows of m, a copy of a square matrix with any extra
+// columns to its right, to reduced row echelon form by Gauss-Jordan
+// elimination, and returns its determinant, which is zero when it is
+// singular. Exact values stay exact.
+func (c *Calculator) eliminate(m Matrix) (Value, error) {
+	var det Value = Int{big.NewInt(1)}
+	n := len(m)
+	for col := 0; col < n; col++ {
+		pivot := -1
+		for row := col; row < n; row++ {
+			if x := toFloat(m[row][col]); x != 0 && (pivot < 0 || math.Abs(x) > math.Abs(toFloat(m[pivot][col]))) {
+				pivot = row
+			}
+		}
+		if pivot < 0 {
+			return Int{big.NewInt(0)}, nil
+		}
+		if pivot != col {
+			m[pivot], m[col] = m[col], m[pivot]
+			det = negate(det)
+		}
+		var err error
+		p := m[col][col]
+		if det, err = c.applyBinary(multiplyOperator, det, p); err != nil {
+			return nil, err
+		}
+		for j := range m[col] {
+			if m[col][j], err = c.applyBinary(divideOperator, m[col][j], p); err != nil {
+				return nil, err
+			}
+		}
+		for row := range m {
+			factor := m[row][col]
+			if row == col || toFloat(factor) == 0 {
+				continue
+			}
+			for j := range m[row] {
+				product, err := c.applyBinary(multiplyOperator, factor, m[col][j])
+				if err != nil {
+					return nil, err
+				}
+				if m[row][j], err = c.applyBinary(subtractOperator, m[row][j], product); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return det, nil
+}
+
+// augment returns a copy of m with the columns of extra to its right.
+func augment(m, extra Matrix) Matrix {
+	result := make(Matrix, len(m))
+	for i := range m {
+		result[i] = append(append([]Value{}, m[i]...), extra[i]...)
+	}
+	return result
+}
+
+func (c *Calculator) determinant(m Matrix) (Value, error) {
+	if len(m) != m.columns() {
+		return nil, fmt.Errorf("only a square matrix has a determinant, not a %s matrix", m.size())
+	}
+	return c.eliminate(augment(m, make(Matrix, len(m))))
+}
+
+func (c *Calculator) inverse(m Matrix) (Matrix, error) {
+	if len(m) != m.columns() {
+		return nil, fmt.Errorf("only a square matrix has an inverse, not a %s matrix", m.size())
+	}
+	reduced := augment(m, identity(len(m)))
+	det, err := c.eliminate(reduced)
+	if err != nil {
+		return nil, err
+	}
+	if toFloat(det) == 0 {
+		return nil, ErrSingular
+	}
+	result := make(Matrix, len(m))
+	for i, row := range reduced {
+		result[i] = row[len(m):]
+	}
+	return result, nil
+}
+
+// matrixPower raises the square matrix m to the whole power n, negative
+// powers being those of its inverse.
+func (c *Calculator) matrixPower(m Matrix, n *big.Int) (Matrix, error) {
+	if len(m) != m.columns() {
+		return nil, fmt.Errorf("only a square matrix can be raised to a power, not a %s matrix", m.size())
+	}
+	if !n.IsInt64() || n.Int64() > 1<<16 || n.Int64() < -(1<<16) {
+		return nil, fmt.Errorf("%w: the power of a matrix must be between -65536 and 65536", ErrDomain)
+	}
+	power := n.Int64()
+	if power < 0 {
+		inverse, err := c.inverse(m)
+		if err != nil {
+			return nil, err
+		}
+		m, power = inverse, -power
+	}
+	result := identity(len(m))
+	for ; power > 0; power >>= 1 {
+		var err error
+		if power&1 == 1 {
+			if result, err = c.multiplyMatrices(result, m); err != nil {
+				return nil, err
+			}
+		}
+		if power > 1 {
+			if m, err = c.multiplyMatrices(m, m); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// matrixArgument returns the single matrix argument of a matrix function.
+func matrixArgument(args []Value) (Matrix, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("expected 1 matrix, got %s", pluralize(len(args), "argument"))
+	}
+	m, ok := args[0].(Matrix)
+	if !ok {
+		return nil, fmt.Errorf("expected a matrix such as [[1, 2], [3, 4]], not %s", article(args[0].Kind()))
+	}
+	return m, nil
+}
+
+// matrixExtension adds matrices and the functions transpose, det, inv, and
+// identity.
+func (c *Calculator) matrixExtension() Extension {
+	return Extension{
+		Name: "matrix",
+		Functions: map[string]func([]Value) (Value, error){
+			"transpose": func(args []Value) (Value, error) {
+				m, err := matrixArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return m.transpose(), nil
+			},
+			"det": func(args []Value) (Value, error) {
+				m, err := matrixArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return c.determinant(m)
+			},
+			"inv": func(args []Value) (Value, error) {
+				m, err := matrixArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return c.inverse(m)
+			},
+			"identity": func(args []Value) (Value, error) {
+				if len(args) != 1 {
+					return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+				}
+				n, ok := wholeNumber(args[0])
+				if !ok || n.Sign() <= 0 || n.Cmp(big.NewInt(1000)) > 0 {
+					return nil, fmt.Errorf("the size must be a whole number from 1 to 1000")
+				}
+				return identity(int(n.Int64())), nil
+			},
+		},
+		Binary: c.matrixBinary,
+		Unary: func(operator string, a Value) (Value, bool, error) {
+			m, ok := a.(Matrix)
+			if !ok {
+				return nil, false, nil
+			}
+			if operator != subtractOperator {
+				return nil, true, fmt.Errorf("%s is not defined for matrices", operator)
+			}
+			result, err := c.mapMatrix(m, func(v Value) (Value, error) { return c.applyUnary(negateOperator, v) })
+			return result, true, err
+		},
+	}
+}
+
+// mapMatrix applies f to each element of m.
+func (c *Calculator) mapMatrix(m Matrix, f func(Value) (Value, error)) (Matrix, error) {
+	result := make(Matrix, len(m))
+	for i, row := range m {
+		result[i] = make([]Value, len(row))
+		for j, v := range row {
+			var err error
+			if result[i][j], err = f(v); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+func (c *Calculator) matrixBinary(operator string, a, b Value) (Value, bool, error) {
+	m, isMatrix := a.(Matrix)
+	n, otherIsMatrix := b.(Matrix)
+	if !isMatrix && !otherIsMatrix {
+		return nil, false, nil
+	}
+	if v, ok := b.(List); ok && isMatrix && operator == multiplyOperator {
+		column := make(Matrix, len(v))
+		for i, x := range v {
+			column[i] = []Value{x}
+		}
+		product, err := c.multiplyMatrices(m, column)
+		if err != nil {
+			return nil, true, err
+		}
+		return List(product.transpose()[0]), true, nil
+	}
+	switch {
+	case isMatrix && otherIsMatrix && (operator == addOperator || operator == subtractOperator):
+		if len(m) != len(n) || m.columns() != n.columns() {
+			return nil, true, fmt.Errorf("cannot %s a %s matrix and a %s matrix", map[string]string{addOperator: "add", subtractOperator: "subtract"}[operator], m.size(), n.size())
+		}
+		result := make(Matrix, len(m))
+		for i := range m {
+			result[i] = make([]Value, len(m[i]))
+			for j := range m[i] {
+				var err error
+				if result[i][j], err = c.applyBinary(operator, m[i][j], n[i][j]); err != nil {
+					return nil, true, err
+				}
+			}
+		}
+		return result, true, nil
+	case isMatrix && otherIsMatrix && operator == multiplyOperator:
+		result, err := c.multiplyMatrices(m, n)
+		return result, true, err
+	case isMatrix && operator == powerOperator:
+		power, ok := wholeNumber(b)
+		if !ok {
+			return nil, true, fmt.Errorf("a matrix can only be raised to a whole power, not %s", c.FormatValue(b))
+		}
+		result, err := c.matrixPower(m, power)
+		return result, true, err
+	case isMatrix && isNumeric(b) && (operator == multiplyOperator || operator == divideOperator):
+		result, err := c.mapMatrix(m, func(v Value) (Value, error) { return c.applyBinary(operator, v, b) })
+		return result, true, err
+	case otherIsMatrix && isNumeric(a) && operator == multiplyOperator:
+		result, err := c.mapMatrix(n, func(v Value) (Value, error) { return c.applyBinary(operator, a, v) })
+		return result, true, err
+	}
+	return nil, true, fmt.Errorf("%s is not defined between %s and %s", operator, article(a.Kind()), article(b.Kind()))
+}
@@ -0,0 +1,179 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// secondsPerDay is the length of a calendar day added to a date.
+const secondsPerDay = 24 * 60 * 60
+
+// maxShiftDays bounds the days a date can be moved by, which is more than
+// the span of the years 0 to 9999 that dates are written in.
+const maxShiftDays = 10000 * 366
+
+// maxDurationSeconds is the longest time.Duration in seconds, about 292
+// years.
+const maxDurationSeconds = math.MaxInt64 / float64(time.Second)
+
+// dateLiteralRegex matches a date written in an expression, as in
+// 2025-01-01 - 1999-06-15.
+var dateLiteralRegex = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}`)
+
+// Moment is a date, such as 2025-01-01 or today, or a moment in time, such
+// as now. Adding a time quantity such as 45 days gives another moment, and
+// subtracting two gives the days between them.
+type Moment struct {
+	time time.Time
+	// dateOnly reports whether the moment is a calendar date without a time
+	// of day, kept at midnight UTC like the dates of the date functions.
+	dateOnly bool
+}
+
+func (m Moment) Kind() string { return "date" }
+
+func (m Moment) String() string {
+	if m.dateOnly {
+		return formatDate(m.time)
+	}
+	return formatMoment(m.time)
+}
+
+// Expression writes m as an expression that gives it, as 2025-01-01 or
+// 2025-01-01 + 45000*s.
+func (m Moment) Expression() string {
+	year, month, day := m.time.UTC().Date()
+	date := time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+	if seconds := m.time.Sub(date).Seconds(); seconds != 0 {
+		return date.Format("2006-01-02") + " + " + strconv.FormatFloat(seconds, 'g', -1, 64) + "*s"
+	}
+	return date.Format("2006-01-02")
+}
+
+// isDateToken reports whether token is a date literal or one of the names
+// today and now, which the tokenizer only passes on when no variable has
+// them.
+func isDateToken(token string) bool {
+	return token == "today" || token == "now" || dateLiteralRegex.MatchString(token) && len(token) == len("2006-01-02")
+}
+
+// parseMoment returns the moment of a date token.
+func parseMoment(token string) (Moment, error) {
+	switch token {
+	case "now":
+		return Moment{time: time.Now()}, nil
+	case "today":
+		date, err := parseDate(token)
+		return Moment{time: date, dateOnly: true}, err
+	}
+	date, err := time.Parse("2006-01-02", token)
+	if err != nil {
+		return Moment{}, fmt.Errorf("invalid date: %s", token)
+	}
+	return Moment{time: date, dateOnly: true}, nil
+}
+
+// shiftMoment adds a time quantity, in seconds, to m. Whole days are added
+// on the calendar, so a date stays a date and a time keeps its wall-clock time
+// across daylight saving changes. Times longer than a time.Duration are split
+// into whole days and the rest of a day.
+func shiftMoment(m Moment, seconds float64) (Moment, error) {
+	days := seconds / secondsPerDay
+	if math.IsNaN(days) || math.Abs(days) > maxShiftDays {
+		return Moment{}, errDateRange
+	}
+	var shifted Moment
+	switch {
+	case days == math.Trunc(days):
+		shifted = Moment{time: m.time.AddDate(0, 0, int(days)), dateOnly: m.dateOnly}
+	case math.Abs(seconds) < maxDurationSeconds:
+		shifted = Moment{time: m.time.Add(time.Duration(seconds * float64(time.Second)))}
+	default:
+		whole := math.Floor(days)
+		rest := (seconds - whole*secondsPerDay) * float64(time.Second)
+		shifted = Moment{time: m.time.AddDate(0, 0, int(whole)).Add(time.Duration(rest))}
+	}
+	if err := checkDateRange(shifted.time); err != nil {
+		return Moment{}, err
+	}
+	return shifted, nil
+}
+
+// errDateRange is the error of a date outside the years dates are written in.
+var errDateRange = fmt.Errorf("the date would be outside the years 0 to 9999")
+
+// checkDateRange reports errDateRange if t is outside the years 0 to 9999.
+func checkDateRange(t time.Time) error {
+	if year := t.Year(); year < 0 || year > 9999 {
+		return errDateRange
+	}
+	return nil
+}
+
+// civilDays returns the number of calendar days from 1970-01-01 to the date
+// of t, counted from the date rather than through time.Duration, which only
+// spans about 292 years.
+func civilDays(t time.Time) int64 {
+	year, month, day := t.Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Unix() / secondsPerDay
+}
+
+// daysBetween returns the days from n to m, counting whole calendar days
+// between dates and fractions of a day between moments.
+func daysBetween(m, n Moment) float64 {
+	if m.dateOnly && n.dateOnly {
+		return float64(civilDays(m.time) - civilDays(n.time))
+	}
+	seconds := float64(m.time.Unix()-n.time.Unix()) + float64(m.time.Nanosecond()-n.time.Nanosecond())/float64(time.Second)
+	return seconds / secondsPerDay
+}
+
+// momentExtension adds dates and moments to c: today + 45 days is a date,
+// 2025-01-01 - 1999-06-15 is the number of days between two, and the date
+// functions such as workdays take them.
+func (c *Calculator) momentExtension() Extension {
+	return Extension{
+		Name:      "date",
+		Functions: c.dateFunctions(),
+		Binary:    c.momentBinary,
+	}
+}
+
+func (c *Calculator) momentBinary(operator string, a, b Value) (Value, bool, error) {
+	m, isMoment := a.(Moment)
+	n, otherIsMoment := b.(Moment)
+	if !isMoment && !otherIsMoment {
+		return nil, false, nil
+	}
+	switch {
+	case operator == subtractOperator && isMoment && otherIsMoment:
+		return Quantity{amount: daysBetween(m, n), units: []unitPower{{"day", 1, measures["day"]}}}, true, nil
+	case operator == addOperator && otherIsMoment && !isMoment:
+		return c.momentBinary(operator, b, a)
+	case (operator == addOperator || operator == subtractOperator) && isMoment && !otherIsMoment:
+		q, ok := b.(Quantity)
+		if !ok || q.dimension() != duration {
+			return nil, true, fmt.Errorf("%w: a date can only be moved by a time such as 45 days or 3 h, not %s", ErrIncompatibleUnits, c.FormatValue(b))
+		}
+		seconds := q.amount * q.factor()
+		if operator == subtractOperator {
+			seconds = -seconds
+		}
+		shifted, err := shiftMoment(m, seconds)
+		return shifted, true, err
+	case isComparison(operator) && isMoment && otherIsMoment:
+		order := 0
+		if m.time.Before(n.time) {
+			order = -1
+		} else if m.time.After(n.time) {
+			order = 1
+		}
+		return ordered(operator, order), true, nil
+	case operator == addOperator:
+		return nil, true, fmt.Errorf("two dates cannot be added; subtract them to get the days between")
+	}
+	return nil, true, fmt.Errorf("%s is not defined for dates; dates can be moved by adding or subtracting a time, subtracted, and compared", operator)
+}
@@ -0,0 +1,93 @@
+package calc
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// maxPackSteps bounds the operations and calls that one call of a function
+// from a formula pack may evaluate, including the functions it calls.
+const maxPackSteps = 100000
+
+// PackViolation is a line of a formula pack that was not loaded.
+type PackViolation struct {
+	Line   int
+	Reason string
+}
+
+func (v PackViolation) Error() string {
+	return fmt.Sprintf("line %d: %s", v.Line, v.Reason)
+}
+
+// LoadPack defines the functions of the formula pack in the file at path,
+// which holds one function definition per line; lines starting with # are
+// comments. Pack functions are sandboxed: the pack may not assign variables or
+// replace the session's own functions, and each call of a pack function may
+// take at most maxPackSteps steps. LoadPack returns the definitions it loaded
+// and the lines it refused.
+func (c *Calculator) LoadPack(path string) ([]string, []PackViolation, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot load formula pack %s", path)
+	}
+
+	var loaded []string
+	var violations []PackViolation
+	for number, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		def, ok := parseFunctionDefinition(line)
+		if !ok {
+			reason := "only function definitions are allowed"
+			if _, _, ok := parseAssignment(line); ok {
+				reason = "assignments to session variables are not allowed"
+			}
+			violations = append(violations, PackViolation{Line: number + 1, Reason: reason})
+			continue
+		}
+		if c.isSessionFunction(def.name) {
+			violations = append(violations, PackViolation{Line: number + 1, Reason: fmt.Sprintf("'%s' would replace a function of the session", def.name)})
+			continue
+		}
+		def.sandboxed = true
+		result, err := c.defineFunction(def)
+		if err != nil {
+			violations = append(violations, PackViolation{Line: number + 1, Reason: err.Error()})
+			continue
+		}
+		loaded = append(loaded, result.Text)
+	}
+	c.packs = append(c.packs, path)
+	return loaded, violations, nil
+}
+
+// isSessionFunction reports whether name is a function defined in the session
+// rather than loaded from a formula pack.
+func (c *Calculator) isSessionFunction(name string) bool {
+	for _, overload := range c.Functions[name] {
+		if !overload.Sandboxed {
+			return true
+		}
+	}
+	return false
+}
+
+// countStep counts one evaluation step against the limit of the pack function
+// call in progress, if any, and checks the Deadline.
+func (c *Calculator) countStep() error {
+	if !c.Deadline.IsZero() && time.Now().After(c.Deadline) {
+		return ErrDeadline
+	}
+	if c.sandbox == "" {
+		return nil
+	}
+	if c.stepsLeft == 0 {
+		return fmt.Errorf("%s is from a formula pack and took more than %d steps", c.sandbox, maxPackSteps)
+	}
+	c.stepsLeft--
+	return nil
+}
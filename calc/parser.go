@@ -0,0 +1,473 @@
+package calc
+
+import (
+	"fmt"
+)
+
+// node is a node of an expression's syntax tree: a numberNode, nameNode,
+// dateNode, listNode, indexNode, fieldNode, unaryNode, binaryNode, callNode,
+// letNode, or equationNode.
+type node interface{}
+
+// numberNode is a number, kept as written so that it can also be read exactly.
+type numberNode struct {
+	text string
+}
+
+// nameNode is a variable that holds something other than a real number, such
+// as a polynomial, whose value is looked up when it is evaluated.
+type nameNode struct {
+	name string
+}
+
+// fieldNode reads the field name of the record that operand gives.
+type fieldNode struct {
+	operand node
+	name    string
+}
+
+// listNode is a list literal such as [1, 2, 3].
+type listNode struct {
+	elements []node
+}
+
+// indexNode is the element of the list that operand gives at index, counting
+// from 1, as in xs[2].
+type indexNode struct {
+	operand, index node
+}
+
+// dateNode is a date, as 2025-01-01, or one of today and now.
+type dateNode struct {
+	text string
+}
+
+// unaryNode applies a prefix or postfix operator, such as − or !, to operand.
+type unaryNode struct {
+	operator string
+	operand  node
+}
+
+// binaryNode applies a binary operator to left and right.
+type binaryNode struct {
+	operator    string
+	left, right node
+}
+
+// callNode is a call to a builtin or user function at offset pos of the input.
+type callNode struct {
+	name string
+	args []node
+	pos  int
+}
+
+// equationNode is the equation left = right that solve takes, which is
+// solved for left - right = 0.
+type equationNode struct {
+	left, right node
+}
+
+// letNode is a let expression, whose body is evaluated with each of names
+// bound to the matching value, which can use the names before it.
+type letNode struct {
+	names  []string
+	values []node
+	body   node
+}
+
+// specialCalls are the calls that are evaluated from the syntax of their
+// arguments rather than from their values alone.
+var specialCalls = map[string]bool{
+	ansName: true, ansShortName: true, conditionalName: true, piecewiseName: true, integrateName: true, solveName: true,
+	gpaName: true, proportionName: true, withinName: true, intolName: true, addDaysName: true,
+}
+
+func (c *Calculator) precedenceOf(token string) int {
+	if token == implicitMultiplyOperator && c.ImplicitTight {
+		return precedence[token] + 1
+	}
+	return precedence[token]
+}
+
+// parser builds the syntax tree of a list of tokens by recursive descent,
+// climbing the precedence of the binary operators.
+type parser struct {
+	c         *Calculator
+	tokens    []string
+	positions []int
+	end       int
+	pos       int
+	// groupings are the parts of the expression that could be read another
+	// way, and operator the index of the operator of the last binary node
+	// built.
+	groupings []grouping
+	operator  int
+}
+
+// parse builds the syntax tree of tokens, which are at positions in an input
+// of length end. Its errors are SyntaxErrors giving the offset of the fault.
+func (c *Calculator) parse(tokens []string, positions []int, end int) (node, error) {
+	p := &parser{c: c, tokens: tokens, positions: positions, end: end}
+	if len(tokens) == 0 {
+		return nil, p.fail(0, fmt.Errorf("%w: the expression is empty", ErrInsufficientValues))
+	}
+	tree, err := p.expression(1)
+	if err != nil {
+		return nil, err
+	}
+	switch token := p.peek(); {
+	case token == "":
+		return tree, nil
+	case token == rightParen:
+		return nil, p.fail(p.pos, ErrMismatchedParens)
+	case token == comma:
+		return nil, p.fail(p.pos, fmt.Errorf("commas can only separate function arguments"))
+	case token == equationSign:
+		return nil, p.fail(p.pos, fmt.Errorf("= can only assign to a name, as in x = 3, use == to compare"))
+	default:
+		return nil, p.fail(p.pos, fmt.Errorf("unexpected '%s'", displayToken(token)))
+	}
+}
+
+// fail returns err as a SyntaxError at the token with the given index.
+func (p *parser) fail(index int, err error) error {
+	return &SyntaxError{Err: err, Offset: p.offset(index)}
+}
+
+// offset returns the offset in the input of the token with the given index,
+// or the end of the input past the last token.
+func (p *parser) offset(index int) int {
+	if index < len(p.positions) {
+		return p.positions[index]
+	}
+	return p.end
+}
+
+func (p *parser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *parser) next() string {
+	token := p.peek()
+	p.pos++
+	return token
+}
+
+// expression parses the operators of at least minPrecedence and their
+// operands.
+func (p *parser) expression(minPrecedence int) (node, error) {
+	start := p.pos
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	previous := -1
+	for {
+		operator := p.peek()
+		if !p.c.isOperator(operator) || operator == negateOperator || operator == bitNotOperator || operator == notOperator || p.c.precedenceOf(operator) < minPrecedence {
+			return left, nil
+		}
+		index := p.pos
+		p.next()
+		next := p.c.precedenceOf(operator)
+		if associativity[operator] == "L" {
+			next++
+		}
+		right, err := p.expression(next)
+		if err != nil {
+			return nil, err
+		}
+		// a/b*c and a/b/c divide by b alone, where a/(b*c) was perhaps meant.
+		if previous >= 0 && p.tokens[previous] == divideOperator && (operator == multiplyOperator || operator == implicitMultiplyOperator || operator == divideOperator) {
+			p.groupings = append(p.groupings, grouping{grouped: [2]int{start, index}, other: [2]int{previous + 1, p.pos}})
+		}
+		left = binaryNode{operator: operator, left: left, right: right}
+		previous, p.operator = index, index
+	}
+}
+
+// operand parses a number, a variable, a parenthesized expression, a function call, or a
+// negation or ~, followed by any % and ! operators and fields such as .mean.
+func (p *parser) operand() (node, error) {
+	var operand node
+	var err error
+	switch token := p.next(); {
+	case token == "":
+		return nil, p.missingValue()
+	case token == negateOperator || token == bitNotOperator || token == notOperator:
+		start := p.pos
+		operand, err = p.expression(precedence[token] + 1)
+		if err != nil {
+			return nil, err
+		}
+		// -x^2 negates x^2, where (-x)^2 was perhaps meant.
+		if power, ok := operand.(binaryNode); ok && token == negateOperator && power.operator == powerOperator && !p.enclosed(start, p.pos) {
+			p.groupings = append(p.groupings, grouping{grouped: [2]int{start, p.pos}, other: [2]int{start - 1, p.operator}})
+		}
+		return unaryNode{operator: token, operand: operand}, nil
+	case token == rootOperator:
+		if operand, err = p.expression(precedence[token]); err != nil {
+			return nil, err
+		}
+		return unaryNode{operator: token, operand: operand}, nil
+	case p.c.isNumber(token):
+		operand = numberNode{text: token}
+	case p.c.isValueName(token):
+		operand = nameNode{name: token}
+	case isDateToken(token):
+		operand = dateNode{text: token}
+	case dayKinds[token]:
+		// The tokenizer only passes on the kind of day of adddays.
+		operand = nameNode{name: token}
+	case token == leftParen:
+		open := p.pos - 1
+		if operand, err = p.expression(1); err != nil {
+			return nil, err
+		}
+		if p.next() != rightParen {
+			return nil, p.fail(open, ErrMismatchedParens)
+		}
+	case p.c.isFunction(token):
+		if operand, err = p.call(token[:len(token)-len(leftParen)]); err != nil {
+			return nil, err
+		}
+	case token == leftBracket:
+		if operand, err = p.list(); err != nil {
+			return nil, err
+		}
+	case token == rightParen && p.pos > 1 && opensGroup(p.tokens[p.pos-2]):
+		return nil, p.fail(p.pos-1, fmt.Errorf("%w: empty parentheses", ErrInsufficientValues))
+	default:
+		p.pos--
+		return nil, p.missingValue()
+	}
+
+	for p.peek() == percentOperator || p.peek() == factorialOperator || isField(p.peek()) || p.peek() == leftBracket {
+		if token := p.next(); isField(token) {
+			operand = fieldNode{operand: operand, name: token[len(fieldPrefix):]}
+		} else if token == leftBracket {
+			open := p.pos - 1
+			index, err := p.expression(1)
+			if err != nil {
+				return nil, err
+			}
+			if p.next() != rightBracket {
+				return nil, p.fail(open, fmt.Errorf("%w: expected ] after the index", ErrMismatchedParens))
+			}
+			operand = indexNode{operand: operand, index: index}
+		} else {
+			operand = unaryNode{operator: token, operand: operand}
+		}
+	}
+	return operand, nil
+}
+
+// call parses the arguments of a call to the function name up to its closing
+// paren.
+func (p *parser) call(name string) (node, error) {
+	open := p.pos - 1
+	call := callNode{name: name, pos: p.offset(open)}
+	if p.peek() == rightParen {
+		p.next()
+		return call, nil
+	}
+	for {
+		arg, err := p.expression(1)
+		if err != nil {
+			return nil, err
+		}
+		if name == solveName && len(call.args) == 0 && p.peek() == equationSign {
+			p.next()
+			right, err := p.expression(1)
+			if err != nil {
+				return nil, err
+			}
+			arg = equationNode{left: arg, right: right}
+		}
+		call.args = append(call.args, arg)
+		if name == gpaName {
+			// Each grade is followed by its credits, 1 unless given as in B+:4.
+			credits := node(numberNode{text: "1"})
+			if p.peek() == pieceSeparator {
+				p.next()
+				if credits, err = p.expression(1); err != nil {
+					return nil, err
+				}
+			}
+			call.args = append(call.args, credits)
+		}
+		if name == piecewiseName && len(call.args)%2 == 1 {
+			if p.next() != pieceSeparator {
+				return nil, p.fail(p.pos-1, fmt.Errorf("each piece of %s needs the form condition: value", name))
+			}
+			continue
+		}
+		switch p.next() {
+		case comma:
+		case rightParen:
+			return call, nil
+		case equationSign:
+			return nil, p.fail(p.pos-1, fmt.Errorf("only the first argument of solve can be an equation with =, use == to compare"))
+		default:
+			return nil, p.fail(open, ErrMismatchedParens)
+		}
+	}
+}
+
+// mayBeList reports whether any of args can give a list, whose elements then
+// count as arguments of a function such as sum.
+func mayBeList(args []node) bool {
+	for _, arg := range args {
+		if _, ok := arg.(numberNode); !ok {
+			return true
+		}
+	}
+	return false
+}
+
+// list parses the elements of a list literal after its opening bracket.
+func (p *parser) list() (node, error) {
+	open := p.pos - 1
+	var list listNode
+	if p.peek() == rightBracket {
+		p.next()
+		return list, nil
+	}
+	for {
+		element, err := p.expression(1)
+		if err != nil {
+			return nil, err
+		}
+		list.elements = append(list.elements, element)
+		switch p.next() {
+		case comma:
+		case rightBracket:
+			return list, nil
+		default:
+			return nil, p.fail(open, fmt.Errorf("%w: expected ] to close the list", ErrMismatchedParens))
+		}
+	}
+}
+
+// checkCalls checks the number of arguments of every function call in n, so
+// that a call that cannot succeed is reported before anything is evaluated.
+func (c *Calculator) checkCalls(n node) []*SyntaxError {
+	var problems []*SyntaxError
+	switch n := n.(type) {
+	case unaryNode:
+		problems = c.checkCalls(n.operand)
+	case fieldNode:
+		problems = c.checkCalls(n.operand)
+	case indexNode:
+		problems = append(c.checkCalls(n.operand), c.checkCalls(n.index)...)
+	case listNode:
+		for _, element := range n.elements {
+			problems = append(problems, c.checkCalls(element)...)
+		}
+	case binaryNode:
+		problems = append(c.checkCalls(n.left), c.checkCalls(n.right)...)
+	case equationNode:
+		problems = append(c.checkCalls(n.left), c.checkCalls(n.right)...)
+	case letNode:
+		for _, value := range n.values {
+			problems = append(problems, c.checkCalls(value)...)
+		}
+		problems = append(problems, c.checkCalls(n.body)...)
+	case callNode:
+		var err error
+		if n.name == ansName || n.name == ansShortName {
+			if len(n.args) != 1 {
+				err = fmt.Errorf("%s expects 1 argument, e.g. %s(2) for the result before the last", n.name, n.name)
+			}
+		} else if _, ok := logBase(n.name); ok {
+			if len(n.args) != 1 {
+				err = fmt.Errorf("%s expects 1 argument, got %d", n.name, len(n.args))
+			}
+		} else if n.name == piecewiseName {
+			if len(n.args) == 0 {
+				err = fmt.Errorf("%s needs at least one piece, as in %s(x < 0: -x, x >= 0: x)", n.name, n.name)
+			}
+		} else if n.name == integrateName {
+			if len(n.args) != 4 {
+				err = fmt.Errorf("%s expects 4 arguments, %s(expression, x, a, b), got %d", n.name, n.name, len(n.args))
+			} else if _, ok := n.args[1].(nameNode); !ok {
+				err = fmt.Errorf("%s needs the name of a variable as its second argument, as in %s(sin(x), x, 0, pi)", n.name, n.name)
+			}
+		} else if n.name == solveName {
+			if len(n.args) < 1 || len(n.args) > 2 {
+				err = fmt.Errorf("%s expects an equation and the unknown, as in %s(x^2 - 4 = 0, x), got %s", n.name, n.name, pluralize(len(n.args), "argument"))
+			} else if _, ok := n.args[len(n.args)-1].(nameNode); len(n.args) == 2 && !ok {
+				err = fmt.Errorf("%s needs the name of a variable as its second argument, as in %s(x^2 - 4 = 0, x)", n.name, n.name)
+			}
+		} else if n.name == gpaName {
+			if len(n.args) == 0 {
+				err = fmt.Errorf("%s expects at least one grade, as in %s(A, B+:4, A-:3)", n.name, n.name)
+			}
+		} else if n.name == proportionName {
+			if len(n.args) != 4 {
+				err = fmt.Errorf("%s expects 4 arguments, %s(a, b, c, d) for a/b = c/d, got %d", n.name, n.name, len(n.args))
+			}
+		} else if n.name == addDaysName {
+			if len(n.args) != 2 && len(n.args) != 3 {
+				err = fmt.Errorf("%s expects 2 or 3 arguments, %s(date, days[, business]), got %d", n.name, n.name, len(n.args))
+			}
+		} else if n.name == withinName || n.name == intolName {
+			if len(n.args) != 3 && len(n.args) != 4 {
+				err = fmt.Errorf("%s expects 3 or 4 arguments, %s(measured, nominal, tolerance[, upper tolerance]), got %d", n.name, n.name, len(n.args))
+			}
+		} else if n.name == conditionalName {
+			if len(n.args) != 3 {
+				err = fmt.Errorf("%s expects 3 arguments, %s(condition, then, else), got %d", n.name, n.name, len(n.args))
+			}
+		} else if function, ok := builtins[n.name]; ok && !(function.maxArgs == variadic && mayBeList(n.args)) {
+			err = function.checkArity(n.name, len(n.args))
+		} else if _, ok := c.Functions[n.name]; ok {
+			_, err = c.resolveOverload(n.name, len(n.args))
+		}
+		if err != nil {
+			problems = append(problems, &SyntaxError{Err: err, Offset: n.pos})
+		}
+		for _, arg := range n.args {
+			problems = append(problems, c.checkCalls(arg)...)
+		}
+	}
+	return problems
+}
+
+// missingValue describes an operand missing at the current token.
+func (p *parser) missingValue() error {
+	switch {
+	case p.pos < len(p.tokens) && p.pos == 0:
+		return p.fail(p.pos, fmt.Errorf("%w: expected a value before '%s'", ErrInsufficientValues, displayToken(p.tokens[p.pos])))
+	case p.pos < len(p.tokens):
+		return p.fail(p.pos, fmt.Errorf("%w: expected a value between '%s' and '%s'", ErrInsufficientValues, displayToken(p.tokens[p.pos-1]), displayToken(p.tokens[p.pos])))
+	default:
+		return p.fail(p.pos, fmt.Errorf("%w: expected a value after '%s'", ErrInsufficientValues, displayToken(p.tokens[len(p.tokens)-1])))
+	}
+}
+
+// displayToken returns a token as the user typed it.
+func displayToken(token string) string {
+	switch token {
+	case negateOperator:
+		return subtractOperator
+	case moduloOperator:
+		return percentOperator
+	case implicitMultiplyOperator, unitMultiplyOperator:
+		return multiplyOperator
+	case xorOperator:
+		return "xor"
+	case convertOperator:
+		return "in"
+	case andOperator:
+		return "and"
+	case orOperator:
+		return "or"
+	case notOperator:
+		return "not"
+	}
+	return token
+}
@@ -0,0 +1,117 @@
+package calc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// piecewiseName is the name of piecewise(condition: value, ...), the form of
+// a piecewise expression that can be part of a larger one.
+const piecewiseName = "piecewise"
+
+// piece is one branch of a piecewise expression: value applies when condition
+// holds.
+type piece struct {
+	condition, value string
+}
+
+// isPiecewise reports whether input is a piecewise expression such as
+// { x<0: -x; x>=0: x }.
+func isPiecewise(input string) bool {
+	input = strings.TrimSpace(input)
+	return strings.HasPrefix(input, "{") && strings.HasSuffix(input, "}")
+}
+
+// parsePiecewise splits a piecewise expression into its pieces.
+func parsePiecewise(input string) ([]piece, error) {
+	input = strings.TrimSpace(input)
+	var pieces []piece
+	for _, text := range splitTopLevel(input[1:len(input)-1], ";") {
+		if text == "" {
+			continue
+		}
+		parts := splitTopLevel(text, ":")
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("each piece needs the form condition: value, got '%s'", text)
+		}
+		pieces = append(pieces, piece{condition: parts[0], value: parts[1]})
+	}
+	if len(pieces) == 0 {
+		return nil, fmt.Errorf("a piecewise expression needs at least one piece")
+	}
+	return pieces, nil
+}
+
+// compilePiecewise compiles a piecewise expression in braces into a call of
+// piecewise with the same pieces.
+func (c *Calculator) compilePiecewise(input string) (node, error) {
+	pieces, err := parsePiecewise(input)
+	if err != nil {
+		return nil, err
+	}
+	call := callNode{name: piecewiseName}
+	for _, p := range pieces {
+		for _, expression := range []string{p.condition, p.value} {
+			tree, err := c.compileExpression(expression)
+			if err != nil {
+				return nil, err
+			}
+			call.args = append(call.args, tree)
+		}
+	}
+	return call, nil
+}
+
+// evaluatePieces evaluates piecewise(condition: value, ...), whose arguments
+// alternate between conditions and values, giving the value of the first
+// piece whose condition holds. The values of the other pieces are never
+// evaluated, so a piece can recurse or be undefined outside its condition.
+func (c *Calculator) evaluatePieces(n callNode) (Value, error) {
+	for i := 0; i+1 < len(n.args); i += 2 {
+		condition, err := c.evaluateNode(n.args[i])
+		if err != nil {
+			return nil, err
+		}
+		holds, ok := truth(condition)
+		if !ok {
+			return nil, fmt.Errorf("the conditions of %s must be true or false, not %s", piecewiseName, article(condition.Kind()))
+		}
+		if holds {
+			return c.evaluateNode(n.args[i+1])
+		}
+	}
+	return nil, fmt.Errorf("no piece applies")
+}
+
+// holds evaluates a condition such as x >= 0 and x < 2, which holds when it
+// is true or, for a number, other than zero.
+func (c *Calculator) holds(condition string) (bool, error) {
+	value, err := c.EvaluateValue(condition)
+	if err != nil {
+		return false, err
+	}
+	truth, ok := truth(value)
+	if !ok {
+		return false, fmt.Errorf("the condition '%s' is %s, not true or false", condition, article(value.Kind()))
+	}
+	return truth, nil
+}
+
+// splitTopLevel splits text at each separator outside parentheses, brackets,
+// and braces.
+func splitTopLevel(text, separator string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i := 0; i < len(text); i++ {
+		switch {
+		case strings.ContainsRune("([{", rune(text[i])):
+			depth++
+		case strings.ContainsRune(")]}", rune(text[i])):
+			depth--
+		case depth == 0 && strings.HasPrefix(text[i:], separator):
+			parts = append(parts, strings.TrimSpace(text[start:i]))
+			start = i + len(separator)
+		}
+	}
+	return append(parts, strings.TrimSpace(text[start:]))
+}
@@ -0,0 +1,177 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// plotWidth and plotHeight are the default size of a plot in characters,
+// and maxPlotSize the largest either may be.
+const (
+	plotWidth   = 60
+	plotHeight  = 15
+	maxPlotSize = 200
+)
+
+// plotExpression handles plot(sin(x), x, -pi, pi), a chart of an expression
+// in a variable between two bounds, drawn with text characters and scaled to
+// the values it takes. A width and height may follow the bounds.
+func (c *Calculator) plotExpression(args []string) (string, error) {
+	if (len(args) != 4 && len(args) != 6) || args[0] == "" {
+		return "", fmt.Errorf("plot expects an expression, its variable, and the bounds, then optionally a width and height: plot(sin(x), x, -pi, pi, 60, 15)")
+	}
+	name := strings.TrimSpace(args[1])
+	if identifierRegex.FindString(name) != name {
+		return "", fmt.Errorf("plot needs the name of the variable, not '%s'", name)
+	}
+	if err := c.checkAssignable(name); err != nil {
+		return "", fmt.Errorf("cannot plot over '%s', it is a function name", name)
+	}
+	bounds := make([]float64, len(args)-2)
+	for i, arg := range args[2:] {
+		bound, err := c.Evaluate(arg)
+		if err != nil {
+			return "", err
+		}
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return "", fmt.Errorf("plot needs finite real bounds and size, not %s", strings.TrimSpace(arg))
+		}
+		bounds[i] = bound
+	}
+	a, b := bounds[0], bounds[1]
+	if !(a < b) {
+		return "", fmt.Errorf("plot needs a lower bound below the upper one")
+	}
+	width, height := plotWidth, plotHeight
+	if len(bounds) == 4 {
+		width, height = int(bounds[2]), int(bounds[3])
+		if float64(width) != bounds[2] || float64(height) != bounds[3] || width < 10 || height < 5 || width > maxPlotSize || height > maxPlotSize {
+			return "", fmt.Errorf("plot needs a whole width from 10 to %d and height from 5 to %d", maxPlotSize, maxPlotSize)
+		}
+	}
+
+	defer c.bindUnknown(name, Float(a))()
+	tree, err := c.compile(args[0])
+	if err != nil {
+		return "", err
+	}
+	var failure error
+	xs, ys := make([]float64, width), make([]float64, width)
+	low, high := math.Inf(1), math.Inf(-1)
+	for i := range xs {
+		xs[i] = a + (b-a)*float64(i)/float64(width-1)
+		c.Variables[name] = Float(xs[i])
+		ys[i] = math.NaN()
+		v, err := c.evaluateNode(tree)
+		if err == nil && !isReal(v) {
+			err = fmt.Errorf("%s is not a real number at %s = %s", strings.TrimSpace(args[0]), name, c.Format(xs[i]))
+		}
+		if err != nil {
+			if failure == nil {
+				failure = err
+			}
+			continue
+		}
+		if y := toFloat(v); !math.IsNaN(y) && !math.IsInf(y, 0) {
+			ys[i] = y
+			low, high = math.Min(low, y), math.Max(high, y)
+		}
+	}
+	if math.IsInf(low, 1) {
+		if failure != nil {
+			return "", failure
+		}
+		return "", fmt.Errorf("%s has no finite values between %s and %s", strings.TrimSpace(args[0]), c.Format(a), c.Format(b))
+	}
+	if low == high {
+		margin := math.Max(1, math.Abs(low)/10)
+		low, high = low-margin, high+margin
+	}
+
+	header := fmt.Sprintf("%s for %s from %s to %s", strings.TrimSpace(args[0]), name, c.Format(a), c.Format(b))
+	return header + "\n" + drawPlot(xs, ys, low, high, height), nil
+}
+
+// drawPlot draws the points (xs[i], ys[i]) on a grid of len(xs) columns and
+// height rows spanning low to high, with the axes where they fall inside it
+// and the values at its edges labelled. Points whose y is NaN are left out.
+func drawPlot(xs, ys []float64, low, high float64, height int) string {
+	width := len(xs)
+	row := func(y float64) int {
+		return int(math.Round((high - y) / (high - low) * float64(height-1)))
+	}
+	grid := make([][]rune, height)
+	for r := range grid {
+		grid[r] = []rune(strings.Repeat(" ", width))
+	}
+	axisRow, axisColumn := -1, -1
+	if low <= 0 && high >= 0 {
+		axisRow = row(0)
+		for i := range grid[axisRow] {
+			grid[axisRow][i] = '─'
+		}
+	}
+	if xs[0] <= 0 && xs[width-1] >= 0 {
+		axisColumn = int(math.Round(-xs[0] / (xs[width-1] - xs[0]) * float64(width-1)))
+		for r := range grid {
+			grid[r][axisColumn] = '│'
+		}
+		if axisRow >= 0 {
+			grid[axisRow][axisColumn] = '┼'
+		}
+	}
+	previous := -1
+	for i, y := range ys {
+		if math.IsNaN(y) {
+			previous = -1
+			continue
+		}
+		r := row(y)
+		grid[r][i] = '•'
+		step, span := 1, r-previous
+		if span < 0 {
+			step, span = -1, -span
+		}
+		// Steep stretches are joined up, but not jumps such as tan makes.
+		if previous >= 0 && span > 1 && span < height/2 {
+			for between := previous + step; between != r; between += step {
+				grid[between][i] = '•'
+			}
+		}
+		previous = r
+	}
+
+	labels := map[int]string{0: plotLabel(high), height - 1: plotLabel(low)}
+	if axisRow > 0 && axisRow < height-1 {
+		labels[axisRow] = "0"
+	}
+	margin := 0
+	for _, label := range labels {
+		if len(label) > margin {
+			margin = len(label)
+		}
+	}
+	var plot strings.Builder
+	for r, line := range grid {
+		if label, ok := labels[r]; ok {
+			fmt.Fprintf(&plot, "%*s ┤%s\n", margin, label, string(line))
+		} else {
+			fmt.Fprintf(&plot, "%*s │%s\n", margin, "", string(line))
+		}
+	}
+	fmt.Fprintf(&plot, "%*s └%s\n", margin, "", strings.Repeat("─", width))
+	left, right := plotLabel(xs[0]), plotLabel(xs[width-1])
+	gap := width - len(left) - len(right)
+	if gap < 1 {
+		gap = 1
+	}
+	fmt.Fprintf(&plot, "%*s  %s%s%s", margin, "", left, strings.Repeat(" ", gap), right)
+	return plot.String()
+}
+
+// plotLabel formats a value at the edge of a plot to four significant digits.
+func plotLabel(v float64) string {
+	return strconv.FormatFloat(v, 'g', 4, 64)
+}
@@ -0,0 +1,399 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// maxPolynomialPower bounds the power a polynomial can be raised to with ^.
+const maxPolynomialPower = 256
+
+// Polynomial is a polynomial in x, written with its coefficients from the
+// highest power down, as poly(1, -3, 2) for x^2 - 3x + 2. Its coefficients are
+// numbers, kept exact like other values.
+type Polynomial struct {
+	// coefficients are ordered from the highest power down, without leading
+	// zeros, so the zero polynomial has none.
+	coefficients []Value
+}
+
+func (p Polynomial) Kind() string { return "polynomial" }
+
+// Degree returns the highest power of x in p, or -1 for the zero polynomial.
+func (p Polynomial) Degree() int {
+	return len(p.coefficients) - 1
+}
+
+func (p Polynomial) String() string {
+	return p.format(false)
+}
+
+// Expression writes p as the call of poly that gives it.
+func (p Polynomial) Expression() string {
+	coefficients := make([]string, len(p.coefficients))
+	for i, coefficient := range p.coefficients {
+		coefficients[i] = coefficient.String()
+	}
+	if len(coefficients) == 0 {
+		coefficients = []string{"0"}
+	}
+	return "poly(" + strings.Join(coefficients, ", ") + ")"
+}
+
+// format writes p as a sum of terms such as 3x^2, showing fractional
+// coefficients as fractions when fractions is set and as decimals otherwise.
+func (p Polynomial) format(fractions bool) string {
+	var b strings.Builder
+	for i, coefficient := range p.coefficients {
+		if isZero(coefficient) {
+			continue
+		}
+		power := p.Degree() - i
+		negative := isNegative(coefficient)
+		switch {
+		case b.Len() == 0 && negative:
+			b.WriteString("-")
+		case b.Len() > 0 && negative:
+			b.WriteString(" - ")
+		case b.Len() > 0:
+			b.WriteString(" + ")
+		}
+		if negative {
+			coefficient = negate(coefficient)
+		}
+
+		text := coefficient.String()
+		switch v := coefficient.(type) {
+		case Rational:
+			if !fractions {
+				f, _ := v.Float64()
+				text = strconv.FormatFloat(f, 'g', 12, 64)
+			} else if power > 0 {
+				text = "(" + text + ")"
+			}
+		case Float:
+			text = strconv.FormatFloat(float64(v), 'g', 12, 64)
+		case Complex:
+			if power > 0 {
+				text = "(" + text + ")"
+			}
+		}
+		if text != "1" || power == 0 {
+			b.WriteString(text)
+		}
+		if power > 0 {
+			b.WriteString("x")
+		}
+		if power > 1 {
+			b.WriteString("^" + strconv.Itoa(power))
+		}
+	}
+	if b.Len() == 0 {
+		return "0"
+	}
+	return b.String()
+}
+
+// isZero reports whether a number is zero.
+func isZero(v Value) bool {
+	return isNumeric(v) && toFloat(v) == 0
+}
+
+// isNegative reports whether a number is a negative real number.
+func isNegative(v Value) bool {
+	switch v := v.(type) {
+	case Int:
+		return v.Sign() < 0
+	case Rational:
+		return v.Sign() < 0
+	case Float:
+		return v < 0 || v == 0 && math.Signbit(float64(v))
+	}
+	return false
+}
+
+// negate returns -v for a number.
+func negate(v Value) Value {
+	switch v := v.(type) {
+	case Int:
+		return Int{new(big.Int).Neg(v.Int)}
+	case Rational:
+		return Rational{new(big.Rat).Neg(v.Rat)}
+	case Float:
+		return -v
+	case Complex:
+		return -v
+	}
+	return v
+}
+
+// newPolynomial returns the polynomial with the given coefficients, dropping
+// leading zeros.
+func newPolynomial(coefficients []Value) Polynomial {
+	for len(coefficients) > 0 && isZero(coefficients[0]) {
+		coefficients = coefficients[1:]
+	}
+	return Polynomial{coefficients: coefficients}
+}
+
+// toPolynomial converts a polynomial or a number, as a constant polynomial.
+func toPolynomial(v Value) (Polynomial, bool) {
+	switch v := v.(type) {
+	case Polynomial:
+		return v, true
+	case Bool, Int, Rational, Float, Complex:
+		return newPolynomial([]Value{v}), true
+	}
+	return Polynomial{}, false
+}
+
+// polynomialExtension adds polynomials to c: poly(...) writes one, deriv(p)
+// and degree(p) give its derivative and degree, p(x) evaluates it, and the
+// operators combine polynomials and numbers, with // and % giving the quotient
+// and remainder of a division.
+func (c *Calculator) polynomialExtension() Extension {
+	return Extension{
+		Name: "polynomial",
+		Functions: map[string]func([]Value) (Value, error){
+			"poly": func(args []Value) (Value, error) {
+				if len(args) == 0 {
+					return nil, fmt.Errorf("expected at least 1 coefficient")
+				}
+				for _, arg := range args {
+					if !isNumeric(arg) {
+						return nil, fmt.Errorf("the coefficients must be numbers, not %s", article(arg.Kind()))
+					}
+				}
+				return newPolynomial(args), nil
+			},
+			"deriv": func(args []Value) (Value, error) {
+				p, err := polynomialArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return c.derivative(p)
+			},
+			"degree": func(args []Value) (Value, error) {
+				p, err := polynomialArgument(args)
+				if err != nil {
+					return nil, err
+				}
+				return Int{big.NewInt(int64(p.Degree()))}, nil
+			},
+		},
+		Binary: c.polynomialBinary,
+		Unary: func(operator string, a Value) (Value, bool, error) {
+			p, ok := a.(Polynomial)
+			if !ok {
+				return nil, false, nil
+			}
+			switch operator {
+			case subtractOperator:
+				result, err := c.scalePolynomial(p, multiplyOperator, Int{big.NewInt(-1)})
+				return result, true, err
+			case percentOperator:
+				result, err := c.scalePolynomial(p, divideOperator, Int{big.NewInt(100)})
+				return result, true, err
+			}
+			return nil, false, nil
+		},
+		Call: func(v Value, args []Value) (Value, bool, error) {
+			p, ok := v.(Polynomial)
+			if !ok {
+				return nil, false, nil
+			}
+			if len(args) != 1 {
+				return nil, true, fmt.Errorf("a polynomial takes 1 argument, got %d", len(args))
+			}
+			result, err := c.evaluatePolynomial(p, args[0])
+			return result, true, err
+		},
+	}
+}
+
+// polynomialArgument returns the argument of a function of one polynomial.
+func polynomialArgument(args []Value) (Polynomial, error) {
+	if len(args) != 1 {
+		return Polynomial{}, fmt.Errorf("expected 1 argument, got %d", len(args))
+	}
+	p, ok := toPolynomial(args[0])
+	if !ok {
+		return Polynomial{}, fmt.Errorf("expected a polynomial, not %s", article(args[0].Kind()))
+	}
+	return p, nil
+}
+
+func (c *Calculator) polynomialBinary(operator string, a, b Value) (Value, bool, error) {
+	p, ok := toPolynomial(a)
+	if !ok {
+		return nil, false, nil
+	}
+	q, ok := toPolynomial(b)
+	if !ok {
+		return nil, false, nil
+	}
+	var result Value
+	var err error
+	switch operator {
+	case addOperator:
+		result, err = c.addPolynomials(p, q, addOperator)
+	case subtractOperator:
+		result, err = c.addPolynomials(p, q, subtractOperator)
+	case multiplyOperator:
+		result, err = c.multiplyPolynomials(p, q)
+	case divideOperator:
+		var remainder Polynomial
+		if result, remainder, err = c.dividePolynomials(p, q); err == nil && remainder.Degree() >= 0 {
+			err = fmt.Errorf("%s does not divide evenly by %s; use // and %% for the quotient and remainder", p, q)
+		}
+	case floorDivOperator:
+		result, _, err = c.dividePolynomials(p, q)
+	case percentOperator:
+		_, result, err = c.dividePolynomials(p, q)
+	case powerOperator:
+		result, err = c.polynomialPower(p, b)
+	default:
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, true, err
+	}
+	return result, true, nil
+}
+
+func (c *Calculator) addPolynomials(p, q Polynomial, operator string) (Polynomial, error) {
+	size := len(p.coefficients)
+	if len(q.coefficients) > size {
+		size = len(q.coefficients)
+	}
+	sum := make([]Value, size)
+	for i := range sum {
+		sum[i] = Value(Int{big.NewInt(0)})
+	}
+	for i, coefficient := range p.coefficients {
+		sum[size-len(p.coefficients)+i] = coefficient
+	}
+	for i, coefficient := range q.coefficients {
+		j := size - len(q.coefficients) + i
+		var err error
+		if sum[j], err = c.applyBinary(operator, sum[j], coefficient); err != nil {
+			return Polynomial{}, err
+		}
+	}
+	return newPolynomial(sum), nil
+}
+
+func (c *Calculator) multiplyPolynomials(p, q Polynomial) (Polynomial, error) {
+	if p.Degree() < 0 || q.Degree() < 0 {
+		return Polynomial{}, nil
+	}
+	product := make([]Value, len(p.coefficients)+len(q.coefficients)-1)
+	for i := range product {
+		product[i] = Value(Int{big.NewInt(0)})
+	}
+	for i, a := range p.coefficients {
+		for j, b := range q.coefficients {
+			term, err := c.applyBinary(multiplyOperator, a, b)
+			if err != nil {
+				return Polynomial{}, err
+			}
+			if product[i+j], err = c.applyBinary(addOperator, product[i+j], term); err != nil {
+				return Polynomial{}, err
+			}
+		}
+	}
+	return newPolynomial(product), nil
+}
+
+// scalePolynomial applies operator with the number x to every coefficient of p.
+func (c *Calculator) scalePolynomial(p Polynomial, operator string, x Value) (Polynomial, error) {
+	scaled := make([]Value, len(p.coefficients))
+	for i, coefficient := range p.coefficients {
+		var err error
+		if scaled[i], err = c.applyBinary(operator, coefficient, x); err != nil {
+			return Polynomial{}, err
+		}
+	}
+	return newPolynomial(scaled), nil
+}
+
+// dividePolynomials divides p by q by long division, returning the quotient
+// and the remainder.
+func (c *Calculator) dividePolynomials(p, q Polynomial) (Polynomial, Polynomial, error) {
+	if q.Degree() < 0 {
+		return Polynomial{}, Polynomial{}, ErrDivideByZero
+	}
+	if p.Degree() < q.Degree() {
+		return Polynomial{}, p, nil
+	}
+	remainder := append([]Value(nil), p.coefficients...)
+	quotient := make([]Value, len(p.coefficients)-len(q.coefficients)+1)
+	for i := range quotient {
+		factor, err := c.applyBinary(divideOperator, remainder[0], q.coefficients[0])
+		if err != nil {
+			return Polynomial{}, Polynomial{}, err
+		}
+		quotient[i] = factor
+		for j, coefficient := range q.coefficients {
+			term, err := c.applyBinary(multiplyOperator, factor, coefficient)
+			if err != nil {
+				return Polynomial{}, Polynomial{}, err
+			}
+			if remainder[j], err = c.applyBinary(subtractOperator, remainder[j], term); err != nil {
+				return Polynomial{}, Polynomial{}, err
+			}
+		}
+		// The leading term is gone, even where rounding leaves a trace of it.
+		remainder = remainder[1:]
+	}
+	return newPolynomial(quotient), newPolynomial(remainder), nil
+}
+
+func (c *Calculator) polynomialPower(p Polynomial, exponent Value) (Polynomial, error) {
+	n, ok := exponent.(Int)
+	if !ok || n.Sign() < 0 || n.Cmp(big.NewInt(maxPolynomialPower)) > 0 {
+		return Polynomial{}, fmt.Errorf("a polynomial can only be raised to a whole power from 0 to %d", maxPolynomialPower)
+	}
+	result := newPolynomial([]Value{Int{big.NewInt(1)}})
+	for i := int64(0); i < n.Int64(); i++ {
+		var err error
+		if result, err = c.multiplyPolynomials(result, p); err != nil {
+			return Polynomial{}, err
+		}
+	}
+	return result, nil
+}
+
+func (c *Calculator) derivative(p Polynomial) (Polynomial, error) {
+	if p.Degree() < 1 {
+		return Polynomial{}, nil
+	}
+	derivative := make([]Value, p.Degree())
+	for i := range derivative {
+		var err error
+		power := Int{big.NewInt(int64(p.Degree() - i))}
+		if derivative[i], err = c.applyBinary(multiplyOperator, p.coefficients[i], power); err != nil {
+			return Polynomial{}, err
+		}
+	}
+	return newPolynomial(derivative), nil
+}
+
+// evaluatePolynomial evaluates p at x by Horner's rule. x may itself be a
+// polynomial, which gives their composition.
+func (c *Calculator) evaluatePolynomial(p Polynomial, x Value) (Value, error) {
+	var result Value = Int{big.NewInt(0)}
+	for _, coefficient := range p.coefficients {
+		var err error
+		if result, err = c.applyBinary(multiplyOperator, result, x); err != nil {
+			return nil, err
+		}
+		if result, err = c.applyBinary(addOperator, result, coefficient); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,147 @@
+package calc
+
+import (
+	"fmt"
+	"strings"
+)
+
+// derivation is how a session variable got its value: the expression it was
+// assigned, if recorded, and the derivations of the variables and functions
+// that the expression used, as they were at the time. The derivation of a
+// function has no value, and its expression is the function's definition.
+type derivation struct {
+	expression string
+	value      Value
+	inputs     []input
+}
+
+// input is a variable or function that an expression used.
+type input struct {
+	name string
+	from *derivation
+}
+
+// derive returns the derivation of value from expression, along with the
+// variables and functions the expression used.
+func (c *Calculator) derive(expression string, value Value) *derivation {
+	d := &derivation{expression: expression, value: value}
+	d.inputs = c.usedInputs(expression, nil, map[string]*derivation{})
+	if c.derivations == nil {
+		c.derivations = map[string]*derivation{}
+	}
+	return d
+}
+
+// usedInputs returns the session variables and functions that expression
+// uses, other than the names in skip, with their derivations. The functions
+// are followed into the variables their bodies read, and functions holds the
+// derivations of those followed so far, so that a recursive function is
+// followed once.
+func (c *Calculator) usedInputs(expression string, skip map[string]bool, functions map[string]*derivation) []input {
+	squeezed, _ := squeeze(replaceWordOperators(expression))
+	tokens, _, _ := c.tokenize(squeezed)
+	var inputs []input
+	seen := map[string]bool{}
+	for _, token := range tokens {
+		used := strings.TrimSuffix(token, leftParen)
+		if seen[used] || skip[used] {
+			continue
+		}
+		if v, ok := c.Variables[used]; ok {
+			seen[used] = true
+			from := c.derivations[used]
+			if from == nil {
+				from = &derivation{value: v}
+			}
+			inputs = append(inputs, input{name: used, from: from})
+		} else if _, ok := c.Functions[used]; ok && used != token {
+			seen[used] = true
+			inputs = append(inputs, input{name: used, from: c.deriveFunction(used, functions)})
+		}
+	}
+	return inputs
+}
+
+// deriveFunction returns the derivation of the function name: its definition
+// and the session variables and functions its body and default values read,
+// other than its parameters and the variables it captured.
+func (c *Calculator) deriveFunction(name string, functions map[string]*derivation) *derivation {
+	if d, ok := functions[name]; ok {
+		return d
+	}
+	d := &derivation{}
+	functions[name] = d
+	var definitions []string
+	seen := map[string]bool{}
+	for _, f := range c.Functions[name] {
+		definitions = append(definitions, name+f.String())
+		skip := map[string]bool{name: true}
+		for _, param := range f.Params {
+			skip[param] = true
+		}
+		for captured := range f.Captured {
+			skip[captured] = true
+		}
+		for _, expression := range append([]string{f.Body}, f.Defaults...) {
+			for _, in := range c.usedInputs(expression, skip, functions) {
+				if !seen[in.name] {
+					seen[in.name] = true
+					d.inputs = append(d.inputs, in)
+				}
+			}
+		}
+	}
+	d.expression = strings.Join(definitions, "; ")
+	return d
+}
+
+// Why explains the value of the session variable name as the tree of
+// assignments it was derived from, each line giving a variable, its value,
+// and the expression that gave it, or a function the expression called and
+// its definition. Only assignments made while TrackProvenance was set are
+// recorded with the variables and functions they used, and others only give
+// the expression that defined the variable; a variable used more than once
+// is expanded the first time.
+func (c *Calculator) Why(name string) (string, error) {
+	value, ok := c.Variables[name]
+	if !ok {
+		return "", fmt.Errorf("undefined variable: %s", name)
+	}
+	d := c.derivations[name]
+	if d == nil {
+		d = &derivation{expression: c.definitions[name].expression, value: value}
+	}
+	var b strings.Builder
+	c.writeDerivation(&b, name, d, "", "", map[*derivation]bool{})
+	return strings.TrimSuffix(b.String(), "\n"), nil
+}
+
+// writeDerivation writes the line of the variable or function name, after
+// prefix, and the lines of its inputs below it, each after indent.
+func (c *Calculator) writeDerivation(b *strings.Builder, name string, d *derivation, prefix, indent string, seen map[*derivation]bool) {
+	if d.value == nil {
+		b.WriteString(prefix + d.expression)
+	} else {
+		b.WriteString(prefix + name + " = " + c.FormatValue(d.value))
+	}
+	switch {
+	case d.expression == "":
+		b.WriteString("\n")
+		return
+	case seen[d]:
+		b.WriteString(" (as above)\n")
+		return
+	}
+	seen[d] = true
+	if d.value != nil {
+		b.WriteString(" from " + d.expression)
+	}
+	b.WriteString("\n")
+	for i, in := range d.inputs {
+		branch, next := "├─ ", "│  "
+		if i == len(d.inputs)-1 {
+			branch, next = "└─ ", "   "
+		}
+		c.writeDerivation(b, in.name, in.from, indent+branch, indent+next, seen)
+	}
+}
@@ -0,0 +1,363 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// dimension holds the powers of the base quantities a unit measures: length,
+// mass, time, electric current, temperature, money, and angle.
+type dimension [7]int
+
+var (
+	length      = dimension{1, 0, 0, 0, 0}
+	mass        = dimension{0, 1, 0, 0, 0}
+	duration    = dimension{0, 0, 1, 0, 0}
+	current     = dimension{0, 0, 0, 1, 0}
+	temperature = dimension{0, 0, 0, 0, 1}
+	area        = dimension{2, 0, 0, 0, 0}
+	volume      = dimension{3, 0, 0, 0, 0}
+	speed       = dimension{1, 0, -1, 0, 0}
+	force       = dimension{1, 1, -2, 0, 0}
+	energy      = dimension{2, 1, -2, 0, 0}
+	power       = dimension{2, 1, -3, 0, 0}
+	pressure    = dimension{-1, 1, -2, 0, 0}
+	frequency   = dimension{0, 0, -1, 0, 0}
+	voltage     = dimension{2, 1, -3, -1, 0}
+	resistance  = dimension{2, 1, -3, -2, 0}
+	money       = dimension{0, 0, 0, 0, 0, 1}
+	angle       = dimension{0, 0, 0, 0, 0, 0, 1}
+)
+
+// dimensionNames name the dimensions in errors about incompatible units.
+var dimensionNames = map[dimension]string{
+	length: "length", mass: "mass", duration: "time", current: "current", temperature: "temperature",
+	area: "area", volume: "volume", speed: "speed", {1, 0, -2, 0, 0}: "acceleration", force: "force",
+	energy: "energy", power: "power", pressure: "pressure", frequency: "frequency", voltage: "voltage",
+	resistance: "resistance", money: "money", angle: "angle",
+}
+
+// measure is a unit of a quantity: factor of the SI unit of its dimension.
+type measure struct {
+	factor    float64
+	dimension dimension
+}
+
+// measures are the units that quantities can be written in, by symbol.
+var measures = map[string]measure{
+	"m": {1, length}, "km": {1e3, length}, "cm": {1e-2, length}, "mm": {1e-3, length}, "um": {1e-6, length}, "nm": {1e-9, length},
+	"mi": {1609.344, length}, "yd": {0.9144, length}, "ft": {0.3048, length}, "inch": {0.0254, length}, "nmi": {1852, length},
+	"au": {149597870700, length}, "ly": {9460730472580800, length},
+	"kg": {1, mass}, "g": {1e-3, mass}, "mg": {1e-6, mass}, "tonne": {1e3, mass}, "lb": {0.45359237, mass}, "oz": {0.028349523125, mass},
+	"s": {1, duration}, "ms": {1e-3, duration}, "us": {1e-6, duration}, "ns": {1e-9, duration}, "min": {60, duration}, "h": {3600, duration},
+	"day": {86400, duration}, "week": {604800, duration}, "yr": {31557600, duration},
+	"mph": {0.44704, speed}, "knot": {1852.0 / 3600, speed},
+	"ha": {1e4, area}, "acre": {4046.8564224, area},
+	"l": {1e-3, volume}, "ml": {1e-6, volume}, "gal": {3.785411784e-3, volume}, "quart": {9.46352946e-4, volume}, "pint": {4.73176473e-4, volume},
+	"cup": {2.365882365e-4, volume}, "floz": {2.95735295625e-5, volume}, "tsp": {4.92892159375e-6, volume}, "tbsp": {1.478676478125e-5, volume},
+	"N": {1, force}, "lbf": {4.4482216152605, force},
+	"J": {1, energy}, "kJ": {1e3, energy}, "cal": {4.184, energy}, "kcal": {4184, energy}, "Wh": {3600, energy}, "kWh": {3.6e6, energy},
+	"W": {1, power}, "kW": {1e3, power}, "MW": {1e6, power}, "hp": {745.69987158227022, power},
+	"Pa": {1, pressure}, "kPa": {1e3, pressure}, "bar": {1e5, pressure}, "psi": {6894.757293168361, pressure}, "atm": {101325, pressure},
+	"Hz": {1, frequency}, "kHz": {1e3, frequency}, "MHz": {1e6, frequency}, "GHz": {1e9, frequency},
+	"A": {1, current}, "mA": {1e-3, current}, "V": {1, voltage}, "mV": {1e-3, voltage}, "kV": {1e3, voltage}, "ohm": {1, resistance},
+	"K":   {1, temperature},
+	"rad": {1, angle}, "deg": {math.Pi / 180, angle}, "grad": {math.Pi / 200, angle}, "turn": {2 * math.Pi, angle},
+}
+
+// measureAliases are the names that can be written for the symbol of a unit.
+var measureAliases = map[string]string{
+	"meter": "m", "meters": "m", "metre": "m", "metres": "m", "kilometer": "km", "kilometers": "km", "mile": "mi", "miles": "mi",
+	"yard": "yd", "yards": "yd", "foot": "ft", "feet": "ft", "inches": "inch",
+	"gram": "g", "grams": "g", "gramme": "g", "grammes": "g", "kilogram": "kg", "kilograms": "kg", "kilo": "kg", "kilos": "kg",
+	"milligram": "mg", "milligrams": "mg", "tonnes": "tonne", "pound": "lb", "pounds": "lb", "lbs": "lb", "ounce": "oz", "ounces": "oz",
+	"sec": "s", "second": "s", "seconds": "s", "minute": "min", "minutes": "min", "hr": "h", "hour": "h", "hours": "h",
+	"days": "day", "weeks": "week", "year": "yr", "years": "yr", "knots": "knot", "kn": "knot", "acres": "acre",
+	"L": "l", "liter": "l", "liters": "l", "litre": "l", "litres": "l", "mL": "ml", "milliliter": "ml", "milliliters": "ml",
+	"millilitre": "ml", "millilitres": "ml", "gallon": "gal", "gallons": "gal", "quarts": "quart", "qt": "quart", "pints": "pint", "pt": "pint",
+	"cups": "cup", "fl oz": "floz", "fluid ounce": "floz", "fluid ounces": "floz", "teaspoon": "tsp", "teaspoons": "tsp",
+	"tablespoon": "tbsp", "tablespoons": "tbsp",
+	"newton": "N", "newtons": "N", "joule": "J", "joules": "J", "watt": "W", "watts": "W", "kelvin": "K", "hertz": "Hz",
+	"radian": "rad", "radians": "rad", "degree": "deg", "degrees": "deg", "gradian": "grad", "gradians": "grad", "gon": "grad", "turns": "turn", "rev": "turn", "revs": "turn",
+}
+
+// unitPower is a unit raised to a power, as s^-2 in m/s^2. It keeps the
+// measure of the unit, which for a currency is its rate when it was read.
+type unitPower struct {
+	symbol  string
+	power   int
+	measure measure
+}
+
+// Quantity is an amount measured in units, such as 5 km or 60 km/h. Its
+// units keep the order they were written in, and a quantity whose units
+// cancel out is a plain number.
+type Quantity struct {
+	amount float64
+	units  []unitPower
+	// display, when set, is how the quantity is shown, as the countdown
+	// in 2 months, 8 days (69 days) of until. Arithmetic drops it.
+	display string
+}
+
+func (q Quantity) Kind() string { return "quantity" }
+
+func (q Quantity) String() string {
+	return strconv.FormatFloat(q.amount, 'g', -1, 64) + " " + q.Unit()
+}
+
+// Expression writes q as an expression that gives it, as 60*km/h.
+func (q Quantity) Expression() string {
+	return strconv.FormatFloat(q.amount, 'g', -1, 64) + "*" + q.unitText("*")
+}
+
+// Unit writes the units of q, as km/h or kg·m/s^2.
+func (q Quantity) Unit() string {
+	return q.unitText("·")
+}
+
+func (q Quantity) unitText(separator string) string {
+	var numerator, denominator []string
+	for _, u := range q.units {
+		if u.power > 0 {
+			numerator = append(numerator, writePower(u.symbol, u.power))
+		} else {
+			denominator = append(denominator, writePower(u.symbol, -u.power))
+		}
+	}
+	switch {
+	case len(numerator) == 0:
+		for i, u := range q.units {
+			denominator[i] = writePower(u.symbol, u.power)
+		}
+		return strings.Join(denominator, separator)
+	case len(denominator) == 1:
+		return strings.Join(numerator, separator) + "/" + denominator[0]
+	case len(denominator) > 1:
+		return strings.Join(numerator, separator) + "/(" + strings.Join(denominator, separator) + ")"
+	}
+	return strings.Join(numerator, separator)
+}
+
+// writePower writes a unit raised to a power, as s^2.
+func writePower(symbol string, power int) string {
+	if power == 1 {
+		return symbol
+	}
+	return symbol + "^" + strconv.Itoa(power)
+}
+
+// factor returns the number of SI units in one of the units of q.
+func (q Quantity) factor() float64 {
+	factor := 1.0
+	for _, u := range q.units {
+		factor *= math.Pow(u.measure.factor, float64(u.power))
+	}
+	return factor
+}
+
+func (q Quantity) dimension() dimension {
+	var d dimension
+	for _, u := range q.units {
+		for i, power := range u.measure.dimension {
+			d[i] += power * u.power
+		}
+	}
+	return d
+}
+
+// describe names the units of q and what they measure, as km (length).
+func (q Quantity) describe() string {
+	if len(q.units) == 0 {
+		return "a number without units"
+	}
+	if name, ok := dimensionNames[q.dimension()]; ok {
+		return q.Unit() + " (" + name + ")"
+	}
+	return q.Unit()
+}
+
+// lookupMeasure returns 1 of the unit name as a quantity. The currencies are
+// units too, by their codes such as USD.
+func (c *Calculator) lookupMeasure(name string) (Quantity, bool) {
+	if symbol, ok := measureAliases[name]; ok {
+		name = symbol
+	}
+	m, ok := measures[name]
+	if !ok {
+		m, ok = c.currencyMeasure(name)
+	}
+	if !ok {
+		return Quantity{}, false
+	}
+	return Quantity{amount: 1, units: []unitPower{{name, 1, m}}}, true
+}
+
+// toQuantity returns a quantity or a real number as a quantity, a number
+// having no units.
+func toQuantity(v Value) (Quantity, bool) {
+	switch v := v.(type) {
+	case Quantity:
+		return v, true
+	case Bool:
+		return Quantity{}, false
+	}
+	f, ok := Real(v)
+	return Quantity{amount: f}, ok
+}
+
+// simplify drops the units of q raised to the power 0, returning a plain
+// number when none are left.
+func (q Quantity) simplify() Value {
+	var units []unitPower
+	for _, u := range q.units {
+		if u.power != 0 {
+			units = append(units, u)
+		}
+	}
+	if len(units) == 0 {
+		return Float(q.amount)
+	}
+	return Quantity{amount: q.amount, units: units}
+}
+
+// multiplyQuantities multiplies p and q. A unit of q that measures the same
+// thing as a unit of p is converted to it, so h * km/h is in km.
+func multiplyQuantities(p, q Quantity) Value {
+	result := Quantity{amount: p.amount * q.amount, units: append([]unitPower(nil), p.units...)}
+	for _, u := range q.units {
+		i := 0
+		for i < len(result.units) && result.units[i].measure.dimension != u.measure.dimension {
+			i++
+		}
+		if i == len(result.units) {
+			result.units = append(result.units, u)
+			continue
+		}
+		ratio := u.measure.factor / result.units[i].measure.factor
+		result.amount *= math.Pow(ratio, float64(u.power))
+		result.units[i].power += u.power
+	}
+	return result.simplify()
+}
+
+// inverse returns 1/q.
+func (q Quantity) inverse() Quantity {
+	result := Quantity{amount: 1 / q.amount}
+	for _, u := range q.units {
+		result.units = append(result.units, unitPower{u.symbol, -u.power, u.measure})
+	}
+	return result
+}
+
+// convertQuantity writes q in the units of target, whose amount must be 1.
+func convertQuantity(q Quantity, target Quantity) (Value, error) {
+	if target.amount != 1 || len(target.units) == 0 {
+		return nil, fmt.Errorf("in converts to units such as km/h, not %s", target)
+	}
+	if len(q.units) == 0 {
+		return nil, fmt.Errorf("%s has no units to convert to %s", strconv.FormatFloat(q.amount, 'g', -1, 64), target.Unit())
+	}
+	if q.dimension() != target.dimension() {
+		return nil, fmt.Errorf("%w: cannot convert %s to %s", ErrIncompatibleUnits, q.describe(), target.describe())
+	}
+	return Quantity{amount: q.amount * q.factor() / target.factor(), units: target.units}, nil
+}
+
+// quantityPower raises q to the power exponent, which must leave whole powers
+// of its units.
+func quantityPower(q Quantity, exponent Value) (Value, error) {
+	e, ok := Real(exponent)
+	if !ok {
+		return nil, fmt.Errorf("%s can only be raised to a number, not %s", q.Unit(), article(exponent.Kind()))
+	}
+	result := Quantity{amount: math.Pow(q.amount, e)}
+	for _, u := range q.units {
+		power := float64(u.power) * e
+		if math.Abs(power-math.Round(power)) > 1e-9 {
+			return nil, fmt.Errorf("%w: %s^%g does not have whole powers of its units", ErrIncompatibleUnits, q.Unit(), e)
+		}
+		result.units = append(result.units, unitPower{u.symbol, int(math.Round(power)), u.measure})
+	}
+	return result.simplify(), nil
+}
+
+// quantityExtension adds quantities with units to c: 5 km + 300 m is 5.3 km,
+// 2 h * 30 km/h is 60 km, and 60 mph in km/h converts to km/h. Adding or
+// converting units that measure different things is an error.
+func (c *Calculator) quantityExtension() Extension {
+	return Extension{
+		Name:   "quantity",
+		Binary: c.quantityBinary,
+		Unary: func(operator string, a Value) (Value, bool, error) {
+			q, ok := a.(Quantity)
+			if !ok || operator != subtractOperator {
+				return nil, false, nil
+			}
+			return Quantity{amount: -q.amount, units: q.units}, true, nil
+		},
+	}
+}
+
+func (c *Calculator) quantityBinary(operator string, a, b Value) (Value, bool, error) {
+	p, ok := toQuantity(a)
+	if !ok {
+		return nil, false, nil
+	}
+	q, ok := toQuantity(b)
+	if !ok {
+		return nil, false, nil
+	}
+	switch operator {
+	case multiplyOperator:
+		return multiplyQuantities(p, q), true, nil
+	case divideOperator:
+		if q.amount == 0 {
+			return nil, true, ErrDivideByZero
+		}
+		return multiplyQuantities(p, q.inverse()), true, nil
+	case powerOperator:
+		if _, ok := b.(Quantity); ok {
+			return nil, true, fmt.Errorf("%w: the exponent of ^ cannot have units", ErrIncompatibleUnits)
+		}
+		result, err := quantityPower(p, b)
+		return result, true, err
+	case displayToken(convertOperator):
+		result, err := convertQuantity(p, q)
+		return result, true, err
+	}
+
+	if isComparison(operator) {
+		if p.dimension() != q.dimension() {
+			return nil, true, fmt.Errorf("%w: cannot compare %s and %s", ErrIncompatibleUnits, p.describe(), q.describe())
+		}
+		result, err := c.compareValues(operator, Float(p.amount*p.factor()), Float(q.amount*q.factor()))
+		return result, true, err
+	}
+	verb := map[string]string{addOperator: "add", subtractOperator: "subtract", floorDivOperator: "divide", percentOperator: "take the remainder of"}[operator]
+	if verb == "" {
+		return nil, false, nil
+	}
+	if p.dimension() != q.dimension() || len(p.units) == 0 || len(q.units) == 0 {
+		return nil, true, fmt.Errorf("%w: cannot %s %s and %s", ErrIncompatibleUnits, verb, p.describe(), q.describe())
+	}
+	other := q.amount * q.factor() / p.factor()
+	switch operator {
+	case addOperator:
+		return Quantity{amount: p.amount + other, units: p.units}, true, nil
+	case subtractOperator:
+		return Quantity{amount: p.amount - other, units: p.units}, true, nil
+	case floorDivOperator:
+		if other == 0 {
+			return nil, true, ErrDivideByZero
+		}
+		return Float(math.Floor(p.amount / other)), true, nil
+	default:
+		if other == 0 {
+			return nil, true, ErrDivideByZero
+		}
+		return Quantity{amount: math.Mod(p.amount, other), units: p.units}, true, nil
+	}
+}
@@ -0,0 +1,265 @@
+// Package quaternion is an optional pack that adds quaternions to a
+// calculator, for rotations in graphics and robotics:
+//
+//	c := calc.New()
+//	c.Register(quaternion.Extension())
+//	c.EvaluateInput("rotate(axisangle(0, 0, 1, pi/2), 1, 0, 0)")
+package quaternion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// Quaternion is the value w + xi + yj + zk.
+type Quaternion struct {
+	W, X, Y, Z float64
+}
+
+func (q Quaternion) Kind() string { return "quaternion" }
+
+// String writes the quaternion with 12 significant digits, showing parts that
+// are rounding noise next to the largest one as 0.
+func (q Quaternion) String() string {
+	parts := []float64{q.W, q.X, q.Y, q.Z}
+	largest := 0.0
+	for _, part := range parts {
+		largest = math.Max(largest, math.Abs(part))
+	}
+	format := func(x float64) string {
+		if math.Abs(x) < largest*1e-12 {
+			x = 0
+		}
+		return strconv.FormatFloat(x, 'g', 12, 64)
+	}
+	s := format(q.W)
+	for i, part := range parts[1:] {
+		sign := " + "
+		if part < 0 && format(math.Abs(part)) != "0" {
+			sign = " - "
+		}
+		s += sign + format(math.Abs(part)) + "ijk"[i:i+1]
+	}
+	return s
+}
+
+var errZero = errors.New("the quaternion is zero")
+
+// Extension returns the quaternion pack: quat(w, x, y, z) or w + xi + yj + zk
+// writes a quaternion, with the units i, j, and k, and +, -, *, and / work
+// between quaternions and real numbers, with ^ raising a quaternion to a
+// whole power and == and != comparing them. The functions
+// conj, norm, unit, and inverse give the conjugate, the length, the quaternion
+// scaled to length 1, and the multiplicative inverse. axisangle(x, y, z, angle)
+// is the rotation by angle radians around the axis (x, y, z), and
+// rotate(q, x, y, z) rotates the vector (x, y, z) by q, giving the result as
+// the quaternion 0 + x'i + y'j + z'k.
+func Extension() calc.Extension {
+	return calc.Extension{
+		Name: "quaternion",
+		Functions: map[string]func([]calc.Value) (calc.Value, error){
+			"quat": func(args []calc.Value) (calc.Value, error) {
+				parts, err := reals(args, 4)
+				if err != nil {
+					return nil, err
+				}
+				return Quaternion{parts[0], parts[1], parts[2], parts[3]}, nil
+			},
+			"conj": unary(func(q Quaternion) (calc.Value, error) {
+				return q.conjugate(), nil
+			}),
+			"norm": unary(func(q Quaternion) (calc.Value, error) {
+				return calc.Float(q.norm()), nil
+			}),
+			"unit": unary(func(q Quaternion) (calc.Value, error) {
+				n := q.norm()
+				if n == 0 {
+					return nil, errZero
+				}
+				return q.scale(1 / n), nil
+			}),
+			"inverse": unary(func(q Quaternion) (calc.Value, error) {
+				return q.inverse()
+			}),
+			"axisangle": func(args []calc.Value) (calc.Value, error) {
+				parts, err := reals(args, 4)
+				if err != nil {
+					return nil, err
+				}
+				axis := Quaternion{0, parts[0], parts[1], parts[2]}
+				n := axis.norm()
+				if n == 0 {
+					return nil, errors.New("the axis is zero")
+				}
+				sin, cos := math.Sincos(parts[3] / 2)
+				return axis.scale(sin / n).add(Quaternion{W: cos}), nil
+			},
+			"rotate": func(args []calc.Value) (calc.Value, error) {
+				if len(args) != 4 {
+					return nil, fmt.Errorf("expected a quaternion and 3 coordinates, got %d arguments", len(args))
+				}
+				q, ok := toQuaternion(args[0])
+				if !ok {
+					return nil, fmt.Errorf("expected a quaternion, got %s", args[0])
+				}
+				vector, err := reals(args[1:], 3)
+				if err != nil {
+					return nil, err
+				}
+				n := q.norm()
+				if n == 0 {
+					return nil, errZero
+				}
+				q = q.scale(1 / n)
+				v := Quaternion{0, vector[0], vector[1], vector[2]}
+				return q.multiply(v).multiply(q.conjugate()), nil
+			},
+		},
+		Constants: map[string]calc.Value{
+			"i": Quaternion{X: 1},
+			"j": Quaternion{Y: 1},
+			"k": Quaternion{Z: 1},
+		},
+		Binary: binary,
+		Unary: func(operator string, a calc.Value) (calc.Value, bool, error) {
+			q, ok := a.(Quaternion)
+			switch {
+			case !ok:
+				return nil, false, nil
+			case operator == "-":
+				return q.scale(-1), true, nil
+			case operator == "%":
+				return q.scale(0.01), true, nil
+			}
+			return nil, false, nil
+		},
+	}
+}
+
+func binary(operator string, a, b calc.Value) (calc.Value, bool, error) {
+	p, ok := toQuaternion(a)
+	if !ok {
+		return nil, false, nil
+	}
+	q, ok := toQuaternion(b)
+	if !ok {
+		return nil, false, nil
+	}
+	switch operator {
+	case "==":
+		return calc.Bool(p == q), true, nil
+	case "!=":
+		return calc.Bool(p != q), true, nil
+	case "+":
+		return p.add(q), true, nil
+	case "-":
+		return p.add(q.scale(-1)), true, nil
+	case "*":
+		return p.multiply(q), true, nil
+	case "/":
+		inverse, err := q.inverse()
+		if err != nil {
+			return nil, true, calc.ErrDivideByZero
+		}
+		return p.multiply(inverse), true, nil
+	case "^":
+		n, ok := calc.Real(b)
+		if !ok || n != math.Trunc(n) || math.Abs(n) > 1<<20 {
+			return nil, true, errors.New("a quaternion can only be raised to a whole power")
+		}
+		if n < 0 {
+			inverse, err := p.inverse()
+			if err != nil {
+				return nil, true, calc.ErrDivideByZero
+			}
+			p, n = inverse, -n
+		}
+		result := Quaternion{W: 1}
+		for ; n > 0; n-- {
+			result = result.multiply(p)
+		}
+		return result, true, nil
+	}
+	return nil, false, nil
+}
+
+// toQuaternion converts a quaternion, a complex number, or a real number to
+// a quaternion.
+func toQuaternion(v calc.Value) (Quaternion, bool) {
+	switch v := v.(type) {
+	case Quaternion:
+		return v, true
+	case calc.Complex:
+		return Quaternion{W: real(v), X: imag(v)}, true
+	}
+	x, ok := calc.Real(v)
+	return Quaternion{W: x}, ok
+}
+
+// reals converts the arguments of a function that takes count real numbers.
+func reals(args []calc.Value, count int) ([]float64, error) {
+	if len(args) != count {
+		return nil, fmt.Errorf("expected %d arguments, got %d", count, len(args))
+	}
+	parts := make([]float64, count)
+	for i, arg := range args {
+		x, ok := calc.Real(arg)
+		if !ok {
+			return nil, fmt.Errorf("expected a real number, got %s", arg)
+		}
+		parts[i] = x
+	}
+	return parts, nil
+}
+
+// unary wraps a function of one quaternion or real number.
+func unary(f func(Quaternion) (calc.Value, error)) func([]calc.Value) (calc.Value, error) {
+	return func(args []calc.Value) (calc.Value, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		}
+		q, ok := toQuaternion(args[0])
+		if !ok {
+			return nil, fmt.Errorf("expected a quaternion, got %s", args[0])
+		}
+		return f(q)
+	}
+}
+
+func (q Quaternion) add(p Quaternion) Quaternion {
+	return Quaternion{q.W + p.W, q.X + p.X, q.Y + p.Y, q.Z + p.Z}
+}
+
+func (q Quaternion) scale(s float64) Quaternion {
+	return Quaternion{q.W * s, q.X * s, q.Y * s, q.Z * s}
+}
+
+// multiply returns the Hamilton product qp.
+func (q Quaternion) multiply(p Quaternion) Quaternion {
+	return Quaternion{
+		q.W*p.W - q.X*p.X - q.Y*p.Y - q.Z*p.Z,
+		q.W*p.X + q.X*p.W + q.Y*p.Z - q.Z*p.Y,
+		q.W*p.Y - q.X*p.Z + q.Y*p.W + q.Z*p.X,
+		q.W*p.Z + q.X*p.Y - q.Y*p.X + q.Z*p.W,
+	}
+}
+
+func (q Quaternion) conjugate() Quaternion {
+	return Quaternion{q.W, -q.X, -q.Y, -q.Z}
+}
+
+func (q Quaternion) norm() float64 {
+	return math.Sqrt(q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z)
+}
+
+func (q Quaternion) inverse() (Quaternion, error) {
+	n := q.W*q.W + q.X*q.X + q.Y*q.Y + q.Z*q.Z
+	if n == 0 {
+		return Quaternion{}, errZero
+	}
+	return q.conjugate().scale(1 / n), nil
+}
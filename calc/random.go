@@ -0,0 +1,110 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strconv"
+	"time"
+)
+
+// Seed seeds the random numbers of rand, randint, and randnorm, so that the
+// same seed gives the same numbers again. Without a seed they differ from run
+// to run.
+func (c *Calculator) Seed(seed int64) {
+	c.rng = rand.New(rand.NewSource(seed))
+}
+
+// random returns the random number generator of the session, seeding it from
+// the clock the first time, and notes that the input drew random numbers.
+func (c *Calculator) random() *rand.Rand {
+	if c.rng == nil {
+		c.Seed(time.Now().UnixNano())
+	}
+	c.drewRandom = true
+	return c.rng
+}
+
+// seedRandom handles seed(42), which seeds the random numbers of the session.
+func (c *Calculator) seedRandom(args []string) (string, error) {
+	if len(args) != 1 || args[0] == "" {
+		return "", fmt.Errorf("seed expects a whole number: seed(42)")
+	}
+	value, err := c.EvaluateValue(args[0])
+	if err != nil {
+		return "", err
+	}
+	n, ok := wholeNumber(value)
+	if !ok || !n.IsInt64() {
+		return "", fmt.Errorf("seed expects a whole number, not %s", c.FormatValue(value))
+	}
+	c.Seed(n.Int64())
+	return "random numbers seeded with " + strconv.FormatInt(n.Int64(), 10), nil
+}
+
+// randomExtension provides rand() and rand(a, b) for a number in [0, 1) or
+// [a, b), randint(a, b) for a whole number from a to b, and randnorm(mu,
+// sigma) for a normally distributed one.
+func (c *Calculator) randomExtension() Extension {
+	bounds := func(name string, args []Value) (float64, float64, error) {
+		if len(args) != 2 {
+			return 0, 0, fmt.Errorf("expected 2 arguments, got %d", len(args))
+		}
+		a, err := realArgument(name, args[0])
+		if err != nil {
+			return 0, 0, err
+		}
+		b, err := realArgument(name, args[1])
+		if err != nil {
+			return 0, 0, err
+		}
+		return a, b, nil
+	}
+	return Extension{
+		Name: "random",
+		Functions: map[string]func([]Value) (Value, error){
+			"rand": func(args []Value) (Value, error) {
+				if len(args) == 0 {
+					return Float(c.random().Float64()), nil
+				}
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected no arguments or 2, got %d", len(args))
+				}
+				a, b, err := bounds("rand", args)
+				if err != nil {
+					return nil, err
+				}
+				if a >= b {
+					return nil, fmt.Errorf("the lower bound %s must be less than the upper bound %s", c.Format(a), c.Format(b))
+				}
+				return Float(a + (b-a)*c.random().Float64()), nil
+			},
+			"randint": func(args []Value) (Value, error) {
+				a, b, err := bounds("randint", args)
+				if err != nil {
+					return nil, err
+				}
+				low, high := math.Ceil(a), math.Floor(b)
+				if low > high {
+					return nil, fmt.Errorf("there is no whole number from %s to %s", c.Format(a), c.Format(b))
+				}
+				if high-low >= math.MaxInt64 {
+					return nil, fmt.Errorf("the range from %s to %s is too large", c.Format(a), c.Format(b))
+				}
+				n := int64(low) + c.random().Int63n(int64(high-low)+1)
+				return Int{big.NewInt(n)}, nil
+			},
+			"randnorm": func(args []Value) (Value, error) {
+				mu, sigma, err := bounds("randnorm", args)
+				if err != nil {
+					return nil, err
+				}
+				if sigma < 0 {
+					return nil, fmt.Errorf("%w: the standard deviation %s is negative", ErrDomain, c.Format(sigma))
+				}
+				return Float(mu + sigma*c.random().NormFloat64()), nil
+			},
+		},
+	}
+}
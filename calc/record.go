@@ -0,0 +1,179 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// fieldPrefix begins the name of a field of a record, as in r.slope.
+const fieldPrefix = "."
+
+// Record is a result with named fields, such as the statistics given by
+// summary(2, 4, 6). A field is read with a dot, as in summary(2, 4, 6).mean or
+// r.mean for a record stored in r.
+type Record struct {
+	names  []string
+	values map[string]Value
+	// display, when set, is how the record is shown, as the age
+	// 36 years, 6 months, 5 days of age.
+	display string
+}
+
+// newRecord returns a record with the fields names, in that order, holding
+// values.
+func newRecord(names []string, values []Value) Record {
+	r := Record{names: names, values: map[string]Value{}}
+	for i, name := range names {
+		r.values[name] = values[i]
+	}
+	return r
+}
+
+func (r Record) Kind() string { return "record" }
+
+func (r Record) String() string {
+	parts := make([]string, len(r.names))
+	for i, name := range r.names {
+		parts[i] = name + ": " + r.values[name].String()
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+// Fields returns the names of the fields of r, in order.
+func (r Record) Fields() []string {
+	return append([]string(nil), r.names...)
+}
+
+// Field returns the value of the field name of r.
+func (r Record) Field(name string) (Value, bool) {
+	v, ok := r.values[name]
+	return v, ok
+}
+
+// formatRecord shows r with one field on each line, its values lined up and
+// indented by indent.
+func (c *Calculator) formatRecord(r Record, indent string) string {
+	width := 0
+	for _, name := range r.names {
+		if len(name) > width {
+			width = len(name)
+		}
+	}
+	var b strings.Builder
+	b.WriteString("{\n")
+	for i, name := range r.names {
+		b.WriteString(indent + "  " + name + ":" + strings.Repeat(" ", width-len(name)+1))
+		if nested, ok := r.values[name].(Record); ok {
+			b.WriteString(c.formatRecord(nested, indent+"  "))
+		} else {
+			b.WriteString(c.FormatValue(r.values[name]))
+		}
+		if i < len(r.names)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + "}")
+	return b.String()
+}
+
+// isField reports whether token reads a field of the record before it.
+func isField(token string) bool {
+	return len(token) > len(fieldPrefix) && strings.HasPrefix(token, fieldPrefix) && identifierRegex.MatchString(token[len(fieldPrefix):])
+}
+
+// field returns the field name of v, which must be a record.
+func (c *Calculator) field(v Value, name string) (Value, error) {
+	r, ok := v.(Record)
+	if !ok {
+		return nil, fmt.Errorf("%s has no fields, so .%s cannot be read", article(v.Kind()), name)
+	}
+	value, ok := r.Field(name)
+	if !ok {
+		return nil, fmt.Errorf("the record has no field %s; its fields are %s", name, strings.Join(r.names, ", "))
+	}
+	return value, nil
+}
+
+// recordExtension adds the functions that give records.
+func (c *Calculator) recordExtension() Extension {
+	return Extension{
+		Name: "record",
+		Functions: map[string]func([]Value) (Value, error){
+			"summary": func(args []Value) (Value, error) {
+				args = flattenLists(args)
+				if len(args) < 2 {
+					return nil, fmt.Errorf("expected at least 2 values, got %d", len(args))
+				}
+				numbers := make([]float64, len(args))
+				for i, arg := range args {
+					if !isReal(arg) {
+						return nil, fmt.Errorf("the values must be real numbers, not %s", article(arg.Kind()))
+					}
+					numbers[i] = toFloat(arg)
+				}
+				names := []string{"count", "sum", "mean", "median", "min", "max", "stddev"}
+				values := []Value{Int{big.NewInt(int64(len(numbers)))}}
+				for _, statistic := range []func([]float64) (float64, error){sum, mean, median, extreme(math.Min), extreme(math.Max), stddev} {
+					result, _ := statistic(numbers)
+					values = append(values, Float(result))
+				}
+				return newRecord(names, values), nil
+			},
+			"linreg": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected a list of x values and a list of y values, as in linreg([1, 2, 3], [2, 4, 7])")
+				}
+				var lists [2][]float64
+				for i, arg := range args {
+					l, ok := arg.(List)
+					if !ok {
+						return nil, fmt.Errorf("expected a list of x values and a list of y values, not %s", article(arg.Kind()))
+					}
+					numbers, err := realList("linreg", l)
+					if err != nil {
+						return nil, err
+					}
+					lists[i] = numbers
+				}
+				slope, intercept, r2, err := linearRegression(lists[0], lists[1])
+				if err != nil {
+					return nil, err
+				}
+				return newRecord([]string{"slope", "intercept", "r2"}, []Value{Float(slope), Float(intercept), Float(r2)}), nil
+			},
+		},
+	}
+}
+
+// linearRegression fits the line y = slope*x + intercept to the points (xs,
+// ys) by least squares, along with its coefficient of determination r2. A fit
+// to points with the same y everywhere is perfect.
+func linearRegression(xs, ys []float64) (float64, float64, float64, error) {
+	if len(xs) != len(ys) {
+		return 0, 0, 0, fmt.Errorf("got %d x values but %d y values", len(xs), len(ys))
+	}
+	if len(xs) < 2 {
+		return 0, 0, 0, fmt.Errorf("expected at least 2 points, got %d", len(xs))
+	}
+	meanX, _ := mean(xs)
+	meanY, _ := mean(ys)
+	var sxx, sxy, syy float64
+	for i, x := range xs {
+		dx, dy := x-meanX, ys[i]-meanY
+		sxx += dx * dx
+		sxy += dx * dy
+		syy += dy * dy
+	}
+	if sxx == 0 {
+		return 0, 0, 0, fmt.Errorf("the x values must not all be the same")
+	}
+	slope := sxy / sxx
+	r2 := 1.0
+	if syy != 0 {
+		r2 = sxy * sxy / (sxx * syy)
+	}
+	return slope, meanY - slope*meanX, r2, nil
+}
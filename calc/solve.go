@@ -0,0 +1,259 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"math/cmplx"
+	"sort"
+)
+
+// solveName is the name of solve(equation, x), whose equation is evaluated
+// with x as an unknown.
+const solveName = "solve"
+
+// solveLimit bounds the values searched for the roots of an equation that is
+// not linear or quadratic, and nearLimit the values searched first.
+const (
+	solveLimit = 1e6
+	nearLimit  = 10
+)
+
+// evaluateSolve evaluates solve(x^2 - 4 = 0, x), giving the list of the
+// values of the unknown that satisfy the equation. Linear and quadratic
+// equations are solved exactly, and others numerically, finding their real
+// roots between -nearLimit and nearLimit or, when there are none, between
+// -solveLimit and solveLimit. Without an = the expression is solved for zero,
+// and without a name the unknown is x.
+func (c *Calculator) evaluateSolve(n callNode) (Value, error) {
+	name := "x"
+	if len(n.args) == 2 {
+		name = n.args[1].(nameNode).name
+	}
+	tree := n.args[0]
+
+	defer c.bindUnknown(name, newPolynomial([]Value{Int{big.NewInt(1)}, Int{big.NewInt(0)}}))()
+	var roots []Value
+	var err error
+	if p, ok := c.polynomialOf(tree); ok && p.Degree() <= 2 {
+		roots, err = c.solvePolynomial(p, name)
+	} else {
+		roots, err = c.solveNumerically(tree, name)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return List(roots), nil
+}
+
+// polynomialOf evaluates tree with the unknown as the polynomial x and
+// reports whether it is a polynomial in it.
+func (c *Calculator) polynomialOf(tree node) (Polynomial, bool) {
+	v, err := c.evaluateNode(tree)
+	if err != nil {
+		return Polynomial{}, false
+	}
+	return toPolynomial(v)
+}
+
+// solvePolynomial gives the roots of a polynomial of degree 2 at most,
+// exactly where they are rational.
+func (c *Calculator) solvePolynomial(p Polynomial, name string) ([]Value, error) {
+	switch p.Degree() {
+	case -1:
+		return nil, fmt.Errorf("every value of %s is a solution", name)
+	case 0:
+		return nil, fmt.Errorf("no value of %s is a solution", name)
+	case 1:
+		root, err := c.applyBinary(divideOperator, negate(p.coefficients[1]), p.coefficients[0])
+		return []Value{root}, err
+	}
+
+	a, b, k := p.coefficients[0], p.coefficients[1], p.coefficients[2]
+	square, err := c.applyBinary(multiplyOperator, b, b)
+	if err != nil {
+		return nil, err
+	}
+	product, err := c.applyBinary(multiplyOperator, Int{big.NewInt(4)}, a)
+	if err == nil {
+		product, err = c.applyBinary(multiplyOperator, product, k)
+	}
+	if err != nil {
+		return nil, err
+	}
+	discriminant, err := c.applyBinary(subtractOperator, square, product)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := exactSquareRoot(discriminant)
+	if z, isComplex := discriminant.(Complex); isComplex {
+		root = normalize(Complex(cmplx.Sqrt(complex128(z))))
+	} else if !ok && toFloat(discriminant) >= 0 {
+		root = Float(math.Sqrt(toFloat(discriminant)))
+	} else if !ok {
+		root = Complex(complex(0, math.Sqrt(-toFloat(discriminant))))
+	}
+	if isZero(root) {
+		single, err := c.applyBinary(divideOperator, negate(b), Int{big.NewInt(2)})
+		if err == nil {
+			single, err = c.applyBinary(divideOperator, single, a)
+		}
+		return []Value{single}, err
+	}
+
+	twice, err := c.applyBinary(multiplyOperator, Int{big.NewInt(2)}, a)
+	if err != nil {
+		return nil, err
+	}
+	var roots []Value
+	for _, operator := range []string{subtractOperator, addOperator} {
+		numerator, err := c.applyBinary(operator, negate(b), root)
+		if err != nil {
+			return nil, err
+		}
+		x, err := c.applyBinary(divideOperator, numerator, twice)
+		if err != nil {
+			return nil, err
+		}
+		roots = append(roots, x)
+	}
+	if isReal(roots[0]) && toFloat(roots[0]) > toFloat(roots[1]) {
+		roots[0], roots[1] = roots[1], roots[0]
+	}
+	return roots, nil
+}
+
+// exactSquareRoot returns the square root of an Int or Rational that is the
+// square of one.
+func exactSquareRoot(v Value) (Value, bool) {
+	r, ok := exactRational(v)
+	if !ok || r.Sign() < 0 {
+		return nil, false
+	}
+	num, den := new(big.Int).Sqrt(r.Num()), new(big.Int).Sqrt(r.Denom())
+	if new(big.Int).Mul(num, num).Cmp(r.Num()) != 0 || new(big.Int).Mul(den, den).Cmp(r.Denom()) != 0 {
+		return nil, false
+	}
+	return normalize(Rational{new(big.Rat).SetFrac(num, den)}), true
+}
+
+// solveNumerically finds the real roots of tree in the unknown name near
+// zero, or else further out up to solveLimit.
+func (c *Calculator) solveNumerically(tree node, name string) ([]Value, error) {
+	f := func(x float64) float64 {
+		c.Variables[name] = Float(x)
+		v, err := c.evaluateNode(tree)
+		if err != nil || !isReal(v) {
+			return math.NaN()
+		}
+		return toFloat(v)
+	}
+
+	roots := searchRoots(f)
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("no real solution for %s found between %g and %g", name, -solveLimit, solveLimit)
+	}
+	result := make([]Value, len(roots))
+	for i, root := range roots {
+		result[i] = Float(root)
+	}
+	return result, nil
+}
+
+// searchRoots returns the roots of f between -nearLimit and nearLimit or,
+// when there are none, between -solveLimit and solveLimit.
+func searchRoots(f func(float64) float64) []float64 {
+	near := []float64{0}
+	for i := 1; i <= nearLimit*100; i++ {
+		near = append(near, float64(i)/100, -float64(i)/100)
+	}
+	roots := findRoots(f, near)
+	if len(roots) == 0 {
+		var far []float64
+		for x := float64(nearLimit); x <= solveLimit; x *= 1.01 {
+			far = append(far, x, -x)
+		}
+		roots = findRoots(f, far)
+	}
+	return roots
+}
+
+// findRoots returns the roots of f that it finds among points: it looks for
+// changes of sign, which bisection narrows down, and for dips towards zero,
+// which Newton's method follows.
+func findRoots(f func(float64) float64, points []float64) []float64 {
+	sort.Float64s(points)
+	values := make([]float64, len(points))
+	for i, x := range points {
+		values[i] = f(x)
+	}
+
+	var roots []float64
+	accept := func(x float64) {
+		if y := f(x); math.IsNaN(y) || math.Abs(y) > 1e-9*math.Max(1, math.Abs(x)) {
+			return
+		}
+		for _, root := range roots {
+			if math.Abs(root-x) <= 1e-9*math.Max(1, math.Abs(x)) {
+				return
+			}
+		}
+		roots = append(roots, x)
+	}
+	for i := range points {
+		switch {
+		case values[i] == 0:
+			accept(points[i])
+		case i > 0 && values[i-1]*values[i] < 0:
+			accept(bisect(f, points[i-1], points[i], values[i-1]))
+		case i > 0 && i < len(points)-1 && math.Abs(values[i]) < math.Abs(values[i-1]) && math.Abs(values[i]) < math.Abs(values[i+1]):
+			if x, ok := newton(f, points[i]); ok {
+				accept(x)
+			}
+		}
+	}
+	sort.Float64s(roots)
+	return roots
+}
+
+// bisect narrows down the root of f between a and b, where f changes sign
+// and f(a) is fa.
+func bisect(f func(float64) float64, a, b, fa float64) float64 {
+	for i := 0; i < 200 && a != b; i++ {
+		middle := a + (b-a)/2
+		if middle == a || middle == b {
+			break
+		}
+		fm := f(middle)
+		if fm == 0 {
+			return middle
+		}
+		if (fm < 0) == (fa < 0) {
+			a, fa = middle, fm
+		} else {
+			b = middle
+		}
+	}
+	return a + (b-a)/2
+}
+
+// newton follows Newton's method on f from x, reporting whether it settled.
+func newton(f func(float64) float64, x float64) (float64, bool) {
+	for i := 0; i < 100; i++ {
+		y := f(x)
+		if y == 0 {
+			return x, true
+		}
+		h := 1e-7 * math.Max(1, math.Abs(x))
+		slope := (f(x+h) - f(x-h)) / (2 * h)
+		if slope == 0 || math.IsNaN(slope) {
+			return x, false
+		}
+		step := y / slope
+		x -= step
+		if math.Abs(step) <= 1e-14*math.Max(1, math.Abs(x)) {
+			return x, true
+		}
+	}
+	return x, false
+}
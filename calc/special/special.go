@@ -0,0 +1,109 @@
+// Package special is an optional pack of special functions for scientific
+// work, next to the gamma function that is built in:
+//
+//	c := calc.New()
+//	c.Register(special.Extension())
+//	c.EvaluateInput("erf(1)")
+package special
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// Extension returns the special functions pack: lgamma(x) is the natural
+// logarithm of the absolute value of gamma(x), beta(a, b) is the beta
+// function, erf(x) and erfc(x) the error function and its complement, and
+// besselj0(x) and besselj1(x) the Bessel functions of the first kind of
+// orders 0 and 1, and asinh(x), acosh(x), and atanh(x) the inverse
+// hyperbolic functions, which sinh^-1, cosh^-1, and tanh^-1 also give.
+func Extension() calc.Extension {
+	return calc.Extension{
+		Name: "special",
+		Functions: map[string]func([]calc.Value) (calc.Value, error){
+			"lgamma": realFunction(1, func(x []float64) (float64, error) {
+				if isPole(x[0]) {
+					return 0, fmt.Errorf("%w: lgamma(%g) is undefined at zero and the negative integers", calc.ErrDomain, x[0])
+				}
+				result, _ := math.Lgamma(x[0])
+				return result, nil
+			}),
+			"beta": realFunction(2, beta),
+			"erf": realFunction(1, func(x []float64) (float64, error) {
+				return math.Erf(x[0]), nil
+			}),
+			"erfc": realFunction(1, func(x []float64) (float64, error) {
+				return math.Erfc(x[0]), nil
+			}),
+			"besselj0": realFunction(1, func(x []float64) (float64, error) {
+				return math.J0(x[0]), nil
+			}),
+			"besselj1": realFunction(1, func(x []float64) (float64, error) {
+				return math.J1(x[0]), nil
+			}),
+			"asinh": realFunction(1, func(x []float64) (float64, error) {
+				return math.Asinh(x[0]), nil
+			}),
+			"acosh": realFunction(1, func(x []float64) (float64, error) {
+				if x[0] < 1 {
+					return 0, fmt.Errorf("%w: acosh(%g) is undefined below 1", calc.ErrDomain, x[0])
+				}
+				return math.Acosh(x[0]), nil
+			}),
+			"atanh": realFunction(1, func(x []float64) (float64, error) {
+				if math.Abs(x[0]) >= 1 {
+					return 0, fmt.Errorf("%w: atanh(%g) is undefined outside -1 < x < 1", calc.ErrDomain, x[0])
+				}
+				return math.Atanh(x[0]), nil
+			}),
+		},
+		Inverses: map[string]string{"sinh": "asinh", "cosh": "acosh", "tanh": "atanh"},
+	}
+}
+
+// isPole reports whether x is zero or a negative integer, where gamma has no
+// value.
+func isPole(x float64) bool {
+	return x <= 0 && x == math.Trunc(x)
+}
+
+// beta returns gamma(a)*gamma(b)/gamma(a+b), from the gamma function itself
+// while it stays finite and from its logarithm beyond.
+func beta(args []float64) (float64, error) {
+	a, b := args[0], args[1]
+	if isPole(a) || isPole(b) {
+		return 0, fmt.Errorf("%w: beta(%g, %g) is undefined when either is zero or a negative integer", calc.ErrDomain, a, b)
+	}
+	if isPole(a + b) {
+		return 0, nil
+	}
+	if result := math.Gamma(a) * math.Gamma(b) / math.Gamma(a+b); !math.IsInf(result, 0) && !math.IsNaN(result) && result != 0 {
+		return result, nil
+	}
+	la, sa := math.Lgamma(a)
+	lb, sb := math.Lgamma(b)
+	lab, sab := math.Lgamma(a + b)
+	return float64(sa*sb*sab) * math.Exp(la+lb-lab), nil
+}
+
+// realFunction wraps a function of count real numbers.
+func realFunction(count int, f func([]float64) (float64, error)) func([]calc.Value) (calc.Value, error) {
+	return func(args []calc.Value) (calc.Value, error) {
+		if len(args) != count && count == 1 {
+			return nil, fmt.Errorf("expected 1 argument, got %d", len(args))
+		} else if len(args) != count {
+			return nil, fmt.Errorf("expected %d arguments, got %d", count, len(args))
+		}
+		x := make([]float64, count)
+		for i, arg := range args {
+			var ok bool
+			if x[i], ok = calc.Real(arg); !ok {
+				return nil, fmt.Errorf("expected a real number, got %s", arg)
+			}
+		}
+		result, err := f(x)
+		return calc.Float(result), err
+	}
+}
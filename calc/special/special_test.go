@@ -0,0 +1,59 @@
+package special
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+func TestReferenceValues(t *testing.T) {
+	c := calc.New()
+	if err := c.Register(Extension()); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		input string
+		want  float64
+	}{
+		{"erf(1)", 0.8427007929497149},
+		{"erfc(1)", 0.15729920705028513},
+		{"besselj0(1)", 0.7651976865579666},
+		{"besselj1(1)", 0.44005058574493355},
+		{"beta(1/2, 1/2)", math.Pi},
+		{"beta(2, 3)", 1.0 / 12},
+		// 199!^2/399!, from the exact factorials.
+		{"beta(200, 200)", 9.713217247611181e-122},
+		{"lgamma(10)", math.Log(362880)},
+		{"lgamma(-1/2)", math.Log(2 * math.Sqrt(math.Pi))},
+		{"asinh(1)", 0.881373587019543},
+		{"acosh(2)", 1.3169578969248166},
+		{"atanh(1/2)", 0.5493061443340548},
+		{"cosh^-1(2)", 1.3169578969248166},
+		{"tanh^-1(-1/2)", -0.5493061443340548},
+	}
+	for _, test := range tests {
+		value, err := c.EvaluateValue(test.input)
+		if err != nil {
+			t.Errorf("%s: %v", test.input, err)
+			continue
+		}
+		got, ok := calc.Real(value)
+		if !ok || math.Abs(got-test.want) > 1e-12*math.Max(1, math.Abs(test.want)) {
+			t.Errorf("%s = %v, want %v", test.input, value, test.want)
+		}
+	}
+}
+
+func TestPoles(t *testing.T) {
+	c := calc.New()
+	if err := c.Register(Extension()); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{"lgamma(0)", "lgamma(-1)", "lgamma(-2)", "beta(0, 1)", "beta(1, -3)", "acosh(0.5)", "atanh(1)", "atanh(-2)"} {
+		if _, err := c.EvaluateValue(input); !errors.Is(err, calc.ErrDomain) {
+			t.Errorf("%s: got error %v, want %v", input, err, calc.ErrDomain)
+		}
+	}
+}
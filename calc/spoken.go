@@ -0,0 +1,437 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	spokenUnits = map[string]int{
+		"zero": 0, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5, "six": 6, "seven": 7, "eight": 8, "nine": 9,
+		"ten": 10, "eleven": 11, "twelve": 12, "thirteen": 13, "fourteen": 14, "fifteen": 15, "sixteen": 16,
+		"seventeen": 17, "eighteen": 18, "nineteen": 19,
+	}
+	spokenTens    = map[string]int{"twenty": 20, "thirty": 30, "forty": 40, "fifty": 50, "sixty": 60, "seventy": 70, "eighty": 80, "ninety": 90}
+	spokenScales  = map[string]int{"hundred": 100, "thousand": 1000, "million": 1000000}
+	spokenPhrases = []spokenPhrase{
+		{[]string{"to", "the", "power", "of"}, powerOperator},
+		{[]string{"multiplied", "by"}, multiplyOperator},
+		{[]string{"divided", "by"}, divideOperator},
+		{[]string{"open", "parenthesis"}, leftParen},
+		{[]string{"open", "paren"}, leftParen},
+		{[]string{"close", "parenthesis"}, rightParen},
+		{[]string{"close", "paren"}, rightParen},
+		{[]string{"plus"}, addOperator},
+		{[]string{"minus"}, subtractOperator},
+		{[]string{"times"}, multiplyOperator},
+		{[]string{"over"}, divideOperator},
+	}
+	naturalPrefixes = []spokenPhrase{
+		{[]string{"what", "is"}, ""},
+		{[]string{"how", "much", "is"}, ""},
+		{[]string{"calculate"}, ""},
+		{[]string{"compute"}, ""},
+	}
+	naturalFractions = []spokenPhrase{
+		{[]string{"half", "of"}, "2"},
+		{[]string{"a", "third", "of"}, "3"},
+		{[]string{"third", "of"}, "3"},
+		{[]string{"a", "quarter", "of"}, "4"},
+		{[]string{"quarter", "of"}, "4"},
+	}
+	naturalMultiples = map[string]string{"double": "2", "twice": "2", "triple": "3"}
+	naturalPowers    = map[string]string{"squared": "2", "cubed": "3"}
+	// spokenOperators are the operators written as words, which calculations
+	// in words keep as they are.
+	spokenOperators = map[string]bool{"in": true, "mod": true, "xor": true}
+)
+
+type spokenPhrase struct {
+	words []string
+	token string
+}
+
+func (c *Calculator) isSpokenInput(input string) bool {
+	if strings.IndexFunc(input, unicode.IsLetter) < 0 {
+		return false
+	}
+	words := c.spokenWords(input)
+	recognized := false
+	for i, word := range words {
+		if _, err := strconv.ParseFloat(word, 64); err == nil {
+			continue
+		}
+		if isSpokenWord(words, i) {
+			recognized = true
+			continue
+		}
+		if c.isSpokenName(word) {
+			continue
+		}
+		for _, char := range word {
+			if !unicode.IsLetter(char) {
+				return false
+			}
+		}
+	}
+	return recognized
+}
+
+// isSpokenName reports whether word is a name that keeps its meaning inside a
+// calculation in words, such as a variable, pi, a unit, sqrt, or the in of a
+// conversion.
+func (c *Calculator) isSpokenName(word string) bool {
+	_, isValue := c.lookupValue(word)
+	return isValue || c.isFunctionName(word) || spokenOperators[word]
+}
+
+// isFunctionName reports whether name is a builtin, an extension function, or
+// a function defined in the session.
+func (c *Calculator) isFunctionName(name string) bool {
+	_, isBuiltin := builtins[name]
+	_, isLogarithm := logBase(name)
+	_, isExtension := c.extensionFunction(name)
+	_, isDefined := c.Functions[name]
+	return isBuiltin || isLogarithm || isExtension || isDefined
+}
+
+// isSpokenWord reports whether words[i] is a number word or starts one of the
+// phrases, so that the a of a third of 9 counts but a on its own does not.
+func isSpokenWord(words []string, i int) bool {
+	word := words[i]
+	if isSpokenNumberWord(words, i) {
+		return true
+	}
+	if _, ok := naturalMultiples[word]; ok {
+		return true
+	}
+	if _, ok := naturalPowers[word]; ok {
+		return true
+	}
+	if word == "point" || word == "percent" {
+		return true
+	}
+	for _, phrases := range [][]spokenPhrase{spokenPhrases, naturalPrefixes, naturalFractions} {
+		if _, n := matchSpokenPhrase(phrases, words[i:]); n > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// spokenWords splits input into lowercase words, leaving the names of
+// variables, units, and functions as they were written.
+func (c *Calculator) spokenWords(input string) []string {
+	input = strings.NewReplacer("-", " ", "%", " percent ", "?", "", ",", "").Replace(input)
+	var words []string
+	for _, word := range strings.Fields(input) {
+		if c.isSpokenName(word) {
+			words = append(words, word)
+		} else if word = strings.ToLower(word); word == "what's" {
+			words = append(words, "what", "is")
+		} else {
+			words = append(words, word)
+		}
+	}
+	return words
+}
+
+// translateSpoken rewrites calculations written in words, including lite
+// natural-language phrasing such as "15% of 240" or "what is 3 squared", into
+// an expression the evaluator understands.
+func (c *Calculator) translateSpoken(input string) (string, []string, error) {
+	words := c.spokenWords(input)
+	if _, n := matchSpokenPhrase(naturalPrefixes, words); n > 0 {
+		words = words[n:]
+	}
+	var tokens []string
+	var warnings []string
+
+	readNumber := func(i int) (string, int, error) {
+		j := i
+		for j < len(words) && isSpokenNumberWord(words, j) {
+			j++
+		}
+		if j == i {
+			if i >= len(words) {
+				return "", i, fmt.Errorf("expected a number at the end of the input")
+			}
+			return "", i, fmt.Errorf("unrecognized word: %s", words[i])
+		}
+		number, warning, err := parseSpokenNumber(words[i:j])
+		if err != nil {
+			return "", i, err
+		}
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+		return number, j, nil
+	}
+	// readOperand reads what a function applies to, as in sqrt nine or sqrt
+	// of x.
+	readOperand := func(i int) (string, int, error) {
+		if i < len(words) && words[i] == "of" {
+			i++
+		}
+		if i < len(words) {
+			if _, ok := c.lookupValue(words[i]); ok {
+				return words[i], i + 1, nil
+			}
+		}
+		return readNumber(i)
+	}
+
+	for i := 0; i < len(words); {
+		if token, n := matchSpokenPhrase(spokenPhrases, words[i:]); n > 0 {
+			tokens = append(tokens, token)
+			i += n
+			continue
+		}
+
+		if divisor, n := matchSpokenPhrase(naturalFractions, words[i:]); n > 0 {
+			number, next, err := readNumber(i + n)
+			if err != nil {
+				return "", nil, err
+			}
+			tokens = append(tokens, "("+number+" / "+divisor+")")
+			i = next
+			continue
+		}
+
+		if factor, ok := naturalMultiples[words[i]]; ok {
+			number, next, err := readNumber(i + 1)
+			if err != nil {
+				return "", nil, err
+			}
+			tokens = append(tokens, "("+factor+" * "+number+")")
+			i = next
+			continue
+		}
+
+		if exponent, ok := naturalPowers[words[i]]; ok {
+			tokens = append(tokens, powerOperator, exponent)
+			i++
+			continue
+		}
+
+		if words[i] == "percent" {
+			if len(tokens) == 0 || !c.isNumber(tokens[len(tokens)-1]) {
+				return "", nil, fmt.Errorf("expected a number before 'percent'")
+			}
+			base := tokens[len(tokens)-1]
+			tokens = tokens[:len(tokens)-1]
+			if i+1 < len(words) && words[i+1] == "of" {
+				number, next, err := readNumber(i + 2)
+				if err != nil {
+					return "", nil, err
+				}
+				tokens = append(tokens, "("+base+" / 100 * "+number+")")
+				i = next
+			} else {
+				tokens = append(tokens, "("+base+" / 100)")
+				i++
+			}
+			continue
+		}
+
+		if c.isFunctionName(words[i]) {
+			operand, next, err := readOperand(i + 1)
+			if err != nil {
+				return "", nil, err
+			}
+			tokens = append(tokens, words[i]+"("+operand+")")
+			i = next
+			continue
+		}
+
+		if c.isSpokenName(words[i]) {
+			tokens = append(tokens, words[i])
+			i++
+			continue
+		}
+
+		number, next, err := readNumber(i)
+		if err != nil {
+			return "", nil, err
+		}
+		tokens = append(tokens, number)
+		i = next
+	}
+
+	if len(tokens) == 1 && strings.HasPrefix(tokens[0], leftParen) {
+		return strings.TrimSuffix(strings.TrimPrefix(tokens[0], leftParen), rightParen), warnings, nil
+	}
+	return strings.Join(tokens, " "), warnings, nil
+}
+
+func matchSpokenPhrase(phrases []spokenPhrase, words []string) (string, int) {
+	for _, phrase := range phrases {
+		if len(words) < len(phrase.words) {
+			continue
+		}
+		matched := true
+		for k, word := range phrase.words {
+			if words[k] != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return phrase.token, len(phrase.words)
+		}
+	}
+	return "", 0
+}
+
+func isSpokenNumberWord(words []string, i int) bool {
+	word := words[i]
+	if _, err := strconv.ParseFloat(word, 64); err == nil {
+		return true
+	}
+	if _, ok := spokenUnits[word]; ok {
+		return true
+	}
+	if _, ok := spokenTens[word]; ok {
+		return true
+	}
+	if _, ok := spokenScales[word]; ok {
+		return true
+	}
+	if word == "point" {
+		return true
+	}
+	next := ""
+	if i+1 < len(words) {
+		next = words[i+1]
+	}
+	if word == "a" {
+		_, ok := spokenScales[next]
+		return ok
+	}
+	if word == "and" && i > 0 {
+		_, afterScale := spokenScales[words[i-1]]
+		_, beforeUnit := spokenUnits[next]
+		_, beforeTens := spokenTens[next]
+		return afterScale && (beforeUnit || beforeTens)
+	}
+	return false
+}
+
+// parseSpokenNumber converts a run of number words into a numeral. Runs that
+// only make sense as separately spoken groups ("nineteen eighty four", "one two
+// three") are read digit-wise and reported back as a warning.
+func parseSpokenNumber(words []string) (string, string, error) {
+	var groups []string
+	var total, current, largestScale int
+	previous := ""
+	started := false
+
+	flush := func() {
+		if started {
+			groups = append(groups, strconv.Itoa(total+current))
+		}
+		total, current, largestScale = 0, 0, 0
+		previous = ""
+		started = false
+	}
+
+	for i := 0; i < len(words); i++ {
+		word := words[i]
+		if word == "and" {
+			continue
+		}
+		if word == "point" {
+			var digits strings.Builder
+			for i+1 < len(words) {
+				digit, ok := spokenUnits[words[i+1]]
+				if !ok || digit > 9 {
+					break
+				}
+				digits.WriteString(strconv.Itoa(digit))
+				i++
+			}
+			if digits.Len() == 0 {
+				return "", "", fmt.Errorf("expected digits after 'point'")
+			}
+			integer := total + current
+			started = false
+			flush()
+			groups = append(groups, strconv.Itoa(integer)+"."+digits.String())
+			continue
+		}
+		if word == "a" {
+			word = "one"
+		}
+
+		if literal, err := strconv.ParseFloat(word, 64); err == nil {
+			flush()
+			if literal != math.Trunc(literal) || i+1 >= len(words) || spokenScales[words[i+1]] == 0 {
+				groups = append(groups, word)
+				continue
+			}
+			current = int(literal)
+			previous = "literal"
+			started = true
+			continue
+		}
+
+		if scale, ok := spokenScales[word]; ok {
+			if !started {
+				return "", "", fmt.Errorf("unexpected '%s'", word)
+			}
+			if current == 0 {
+				current = 1
+			}
+			if scale == 100 {
+				if previous == "hundred" {
+					return "", "", fmt.Errorf("unexpected '%s'", word)
+				}
+				current *= scale
+				previous = "hundred"
+				continue
+			}
+			if largestScale != 0 && scale >= largestScale {
+				return "", "", fmt.Errorf("unexpected '%s' after a larger scale", word)
+			}
+			largestScale = scale
+			total += current * scale
+			current = 0
+			previous = "scale"
+			continue
+		}
+
+		if value, ok := spokenTens[word]; ok {
+			if previous != "" && previous != "hundred" && previous != "scale" {
+				flush()
+			}
+			current += value
+			previous = "tens"
+			started = true
+			continue
+		}
+
+		value := spokenUnits[word]
+		attaches := previous == "" || previous == "hundred" || previous == "scale" || (previous == "tens" && value < 10)
+		if !attaches {
+			flush()
+		}
+		current += value
+		previous = "unit"
+		started = true
+	}
+	flush()
+
+	if len(groups) == 1 {
+		return groups[0], "", nil
+	}
+	for _, group := range groups {
+		if strings.Contains(group, ".") || len(group) > 2 {
+			return "", "", fmt.Errorf("ambiguous number '%s': add an operator between the numbers", strings.Join(words, " "))
+		}
+	}
+	number := strings.Join(groups, "")
+	return number, fmt.Sprintf("'%s' is ambiguous and was read as %s", strings.Join(words, " "), number), nil
+}
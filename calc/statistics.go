@@ -0,0 +1,68 @@
+package calc
+
+import (
+	"math"
+	"sort"
+)
+
+// sum is the total of its arguments.
+func sum(args []float64) (float64, error) {
+	total := 0.0
+	for _, arg := range args {
+		total += arg
+	}
+	return total, nil
+}
+
+func mean(args []float64) (float64, error) {
+	total, _ := sum(args)
+	return total / float64(len(args)), nil
+}
+
+// median is the middle of its arguments in order, or the mean of the two
+// middle ones when there is an even number of them.
+func median(args []float64) (float64, error) {
+	sorted := append([]float64(nil), args...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2, nil
+	}
+	return sorted[middle], nil
+}
+
+// variance is the sample variance of its arguments, dividing by one less than
+// their number.
+func variance(args []float64) (float64, error) {
+	average, _ := mean(args)
+	squares := 0.0
+	for _, arg := range args {
+		squares += (arg - average) * (arg - average)
+	}
+	return squares / float64(len(args)-1), nil
+}
+
+// stddev is the sample standard deviation of its arguments.
+func stddev(args []float64) (float64, error) {
+	v, _ := variance(args)
+	return math.Sqrt(v), nil
+}
+
+// mode is the most frequent of its arguments, the smallest one if several are
+// equally frequent.
+func mode(args []float64) (float64, error) {
+	sorted := append([]float64(nil), args...)
+	sort.Float64s(sorted)
+	result, best := sorted[0], 0
+	for i := 0; i < len(sorted); {
+		j := i
+		for j < len(sorted) && sorted[j] == sorted[i] {
+			j++
+		}
+		if j-i > best {
+			result, best = sorted[i], j-i
+		}
+		i = j
+	}
+	return result, nil
+}
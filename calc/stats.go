@@ -0,0 +1,102 @@
+package calc
+
+import (
+	"sort"
+	"time"
+)
+
+// EvalStats describes the work one EvaluateInput call took, for embedders
+// that monitor or limit what the input they evaluate costs.
+type EvalStats struct {
+	// Tokens is the number of tokens of the expressions compiled.
+	Tokens int `json:"tokens"`
+	// Depth is the depth of the deepest syntax tree compiled, 1 for a single
+	// number.
+	Depth int `json:"depth"`
+	// Operations is the number of operators applied and functions called.
+	Operations int `json:"operations"`
+	// Functions lists the functions called, sorted and without duplicates.
+	Functions []string `json:"functions"`
+	// Duration is how long the evaluation took.
+	Duration time.Duration `json:"duration"`
+}
+
+// WithStats makes EvaluateInput report the EvalStats of each evaluation in
+// the Stats of its Result.
+func WithStats() Option {
+	return func(c *Calculator) { c.CollectStats = true }
+}
+
+// recordTree adds the tokens, depth, and function calls of a compiled
+// expression to the statistics being collected, if any.
+func (c *Calculator) recordTree(tokens int, tree node) {
+	if c.stats == nil {
+		return
+	}
+	c.stats.Tokens += tokens
+	if depth := treeDepth(tree); depth > c.stats.Depth {
+		c.stats.Depth = depth
+	}
+	for _, name := range calledFunctions(tree) {
+		i := sort.SearchStrings(c.stats.Functions, name)
+		if i == len(c.stats.Functions) || c.stats.Functions[i] != name {
+			c.stats.Functions = append(c.stats.Functions[:i], append([]string{name}, c.stats.Functions[i:]...)...)
+		}
+	}
+}
+
+// countOperation counts n toward the statistics being collected, if any, when
+// it applies an operator or calls a function.
+func (c *Calculator) countOperation(n node) {
+	if c.stats == nil {
+		return
+	}
+	switch n.(type) {
+	case unaryNode, binaryNode, callNode:
+		c.stats.Operations++
+	}
+}
+
+// children returns the nodes directly below n.
+func children(n node) []node {
+	switch n := n.(type) {
+	case unaryNode:
+		return []node{n.operand}
+	case fieldNode:
+		return []node{n.operand}
+	case indexNode:
+		return []node{n.operand, n.index}
+	case listNode:
+		return n.elements
+	case binaryNode:
+		return []node{n.left, n.right}
+	case equationNode:
+		return []node{n.left, n.right}
+	case callNode:
+		return n.args
+	case letNode:
+		return append(append([]node{}, n.values...), n.body)
+	}
+	return nil
+}
+
+func treeDepth(n node) int {
+	depth := 0
+	for _, child := range children(n) {
+		if d := treeDepth(child); d > depth {
+			depth = d
+		}
+	}
+	return depth + 1
+}
+
+func calledFunctions(n node) []string {
+	var names []string
+	if call, ok := n.(callNode); ok {
+		names = append(names, call.name)
+	}
+	for _, child := range children(n) {
+		names = append(names, calledFunctions(child)...)
+	}
+	return names
+}
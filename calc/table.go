@@ -0,0 +1,100 @@
+package calc
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// maxTableRows bounds the number of rows of a table.
+const maxTableRows = 1000
+
+// tableExpression handles table(x^2, x, 0, 10, 1), the values of an
+// expression in a variable from a start to an end in steps, as a table of
+// two columns or, with csv as a last argument, as comma-separated values.
+// A row whose value cannot be found shows the error instead.
+func (c *Calculator) tableExpression(args []string) (string, error) {
+	asCSV := len(args) == 6 && strings.EqualFold(strings.TrimSpace(args[5]), "csv")
+	if (len(args) != 5 && !asCSV) || args[0] == "" {
+		return "", fmt.Errorf("table expects an expression, its variable, a start, an end, and a step, then optionally csv: table(x^2, x, 0, 10, 1)")
+	}
+	name := strings.TrimSpace(args[1])
+	if identifierRegex.FindString(name) != name {
+		return "", fmt.Errorf("table needs the name of the variable, not '%s'", name)
+	}
+	if err := c.checkAssignable(name); err != nil {
+		return "", fmt.Errorf("cannot tabulate over '%s', it is a function name", name)
+	}
+	var bounds [3]float64
+	for i, arg := range args[2:5] {
+		bound, err := c.Evaluate(arg)
+		if err != nil {
+			return "", err
+		}
+		if math.IsNaN(bound) || math.IsInf(bound, 0) {
+			return "", fmt.Errorf("table needs a finite real start, end, and step, not %s", strings.TrimSpace(arg))
+		}
+		bounds[i] = bound
+	}
+	start, end, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 || (end-start)/step < 0 {
+		return "", fmt.Errorf("table needs a step that goes from %s towards %s", c.Format(start), c.Format(end))
+	}
+	count := math.Floor((end-start)/step+1e-9) + 1
+	if count > maxTableRows {
+		return "", fmt.Errorf("table would have more than %d rows; use a larger step", maxTableRows)
+	}
+
+	defer c.bindUnknown(name, Float(start))()
+	tree, err := c.compile(args[0])
+	if err != nil {
+		return "", err
+	}
+	expression := strings.TrimSpace(args[0])
+	rows := [][2]string{{name, expression}}
+	for i := 0; i < int(count); i++ {
+		x := start + float64(i)*step
+		c.Variables[name] = Float(x)
+		cell := ""
+		if v, err := c.evaluateNode(tree); err != nil {
+			cell = "error: " + err.Error()
+		} else {
+			cell = c.FormatValue(v)
+		}
+		rows = append(rows, [2]string{c.Format(x), cell})
+	}
+	if asCSV {
+		var b bytes.Buffer
+		w := csv.NewWriter(&b)
+		for _, row := range rows {
+			w.Write(row[:])
+		}
+		w.Flush()
+		return strings.TrimSuffix(b.String(), "\n"), w.Error()
+	}
+	header := fmt.Sprintf("%s for %s from %s to %s in steps of %s", expression, name, c.Format(start), c.Format(end), c.Format(step))
+	return header + "\n" + formatTable(rows), nil
+}
+
+// formatTable lines up rows of two cells on the right under the first, which
+// it underlines.
+func formatTable(rows [][2]string) string {
+	var widths [2]int
+	for _, row := range rows {
+		for i, cell := range row {
+			if n := len([]rune(cell)); n > widths[i] {
+				widths[i] = n
+			}
+		}
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		lines = append(lines, fmt.Sprintf("%*s | %*s", widths[0], row[0], widths[1], row[1]))
+		if i == 0 {
+			lines = append(lines, strings.Repeat("-", widths[0]+1)+"+"+strings.Repeat("-", widths[1]+1))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,444 @@
+package calc
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	mixedNumberRegex = regexp.MustCompile(`(^|[^\d.])(\d+)\s+(\d+)/(\d+)`)
+	exponentRegex    = regexp.MustCompile(`^[eE][+-]?\d+`)
+	identifierRegex  = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*`)
+	// baseLiteralRegex matches an integer written in hexadecimal, octal, or
+	// binary, as in 0xFF, 0o17, or 0b1010, or a hexadecimal float such as
+	// 0x1.8p3, along with any letters, digits, and points that follow so
+	// that a wrong digit is reported. The prefix must be followed by a digit,
+	// so that 0x without one is 0 times x.
+	baseLiteralRegex = regexp.MustCompile(`^0(?:[xX]\.?[0-9A-Fa-f]|[oObB][0-9])(?:[pP][+-]|[0-9A-Za-z_.])*`)
+	// modRegex matches the word form of the modulo operator, which must be
+	// spaced as in 7 mod 3.
+	modRegex = regexp.MustCompile(`\bmod\b(\s*\S)`)
+	// xorRegex matches the word form of the bitwise exclusive or, as in
+	// 5 xor 3.
+	xorRegex = regexp.MustCompile(`\bxor\b`)
+	// convertRegex matches the word that converts a quantity to other units,
+	// as in 60 mph in km/h.
+	convertRegex = regexp.MustCompile(`\bin\b`)
+	// andRegex, orRegex, and notRegex match the words that combine
+	// conditions, as in x > 0 and not y == 1.
+	andRegex = regexp.MustCompile(`\band\b`)
+	orRegex  = regexp.MustCompile(`\bor\b`)
+	notRegex = regexp.MustCompile(`\bnot\b`)
+	// fluidOunceRegex matches the unit fl oz, whose two words are one unit.
+	fluidOunceRegex = regexp.MustCompile(`\bfl(\s+)oz\b`)
+	// radicalRegex matches the radical signs: √ for a square root, or a root
+	// of the index before it, and ∛ and ∜ for cube and fourth roots.
+	radicalRegex = regexp.MustCompile(`^[√∛∜]`)
+)
+
+// radicalIndices are the indices of the roots whose radical sign shows them.
+var radicalIndices = map[string]string{"∛": "3", "∜": "4"}
+
+// replaceWordOperators replaces the operators written as words, such as mod
+// and xor, with their tokenizer's forms, and writes fl oz as its symbol floz.
+// Each form takes as many bytes as its words, padded with spaces where it is
+// shorter, so that offsets in the result are those of input.
+func replaceWordOperators(input string) string {
+	input = fluidOunceRegex.ReplaceAllString(input, "floz$1")
+	input = xorRegex.ReplaceAllString(input, xorOperator)
+	input = convertRegex.ReplaceAllString(input, convertOperator)
+	input = andRegex.ReplaceAllString(input, andOperator+" ")
+	input = orRegex.ReplaceAllString(input, orOperator)
+	input = notRegex.ReplaceAllString(input, notOperator+" ")
+	return modRegex.ReplaceAllString(input, moduloSign+"$1")
+}
+
+// placeholder stands in for a value the tokenizer could not read.
+const placeholder = "0"
+
+// tokenize splits input into tokens and returns the byte offset in input of
+// each, along with the problems it found. It reads past an invalid character
+// or an undefined name so that the problems of the rest of the input are
+// found too, putting a placeholder value or call in its place.
+func (c *Calculator) tokenize(input string) ([]string, []int, []*SyntaxError) {
+	var tokens []string
+	var positions []int
+	var problems []*SyntaxError
+	var number strings.Builder
+	numberStart := 0
+	add := func(token string, position int) {
+		tokens = append(tokens, token)
+		positions = append(positions, position)
+	}
+	problem := func(err error, position int) {
+		problems = append(problems, &SyntaxError{Err: err, Offset: position})
+		add(placeholder, position)
+	}
+	// addNumber adds the number read so far. Points without digits, as in
+	// min(...), are left for the parser to report as a missing value.
+	addNumber := func() {
+		if _, err := strconv.ParseFloat(number.String(), 64); strings.Trim(number.String(), ".") == "" {
+			add(number.String(), numberStart)
+		} else if errors.Is(err, strconv.ErrRange) {
+			problem(fmt.Errorf("number out of range: %s", number.String()), numberStart)
+		} else if err != nil {
+			problem(fmt.Errorf("invalid number: %s", number.String()), numberStart)
+		} else {
+			add(number.String(), numberStart)
+		}
+		number.Reset()
+	}
+
+	for i := 0; i < len(input); {
+		char := rune(input[i])
+		if date := dateLiteralRegex.FindString(input[i:]); date != "" && number.Len() == 0 && (i+len(date) == len(input) || !unicode.IsDigit(rune(input[i+len(date)]))) {
+			add(date, i)
+			i += len(date)
+		} else if literal := baseLiteralRegex.FindString(input[i:]); literal != "" && number.Len() == 0 {
+			if value, err := parseBaseLiteral(literal); err != nil {
+				problem(err, i)
+			} else {
+				add(value, i)
+			}
+			i += len(literal)
+		} else if name := identifierRegex.FindString(input[i+1:]); char == '.' && name != "" && number.Len() == 0 && len(tokens) > 0 && c.endsOperand(tokens[len(tokens)-1]) {
+			add(fieldPrefix+name, i)
+			i += len(fieldPrefix) + len(name)
+		} else if unicode.IsDigit(char) || char == '.' {
+			if number.Len() == 0 {
+				numberStart = i
+			}
+			number.WriteRune(char)
+			i++
+		} else if exponent := exponentRegex.FindString(input[i:]); exponent != "" && number.Len() > 0 {
+			number.WriteString(exponent)
+			i += len(exponent)
+			// A number ends with its exponent, so 1.5e3.2 is not one.
+			for i < len(input) && (unicode.IsDigit(rune(input[i])) || input[i] == '.') {
+				number.WriteByte(input[i])
+				i++
+			}
+			addNumber()
+		} else {
+			indexed := number.Len() > 0
+			if number.Len() > 0 {
+				addNumber()
+			}
+			if char == ' ' {
+				i++
+			} else if strings.HasPrefix(input[i:], degreeSign) {
+				add("deg", i)
+				i += len(degreeSign)
+			} else if radical := radicalRegex.FindString(input[i:]); radical != "" {
+				// A number written right before √ is the index of the root,
+				// as in 3√27; anything else multiplies the square root.
+				if !indexed && len(tokens) > 0 && c.endsOperand(tokens[len(tokens)-1]) {
+					add(implicitMultiplyOperator, i)
+				}
+				if index := radicalIndices[radical]; index != "" {
+					add(index, i)
+				}
+				add(rootOperator, i)
+				i += len(radical)
+			} else if (char == '-' || char == '+') && isUnaryPosition(tokens) {
+				if char == '-' {
+					add(negateOperator, i)
+				}
+				i++
+			} else if operator := longOperator(input[i:]); operator != "" {
+				add(operator, i)
+				i += len(operator)
+			} else if strings.HasPrefix(input[i:], moduloSign) {
+				add(moduloOperator, i)
+				i += len(moduloSign)
+			} else if char == '%' && startsSignedOperand(input[i+1:]) {
+				add(moduloOperator, i)
+				i++
+			} else if isOperatorOrParen(string(char)) || char == ',' || char == ':' || char == '=' || char == '[' || char == ']' {
+				add(string(char), i)
+				i++
+			} else if grade := gradeRegex.FindString(input[i:]); grade != "" && insideCall(tokens, gpaName) {
+				grade = strings.TrimRight(grade, ":,)")
+				if points, ok := c.GradeScale[strings.ToUpper(grade)]; ok {
+					add(strconv.FormatFloat(points, 'f', -1, 64), i)
+				} else {
+					problem(fmt.Errorf("unknown grade: %s", grade), i)
+				}
+				i += len(grade)
+			} else if name := identifierRegex.FindString(input[i:]); name != "" {
+				if rest := input[i+len(name):]; strings.HasPrefix(rest, scopeSeparator) {
+					qualified := identifierRegex.FindString(rest[len(scopeSeparator):])
+					if qualified == "" {
+						problem(fmt.Errorf("expected a variable name after %s%s", name, scopeSeparator), i)
+					} else if _, err := c.lookupQualified(name, qualified); err != nil {
+						problem(err, i)
+					} else {
+						add(name+scopeSeparator+qualified, i)
+					}
+					i += len(name) + len(scopeSeparator) + len(qualified)
+					continue
+				}
+				isCall := strings.HasPrefix(input[i+len(name):], leftParen)
+				_, isBuiltin := builtins[name]
+				if _, ok := logBase(name); ok {
+					isBuiltin = true
+				}
+				_, isExtension := c.extensionFunction(name)
+				if _, ok := c.Functions[name]; (ok || isBuiltin) && !isExtension {
+					if suffix := inverseRegex.FindString(input[i+len(name):]); suffix != "" {
+						if inverse, ok := c.inverseFunction(name); ok {
+							add(inverse+leftParen, i)
+						} else {
+							add(name+inverseSuffix+leftParen, i)
+						}
+						i += len(name) + len(suffix)
+						continue
+					}
+				}
+				if _, ok := c.Functions[name]; (ok || isBuiltin || isExtension || specialCalls[name]) && isCall {
+					add(name+leftParen, i)
+					i += len(name) + len(leftParen)
+					continue
+				}
+				if _, ok := c.lookupValue(name); ok && !(isCall && c.isMeasure(name)) {
+					if isCall {
+						add(name+leftParen, i)
+						i += len(leftParen)
+					} else {
+						add(name, i)
+					}
+					i += len(name)
+					continue
+				}
+				_, err := c.lookupVariable(name)
+				if !isCall && isDateToken(name) {
+					add(name, i)
+					i += len(name)
+					continue
+				}
+				if kind := strings.ToLower(name); !isCall && dayKinds[kind] && insideCall(tokens, addDaysName) {
+					add(kind, i)
+					i += len(name)
+					continue
+				}
+				_, isFunction := c.Functions[name]
+				switch {
+				case !isCall && (isFunction || isBuiltin || isExtension):
+					problem(fmt.Errorf("%s is a function, so its argument goes in parentheses, as in %s(x)", name, name), i)
+				case isCall && displayHelpers[name]:
+					problems = append(problems, &SyntaxError{Err: fmt.Errorf("%s only shows its result, so it must be the whole input", name), Offset: i})
+					add(name+leftParen, i)
+					i += len(leftParen)
+				case isCall:
+					problems = append(problems, &SyntaxError{Err: fmt.Errorf("undefined function: %s", name), Offset: i})
+					add(name+leftParen, i)
+					i += len(leftParen)
+				default:
+					problem(err, i)
+				}
+				i += len(name)
+			} else {
+				problem(fmt.Errorf("%w: %s", ErrInvalidCharacter, string(char)), i)
+				i++
+			}
+		}
+	}
+	if number.Len() > 0 {
+		addNumber()
+	}
+
+	tokens, positions = c.insertImplicitMultiplication(tokens, positions)
+	return tokens, positions, problems
+}
+
+// baseNames are the names of the bases of integer literals, by their prefix
+// letter.
+var baseNames = map[byte]string{'x': "hexadecimal", 'o': "octal", 'b': "binary"}
+
+// parseBaseLiteral returns the decimal digits of a literal matched by
+// baseLiteralRegex.
+func parseBaseLiteral(literal string) (string, error) {
+	prefix := literal[1] | 0x20
+	if prefix == 'x' && strings.ContainsAny(literal, ".pP") {
+		return parseHexFloat(literal)
+	}
+	base := map[byte]int{'x': 16, 'o': 8, 'b': 2}[prefix]
+	n, ok := new(big.Int).SetString(literal[2:], base)
+	if !ok || strings.ContainsAny(literal[2:], "_+-") {
+		return "", fmt.Errorf("invalid %s number: %s", baseNames[prefix], literal)
+	}
+	return n.String(), nil
+}
+
+// parseHexFloat returns the decimal digits of a hexadecimal float such as
+// 0x1.8p3, whose exponent is a power of two and may be left out.
+func parseHexFloat(literal string) (string, error) {
+	text := literal
+	if !strings.ContainsAny(text, "pP") {
+		text += "p0"
+	}
+	f, err := strconv.ParseFloat(text, 64)
+	if err != nil || strings.Contains(literal, "_") {
+		if errors.Is(err, strconv.ErrRange) {
+			return "", fmt.Errorf("hexadecimal number out of range: %s", literal)
+		}
+		return "", fmt.Errorf("invalid hexadecimal number: %s", literal)
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64), nil
+}
+
+// longOperator returns the operator that input starts with, if any, of those
+// of more than one byte and the comparisons, trying the longest first.
+func longOperator(input string) string {
+	for _, operator := range []string{floorDivOperator, shiftLeftOperator, shiftRightOperator, xorOperator, convertOperator, equalOperator, notEqualOperator, lessEqualOperator, greaterEqualOperator, andOperator, orOperator, notOperator, lessOperator, greaterOperator} {
+		// 5!==120 is a factorial compared for equality.
+		if strings.HasPrefix(input, operator) && !(operator == notEqualOperator && strings.HasPrefix(input, factorialOperator+equalOperator)) {
+			return operator
+		}
+	}
+	return ""
+}
+
+// squeeze drops the spaces from input, keeping one between a name and a name
+// after it so that pi r^2 does not read as pir^2, and one after a sign that
+// follows a % so that 15% - 3 does not read as 15 % -3. It also returns the
+// offset in input of each byte kept, followed by the offset just past the
+// last of them.
+func squeeze(input string) (string, []int) {
+	var squeezed strings.Builder
+	offsets := []int{}
+	end := 0
+	for i := 0; i < len(input); i++ {
+		if input[i] != ' ' {
+			if i > end && (endsName(squeezed.String()) && isNameStart(input[i]) || endsSignAfterPercent(squeezed.String())) {
+				squeezed.WriteByte(' ')
+				offsets = append(offsets, i-1)
+			}
+			squeezed.WriteByte(input[i])
+			offsets = append(offsets, i)
+			end = i + 1
+		}
+	}
+	return squeezed.String(), append(offsets, end)
+}
+
+// endsName reports whether text ends with a name, such as r in 2*r or x in
+// 2x, rather than a number or an operator.
+func endsName(text string) bool {
+	start := len(text)
+	for start > 0 && (isNameStart(text[start-1]) || text[start-1] >= '0' && text[start-1] <= '9') {
+		start--
+	}
+	// Digits before the name are a coefficient, as in 2x.
+	for start < len(text) && text[start] >= '0' && text[start] <= '9' {
+		start++
+	}
+	return start < len(text)
+}
+
+// endsSignAfterPercent reports whether text ends with a sign right after a
+// %, as in 15%-.
+func endsSignAfterPercent(text string) bool {
+	return strings.HasSuffix(text, percentOperator+addOperator) || strings.HasSuffix(text, percentOperator+subtractOperator)
+}
+
+// isNameStart reports whether char can begin a name.
+func isNameStart(char byte) bool {
+	return char == '_' || char >= 'A' && char <= 'Z' || char >= 'a' && char <= 'z'
+}
+
+// closingParen returns the index just past the parenthesis that closes the one
+// opened right before start.
+func closingParen(input string, start int) (int, bool) {
+	count := 1
+	j := start
+	for count > 0 && j < len(input) {
+		if input[j] == '(' {
+			count++
+		} else if input[j] == ')' {
+			count--
+		}
+		j++
+	}
+	return j, count == 0
+}
+
+// expandMixedNumbers rewrites space-separated mixed numbers such as 2 3/4 into
+// (2+3/4) before spaces are dropped and they would read as 23/4.
+func expandMixedNumbers(input string) string {
+	return mixedNumberRegex.ReplaceAllString(input, "${1}(${2}+${3}/${4})")
+}
+
+// insertImplicitMultiplication inserts the implicit multiplications between
+// adjacent operands, placing each at the position of the operand after it.
+func (c *Calculator) insertImplicitMultiplication(tokens []string, positions []int) ([]string, []int) {
+	var result []string
+	var resultPositions []int
+	for i, token := range tokens {
+		if i > 0 {
+			previous := tokens[i-1]
+			endsOperand := c.endsOperand(previous) || previous == percentOperator || previous == factorialOperator
+			startsOperand := c.isNumber(token) || c.isValueName(token) || token == leftParen || c.isFunction(token)
+			if endsOperand && startsOperand {
+				if (c.isNumber(previous) || c.isRealName(previous)) && c.isMeasure(token) {
+					result = append(result, unitMultiplyOperator)
+				} else {
+					result = append(result, implicitMultiplyOperator)
+				}
+				resultPositions = append(resultPositions, positions[i])
+			}
+		}
+		result = append(result, token)
+		resultPositions = append(resultPositions, positions[i])
+	}
+	return result, resultPositions
+}
+
+// endsOperand reports whether token can end an operand that a field such as
+// .mean can follow.
+func (c *Calculator) endsOperand(token string) bool {
+	return c.isNumber(token) || c.isValueName(token) || token == rightParen || token == rightBracket || isField(token)
+}
+
+// startsOperand reports whether char can begin an operand, which makes a %
+// before it the modulo operator rather than a percentage.
+func startsOperand(char byte) bool {
+	return char == '.' || char == '(' || char == '_' || unicode.IsDigit(rune(char)) || unicode.IsLetter(rune(char))
+}
+
+// startsSignedOperand reports whether text begins with an operand, or a sign
+// right before one, which makes a % before it the modulo operator, as in
+// 7 % -3. A sign that squeeze kept apart from what follows, as in 15% - 3,
+// subtracts from a percentage instead.
+func startsSignedOperand(text string) bool {
+	if text != "" && (text[0] == '-' || text[0] == '+') {
+		text = text[1:]
+	}
+	return text != "" && startsOperand(text[0])
+}
+
+// isUnaryPosition reports whether a sign following tokens has no left operand,
+// as at the start of the input, after an operator other than % and !, after an
+// opening paren, or after a comma between function arguments.
+func isUnaryPosition(tokens []string) bool {
+	if len(tokens) == 0 {
+		return true
+	}
+	previous := tokens[len(tokens)-1]
+	return opensGroup(previous) || previous == comma || previous == pieceSeparator || previous == equationSign || previous == leftBracket || isOperatorOrParen(previous) && previous != rightParen && previous != percentOperator && previous != factorialOperator
+}
+
+// opensGroup reports whether token is an opening paren, either on its own or
+// opening the arguments of a function call such as max(.
+func opensGroup(token string) bool {
+	return strings.HasSuffix(token, leftParen)
+}
+
+func isOperatorOrParen(token string) bool {
+	return token == addOperator || token == subtractOperator || token == multiplyOperator || token == divideOperator || token == floorDivOperator || token == moduloOperator || token == powerOperator || token == implicitMultiplyOperator || token == unitMultiplyOperator || token == convertOperator || token == negateOperator || token == percentOperator || token == factorialOperator || token == rootOperator || bitwiseOperators[token] || logicalOperators[token] || token == leftParen || token == rightParen
+}
@@ -0,0 +1,115 @@
+package calc
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// destructureRegex matches a statement that assigns the values of a tuple to
+// several variables, as in q, r = divmod(17, 5).
+var destructureRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\s*,\s*[A-Za-z_][A-Za-z0-9_]*)+)\s*=\s*([^=].*)$`)
+
+// Tuple is several values given by one function, such as the quotient and
+// remainder of divmod(17, 5). Its values can be assigned to several variables
+// at once with q, r = divmod(17, 5).
+type Tuple []Value
+
+func (t Tuple) Kind() string { return "tuple" }
+
+func (t Tuple) String() string {
+	parts := make([]string, len(t))
+	for i, v := range t {
+		parts[i] = v.String()
+	}
+	return "(" + strings.Join(parts, ", ") + ")"
+}
+
+// parseDestructuring splits a statement such as q, r = divmod(17, 5) into the
+// variable names and the expression that gives their values.
+func parseDestructuring(input string) ([]string, string, bool) {
+	match := destructureRegex.FindStringSubmatch(input)
+	if match == nil {
+		return nil, "", false
+	}
+	names := strings.Split(match[1], ",")
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+	return names, strings.TrimSpace(match[2]), true
+}
+
+// evaluateDestructuring assigns the values of the tuple that expression gives
+// to the variables names, in order.
+func (c *Calculator) evaluateDestructuring(names []string, expression string) (Result, error) {
+	seen := map[string]bool{}
+	for _, name := range names {
+		if err := c.checkAssignable(name); err != nil {
+			return Result{}, err
+		}
+		if seen[name] {
+			return Result{}, fmt.Errorf("'%s' is assigned twice", name)
+		}
+		seen[name] = true
+	}
+
+	result, err := c.evaluateNumericInput(expression)
+	if err != nil {
+		return result, err
+	}
+	tuple, ok := result.Typed.(Tuple)
+	if l, isList := result.Typed.(List); isList {
+		tuple, ok = Tuple(l), true
+	}
+	if !ok {
+		return Result{}, fmt.Errorf("cannot assign %s to %d variables, only a tuple such as divmod(17, 5) or a list gives several values", article(result.Typed.Kind()), len(names))
+	}
+	if len(tuple) != len(names) {
+		return Result{}, fmt.Errorf("cannot assign %d values to %d variables", len(tuple), len(names))
+	}
+	if result.Interpretation != "" {
+		expression = result.Interpretation
+	}
+	var d *derivation
+	if c.TrackProvenance {
+		d = c.derive(expression, tuple)
+	}
+	parts := make([]string, len(names))
+	for i, name := range names {
+		c.store(name, tuple[i])
+		if d != nil {
+			c.derivations[name] = &derivation{expression: expression, value: tuple[i], inputs: d.inputs}
+		}
+		parts[i] = name + " = " + c.FormatValue(tuple[i])
+	}
+	return Result{Text: strings.Join(parts, ", "), Warnings: result.Warnings, Interpretation: result.Interpretation}, nil
+}
+
+// tupleExtension adds the functions that give several values, such as divmod.
+func (c *Calculator) tupleExtension() Extension {
+	return Extension{
+		Name: "tuple",
+		Functions: map[string]func([]Value) (Value, error){
+			"divmod": func(args []Value) (Value, error) {
+				if len(args) != 2 {
+					return nil, fmt.Errorf("expected 2 arguments, got %d", len(args))
+				}
+				quotient, err := c.applyBinary(floorDivOperator, args[0], args[1])
+				if err != nil {
+					return nil, err
+				}
+				// The remainder takes the sign of the divisor, as with //, so
+				// that quotient*b + remainder is a.
+				product, err := c.applyBinary(multiplyOperator, quotient, args[1])
+				if err != nil {
+					return nil, err
+				}
+				remainder, err := c.applyBinary(subtractOperator, args[0], product)
+				if err != nil {
+					return nil, err
+				}
+				return Tuple{quotient, remainder}, nil
+			},
+		},
+	}
+}
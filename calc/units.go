@@ -0,0 +1,208 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	inchesPerFoot    = 12
+	inchSubdivisions = 16
+)
+
+var (
+	quantityRegex = regexp.MustCompile(`^(\d+\s+\d+/\d+|\d+/\d+|\d+(?:\.\d+)?)(.*)$`)
+	lengthRegex   = regexp.MustCompile(`(?:(\d+(?:\.\d+)?)\s*')?\s*(?:(\d+(?:\.\d+)?(?:\s+\d+/\d+)?|\d+/\d+)\s*")?`)
+)
+
+// ingredientDensities holds typical densities in grams per milliliter, used to
+// convert between volume and mass for common kitchen ingredients.
+var ingredientDensities = map[string]float64{
+	"water":          1.0,
+	"milk":           1.03,
+	"cream":          1.01,
+	"oil":            0.92,
+	"butter":         0.96,
+	"honey":          1.42,
+	"flour":          0.53,
+	"sugar":          0.85,
+	"brown sugar":    0.93,
+	"powdered sugar": 0.51,
+	"salt":           1.22,
+	"rice":           0.78,
+	"oats":           0.38,
+	"cocoa":          0.42,
+	"yogurt":         1.03,
+}
+
+// kitchenMeasure returns the symbol and measure of the unit of volume or
+// mass name, as written in a kitchen conversion such as 2 fl oz in ml.
+func kitchenMeasure(name string) (string, measure, bool) {
+	if symbol, ok := measureAliases[name]; ok {
+		name = symbol
+	}
+	m, ok := measures[name]
+	if !ok || m.dimension != volume && m.dimension != mass {
+		return "", measure{}, false
+	}
+	return name, m, true
+}
+
+// convertKitchenUnits handles conversions of an ingredient between volume and
+// mass, such as "2 cups flour in grams", from the units of the measures
+// registry. It reports false for any other input, which includes
+// conversions without an ingredient such as 1 kg in lb, as those are
+// quantities.
+func (c *Calculator) convertKitchenUnits(input string) (string, bool, error) {
+	words := strings.Fields(strings.ToLower(input))
+	in := -1
+	for i, word := range words {
+		if word == "in" {
+			in = i
+		}
+	}
+	if in < 1 || in == len(words)-1 {
+		return "", false, nil
+	}
+	targetSymbol, target, ok := kitchenMeasure(strings.Join(words[in+1:], " "))
+	if !ok {
+		return "", false, nil
+	}
+
+	sourceSymbol, source, start, end := "", measure{}, -1, -1
+	for i := 0; i < in && start < 0; i++ {
+		if i+1 < in {
+			if symbol, m, ok := kitchenMeasure(words[i] + " " + words[i+1]); ok {
+				sourceSymbol, source, start, end = symbol, m, i, i+2
+				continue
+			}
+		}
+		if symbol, m, ok := kitchenMeasure(words[i]); ok {
+			sourceSymbol, source, start, end = symbol, m, i, i+1
+		}
+	}
+	if start < 0 {
+		return "", false, nil
+	}
+	ingredient := words[end:in]
+	if len(ingredient) > 0 && ingredient[0] == "of" {
+		ingredient = ingredient[1:]
+	}
+	for _, word := range ingredient {
+		if strings.IndexFunc(word, func(r rune) bool { return !unicode.IsLetter(r) }) >= 0 {
+			return "", false, nil
+		}
+	}
+	if len(ingredient) == 0 {
+		if source.dimension != target.dimension {
+			return "", true, fmt.Errorf("converting %s to %s needs an ingredient, e.g. '2 cups flour in grams'", sourceSymbol, targetSymbol)
+		}
+		return "", false, nil
+	}
+	if start == 0 {
+		return "", true, fmt.Errorf("missing amount before '%s'", words[0])
+	}
+
+	amount, err := c.Evaluate(expandMixedNumbers(strings.Join(words[:start], " ")))
+	if err != nil {
+		return "", true, err
+	}
+	value := amount * source.factor
+	if source.dimension != target.dimension {
+		density, ok := ingredientDensities[strings.Join(ingredient, " ")]
+		if !ok {
+			density, ok = ingredientDensities[ingredient[len(ingredient)-1]]
+		}
+		if !ok {
+			return "", true, fmt.Errorf("unknown ingredient: %s", strings.Join(ingredient, " "))
+		}
+		// The densities are in grams per milliliter, which is a thousand
+		// kilograms per cubic meter.
+		if source.dimension == volume {
+			value *= density * 1000
+		} else {
+			value /= density * 1000
+		}
+	}
+	value /= target.factor
+
+	return strconv.FormatFloat(math.Round(value*100)/100, 'f', -1, 64) + " " + targetSymbol, true, nil
+}
+
+// ParseQuantity evaluates a recipe-style quantity such as 1 1/2, 3/4, or 2.5.
+func (c *Calculator) ParseQuantity(text string) (float64, error) {
+	return c.Evaluate(expandMixedNumbers(text))
+}
+
+// ScaleRecipeLine multiplies the quantity at the start of a recipe line such as
+// "1 1/2 cups flour" by factor. Lines without a leading quantity are returned
+// unchanged.
+func (c *Calculator) ScaleRecipeLine(line string, factor float64) string {
+	match := quantityRegex.FindStringSubmatch(strings.TrimSpace(line))
+	if match == nil {
+		return line
+	}
+	quantity, err := c.ParseQuantity(match[1])
+	if err != nil {
+		return line
+	}
+	scaled, ok := formatMixedFraction(quantity*factor, inchSubdivisions)
+	if !ok {
+		scaled = strconv.FormatFloat(math.Round(quantity*factor*100)/100, 'f', -1, 64)
+	}
+	return scaled + match[2]
+}
+
+func hasFeetAndInches(input string) bool {
+	return strings.ContainsAny(input, `'"`)
+}
+
+// expandFeetAndInches rewrites lengths like 5' 3 1/2" into an expression
+// measured in inches, e.g. (5*12+3+1/2), so they can be evaluated as numbers.
+func expandFeetAndInches(input string) string {
+	return lengthRegex.ReplaceAllStringFunc(input, func(match string) string {
+		parts := lengthRegex.FindStringSubmatch(match)
+		feet, inches := parts[1], strings.Join(strings.Fields(parts[2]), "+")
+		switch {
+		case feet != "" && inches != "":
+			return fmt.Sprintf("(%s*%d+%s)", feet, inchesPerFoot, inches)
+		case feet != "":
+			return fmt.Sprintf("(%s*%d)", feet, inchesPerFoot)
+		case inches != "":
+			return "(" + inches + ")"
+		default:
+			return match
+		}
+	})
+}
+
+func formatFeetAndInches(inches float64) string {
+	if math.IsNaN(inches) || math.IsInf(inches, 0) {
+		return fmt.Sprintf("%f", inches)
+	}
+	sign := ""
+	if inches < 0 {
+		sign = "-"
+	}
+	subdivisions := math.Round(math.Abs(inches) * inchSubdivisions)
+	if subdivisions == 0 {
+		sign = ""
+	}
+	feet := math.Floor(subdivisions / (inchesPerFoot * inchSubdivisions))
+	remainder, _ := formatMixedFraction(subdivisions/inchSubdivisions-feet*inchesPerFoot, inchSubdivisions)
+
+	var result string
+	if feet == 0 {
+		result = fmt.Sprintf(`%s%s"`, sign, remainder)
+	} else {
+		result = fmt.Sprintf(`%s%.0f' %s"`, sign, feet, remainder)
+	}
+	if math.Abs(subdivisions/inchSubdivisions-math.Abs(inches)) > 1e-9 {
+		result += fmt.Sprintf(` (rounded to the nearest 1/%d")`, inchSubdivisions)
+	}
+	return result
+}
@@ -0,0 +1,311 @@
+package calc
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Value is the result of evaluating an expression or a part of one: a Bool,
+// Int, Rational, Float, or Complex, or a value of a kind added by an Extension.
+//
+// Arithmetic on two values of different kinds first promotes the one of the
+// lower kind in the order Bool, Int, Rational, Float, Complex, where true and
+// false are the Ints 1 and 0. Int and Rational arithmetic is exact. A result is
+// then normalized: a Rational that is a whole number becomes an Int, a Complex
+// without an imaginary part becomes a Float, and an exact result of more than
+// maxExactBits bits becomes a Float. Operations without an exact result, such
+// as a fractional power, and the builtin functions give Floats. Functions take
+// real numbers, so passing them a Complex with an imaginary part is an error.
+type Value interface {
+	// Kind names the kind of value, such as int or complex.
+	Kind() string
+	String() string
+}
+
+// Bool is a truth value.
+type Bool bool
+
+// Int is an exact integer.
+type Int struct {
+	*big.Int
+}
+
+// Rational is an exact fraction that is not a whole number.
+type Rational struct {
+	*big.Rat
+}
+
+// Float is a floating point number.
+type Float float64
+
+// Complex is a complex number with an imaginary part.
+type Complex complex128
+
+// The kinds of values in the order of promotion.
+const (
+	boolRank = iota
+	intRank
+	rationalRank
+	floatRank
+	complexRank
+)
+
+func (b Bool) Kind() string     { return "bool" }
+func (i Int) Kind() string      { return "int" }
+func (r Rational) Kind() string { return "rational" }
+func (f Float) Kind() string    { return "float" }
+func (z Complex) Kind() string  { return "complex" }
+
+func (b Bool) String() string {
+	return strconv.FormatBool(bool(b))
+}
+
+func (f Float) String() string {
+	return strconv.FormatFloat(float64(f), 'g', -1, 64)
+}
+
+func (z Complex) String() string {
+	return formatComplex(complex128(z), func(x float64) string { return strconv.FormatFloat(x, 'g', -1, 64) })
+}
+
+// formatComplex writes z as a + bi, formatting the parts with format.
+func formatComplex(z complex128, format func(float64) string) string {
+	sign := "+"
+	if imag(z) < 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s %s %si", format(real(z)), sign, format(math.Abs(imag(z))))
+}
+
+// isNumeric reports whether v is of one of the builtin kinds rather than of a
+// kind added by an extension.
+func isNumeric(v Value) bool {
+	switch v.(type) {
+	case Bool, Int, Rational, Float, Complex:
+		return true
+	}
+	return false
+}
+
+// isReal reports whether v is a number without an imaginary part.
+func isReal(v Value) bool {
+	z, ok := v.(Complex)
+	return isNumeric(v) && (!ok || imag(z) == 0)
+}
+
+// rank returns the position of the kind of v in the order of promotion.
+func rank(v Value) int {
+	switch v.(type) {
+	case Bool:
+		return boolRank
+	case Int:
+		return intRank
+	case Rational:
+		return rationalRank
+	case Float:
+		return floatRank
+	default:
+		return complexRank
+	}
+}
+
+// promote converts v to the kind with the given rank, which is not lower than
+// its own.
+func promote(v Value, to int) Value {
+	if b, ok := v.(Bool); ok {
+		v = Int{big.NewInt(0)}
+		if b {
+			v = Int{big.NewInt(1)}
+		}
+	}
+	switch to {
+	case rationalRank:
+		if i, ok := v.(Int); ok {
+			return Rational{new(big.Rat).SetInt(i.Int)}
+		}
+	case floatRank:
+		return Float(toFloat(v))
+	case complexRank:
+		if z, ok := v.(Complex); ok {
+			return z
+		}
+		return Complex(complex(toFloat(v), 0))
+	}
+	return v
+}
+
+// normalize returns v as the simplest kind that holds it exactly.
+func normalize(v Value) Value {
+	switch v := v.(type) {
+	case Int:
+		if v.BitLen() > maxExactBits {
+			return Float(toFloat(v))
+		}
+	case Rational:
+		if v.Num().BitLen()+v.Denom().BitLen() > maxExactBits {
+			return Float(toFloat(v))
+		}
+		if v.IsInt() {
+			return Int{new(big.Int).Set(v.Num())}
+		}
+	case Complex:
+		if imag(v) == 0 {
+			return Float(real(v))
+		}
+	}
+	return v
+}
+
+// toFloat converts a value to the nearest float64. A Complex with an
+// imaginary part is NaN, like the result of a real function outside its domain.
+func toFloat(v Value) float64 {
+	switch v := v.(type) {
+	case Bool:
+		if v {
+			return 1
+		}
+		return 0
+	case Int:
+		f, _ := new(big.Float).SetInt(v.Int).Float64()
+		return f
+	case Rational:
+		f, _ := v.Float64()
+		return f
+	case Float:
+		return float64(v)
+	case Complex:
+		if imag(v) == 0 {
+			return real(v)
+		}
+	}
+	return math.NaN()
+}
+
+// Real returns a number without an imaginary part as a float64, reporting
+// false for other values.
+func Real(v Value) (float64, bool) {
+	if !isReal(v) {
+		return 0, false
+	}
+	return toFloat(v), true
+}
+
+// realArgument converts the argument of a function to a real number.
+func realArgument(name string, v Value) (float64, error) {
+	if z, ok := v.(Complex); ok && imag(z) != 0 {
+		return 0, fmt.Errorf("%s takes real numbers, not the complex number %s", name, z)
+	}
+	if !isNumeric(v) {
+		return 0, fmt.Errorf("%s takes real numbers, not %s", name, article(v.Kind()))
+	}
+	return toFloat(v), nil
+}
+
+// exactRational returns an Int or Rational as a fraction.
+func exactRational(v Value) (*big.Rat, bool) {
+	switch v := v.(type) {
+	case Int:
+		return new(big.Rat).SetInt(v.Int), true
+	case Rational:
+		return v.Rat, true
+	}
+	return nil, false
+}
+
+// valueExpression writes v as an expression that gives it again, as 1/3 for a
+// Rational, or as its String where it cannot be written as one.
+func valueExpression(v Value) string {
+	switch v := v.(type) {
+	case Rational:
+		return "(" + v.RatString() + ")"
+	case interface{ Expression() string }:
+		return v.Expression()
+	}
+	return v.String()
+}
+
+// parseNumber reads a number token. Integers are Ints and decimals with up to
+// maxExactDigits significant digits are exact; longer numbers are taken to be
+// floating point results and read as Floats.
+func parseNumber(token string) (Value, error) {
+	if strings.Trim(token, "0123456789") == "" {
+		if i, ok := new(big.Int).SetString(token, 10); ok {
+			return normalize(Int{i}), nil
+		}
+	}
+	if significantDigits(token) <= maxExactDigits {
+		if r, ok := new(big.Rat).SetString(token); ok {
+			return normalize(Rational{r}), nil
+		}
+	}
+	f, err := strconv.ParseFloat(token, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid number: %s", token)
+	}
+	return Float(f), nil
+}
+
+// FormatValue formats a value the way EvaluateInput displays results: like
+// Format, except that exact integers too large for a float64 are shown in
+// full, fraction mode shows exact fractions, complex numbers are shown as
+// a + bi, and real numbers are shown in Base where they can be written in it.
+func (c *Calculator) FormatValue(v Value) string {
+	if basePrefixes[c.Base] != "" {
+		if output, ok := formatNumberInBase(v, c.Base); ok {
+			return output
+		}
+	}
+	switch v := v.(type) {
+	case Bool:
+		return v.String()
+	case Int:
+		if c.FractionMode || v.CmpAbs(big.NewInt(maxSafeInteger)) >= 0 {
+			if c.Grouping {
+				return groupThousands(v.String())
+			}
+			return v.String()
+		}
+	case Rational:
+		if c.FractionMode {
+			return formatRational(v.Rat)
+		}
+	case Complex:
+		return formatComplex(complex128(v), c.Format)
+	case Polynomial:
+		return v.format(c.FractionMode)
+	case Record:
+		if v.display != "" {
+			return v.display
+		}
+		return c.formatRecord(v, "")
+	case Matrix:
+		return c.formatMatrix(v)
+	case List:
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = c.FormatValue(element)
+		}
+		return "[" + strings.Join(parts, ", ") + "]"
+	case Tuple:
+		parts := make([]string, len(v))
+		for i, element := range v {
+			parts[i] = c.FormatValue(element)
+		}
+		return "(" + strings.Join(parts, ", ") + ")"
+	case Quantity:
+		if v.display != "" {
+			return v.display
+		}
+		if len(v.units) == 1 && v.units[0].power == 1 && v.units[0].measure.dimension == money {
+			return c.formatMoney(v.amount, v.units[0].symbol)
+		}
+		return c.Format(v.amount) + " " + v.Unit()
+	case Float:
+	default:
+		return v.String()
+	}
+	return c.Format(toFloat(v))
+}
@@ -0,0 +1,379 @@
+package calc
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	ansName      = "ans"
+	ansShortName = "_"
+	// maxAnswers is the number of previous results kept for ans(n).
+	maxAnswers = 10
+
+	// scopeSeparator joins a workspace and a variable name, as in budget::total.
+	scopeSeparator = "::"
+)
+
+var (
+	assignmentRegex = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*)\s*=\s*([^=].*)$`)
+	letRegex        = regexp.MustCompile(`^let\s+(.+?)\s+in\s+(.+)$`)
+)
+
+// reservedNames cannot be assigned or defined because they already mean
+// something in the input, as do the names of the builtins.
+var reservedNames = map[string]bool{
+	"weightedavg": true, "gpa": true, "proportion": true, "solve": true, "integrate": true, "plot": true, "table": true, "seed": true, "factor": true, "splitratio": true, "unitprice": true, "better": true, "within": true, "intol": true,
+	"workdays": true, "adddays": true, "age": true, "until": true,
+	"cfrac": true, "approx_frac": true, "hex": true, "oct": true, "bin": true,
+	"assert": true, "mod": true, "xor": true, "in": true, "and": true, "or": true, "not": true, "if": true, "piecewise": true,
+}
+
+func isReserved(name string) bool {
+	_, isBuiltin := builtins[strings.ToLower(name)]
+	_, isLogarithm := logBase(strings.ToLower(name))
+	return isBuiltin || isLogarithm || reservedNames[strings.ToLower(name)]
+}
+
+// parseAssignment splits a statement such as x = 3.5 into the variable name and
+// the expression that gives its value.
+func parseAssignment(input string) (string, string, bool) {
+	match := assignmentRegex.FindStringSubmatch(input)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], strings.TrimSpace(match[2]), true
+}
+
+// lookupVariable returns the value of a session variable, of ans, or of a
+// constant.
+func (c *Calculator) lookupVariable(name string) (Value, error) {
+	if name == ansName || name == ansShortName {
+		return c.answer(name, 1)
+	}
+	if value, ok := c.Variables[name]; ok {
+		return value, nil
+	}
+	if value, ok := c.extensionConstant(name); ok {
+		return value, nil
+	}
+	if value, ok := constants[name]; ok {
+		return Float(value), nil
+	}
+	return nil, fmt.Errorf("undefined variable: %s", name)
+}
+
+// answer returns the nth previous result, ans(1) being ans.
+func (c *Calculator) answer(name string, n int) (Value, error) {
+	switch {
+	case len(c.answers) == 0:
+		return nil, fmt.Errorf("%s has no value yet, there is no previous result", name)
+	case n < 1 || n > maxAnswers:
+		return nil, fmt.Errorf("%s(n) takes n from 1 to %d", name, maxAnswers)
+	case n > len(c.answers):
+		return nil, fmt.Errorf("%s(%d) has no value yet, there are only %d previous results", name, n, len(c.answers))
+	}
+	return c.answers[n-1], nil
+}
+
+// lookupValue returns the value of ans or of a variable, including one of
+// another workspace such as budget::total, or else 1 of the unit name or the
+// constant name, unless a variable of the same name hides it, as the
+// parameter of a function does.
+func (c *Calculator) lookupValue(name string) (Value, bool) {
+	if i := strings.Index(name, scopeSeparator); i >= 0 {
+		value, err := c.lookupQualified(name[:i], name[i+len(scopeSeparator):])
+		return value, err == nil
+	}
+	if name == ansName || name == ansShortName {
+		if len(c.answers) == 0 {
+			return nil, false
+		}
+		return c.answers[0], true
+	}
+	if value, ok := c.Variables[name]; ok {
+		return value, true
+	}
+	if value, ok := c.extensionConstant(name); ok {
+		return value, true
+	}
+	if measure, ok := c.lookupMeasure(name); ok {
+		return measure, true
+	}
+	value, ok := constants[name]
+	return Float(value), ok
+}
+
+// bindUnknown makes name a variable, v to begin with, so that an expression
+// in it can be compiled once and evaluated as the value changes. The function
+// it returns restores the variable that name was before, if any.
+func (c *Calculator) bindUnknown(name string, v Value) func() {
+	previous, existed := c.Variables[name]
+	if c.Variables == nil {
+		c.Variables = map[string]Value{}
+	}
+	c.Variables[name] = v
+	return func() {
+		delete(c.Variables, name)
+		if existed {
+			c.Variables[name] = previous
+		}
+		c.clearMemos()
+	}
+}
+
+// isMeasure reports whether name is a unit, such as km, rather than a
+// variable.
+func (c *Calculator) isMeasure(name string) bool {
+	if _, ok := c.Variables[name]; ok || name == ansName || name == ansShortName {
+		return false
+	}
+	_, ok := c.lookupMeasure(name)
+	return ok
+}
+
+// isRealName reports whether token is a variable that holds a real number,
+// which a unit after it multiplies as it does a number, as in d km.
+func (c *Calculator) isRealName(token string) bool {
+	value, ok := c.lookupValue(token)
+	return ok && !c.isMeasure(token) && isReal(value)
+}
+
+// lookupQualified returns the value of a variable in another workspace.
+func (c *Calculator) lookupQualified(workspace, name string) (Value, error) {
+	other, ok := c.Workspaces[workspace]
+	if !ok {
+		return nil, fmt.Errorf("unknown workspace: %s", workspace)
+	}
+	value, err := other.lookupVariable(name)
+	if err != nil {
+		return nil, fmt.Errorf("%s in workspace %s", err, workspace)
+	}
+	return value, nil
+}
+
+// checkAssignable reports an error if name cannot be given a value.
+func (c *Calculator) checkAssignable(name string) error {
+	_, isExtension := c.extensionFunction(name)
+	if _, ok := c.Functions[name]; isReserved(name) || ok || isExtension {
+		return fmt.Errorf("cannot assign to '%s', it is a function name", name)
+	}
+	if name == ansName || name == ansShortName {
+		return fmt.Errorf("cannot assign to '%s', it holds the previous result", name)
+	}
+	return nil
+}
+
+func (c *Calculator) evaluateAssignment(name, expression string) (Result, error) {
+	if err := c.checkAssignable(name); err != nil {
+		return Result{}, err
+	}
+	if _, ok, _ := c.evaluateSpecialInput(expression); ok {
+		if _, err := c.compile(expression); err == nil {
+			return c.assignValue(name, expression)
+		}
+		return Result{}, fmt.Errorf("cannot assign to '%s', %s only shows a result rather than giving a value", name, strings.TrimSpace(expression))
+	}
+
+	return c.assignValue(name, expression)
+}
+
+// assignValue stores the value of expression in the variable name.
+func (c *Calculator) assignValue(name, expression string) (Result, error) {
+	result, err := c.evaluateNumericInput(expression)
+	if err != nil {
+		return result, err
+	}
+	if result.Interpretation != "" {
+		expression = result.Interpretation
+	}
+	var d *derivation
+	if c.TrackProvenance {
+		d = c.derive(expression, result.Typed)
+	}
+	c.store(name, result.Typed)
+	if d != nil {
+		c.derivations[name] = d
+	}
+	if c.definitions == nil {
+		c.definitions = map[string]assignment{}
+	}
+	c.assignments++
+	c.definitions[name] = assignment{expression: expression, order: c.assignments}
+	result.Text = name + " = " + result.Text
+	return result, nil
+}
+
+// store sets the variable name to v, dropping any derivation and definition
+// of its previous value.
+func (c *Calculator) store(name string, v Value) {
+	if c.Variables == nil {
+		c.Variables = map[string]Value{}
+	}
+	delete(c.derivations, name)
+	delete(c.definitions, name)
+	c.Variables[name] = v
+	c.clearMemos()
+}
+
+// assignment is the expression a session variable was assigned, and the
+// order of the assignment among those of the session.
+type assignment struct {
+	expression string
+	order      int
+}
+
+// Definition returns the expression that the session variable name was last
+// assigned, as a*b for c = a*b, if it was assigned one.
+func (c *Calculator) Definition(name string) (string, bool) {
+	d, ok := c.definitions[name]
+	return d.expression, ok
+}
+
+// SessionAssignments returns the assignments that give a new calculator with
+// the same settings the session variables of c, in the order they were made.
+// Each variable is assigned the expression that defined it, as in c = a*b,
+// where evaluating that again after the assignments before it gives the same
+// value, and its value otherwise, written exactly, as in 1/3. Variables
+// whose values cannot be written, such as records, are left out.
+func (c *Calculator) SessionAssignments() []string {
+	names := make([]string, 0, len(c.Variables))
+	for name := range c.Variables {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := c.definitions[names[i]].order, c.definitions[names[j]].order
+		return a < b || a == b && names[i] < names[j]
+	})
+
+	replay := New()
+	replay.ImplicitTight, replay.FractionMode, replay.AngleUnit = c.ImplicitTight, c.FractionMode, c.AngleUnit
+	replay.Holidays, replay.Rates, replay.GradeScale = c.Holidays, c.Rates, c.GradeScale
+	var lines []string
+	for _, name := range names {
+		value := c.Variables[name]
+		if d, ok := c.definitions[name]; ok {
+			line := name + " = " + d.expression
+			if result, err := replay.EvaluateInput(line); err == nil && sameValue(result.Typed, value) {
+				lines = append(lines, line)
+				continue
+			}
+		}
+		line := name + " = " + valueExpression(value)
+		if _, err := replay.EvaluateInput(line); err == nil {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
+// sameValue reports whether a and b are the same value of the same kind.
+func sameValue(a, b Value) bool {
+	return a != nil && b != nil && a.Kind() == b.Kind() && a.String() == b.String()
+}
+
+// parseLet splits an expression such as let a = 2, b = a + 1 in a*b into the
+// names and value expressions of its bindings and its body.
+func parseLet(input string) ([]string, []string, string, bool) {
+	match := letRegex.FindStringSubmatch(strings.TrimSpace(input))
+	if match == nil {
+		return nil, nil, "", false
+	}
+	var names, values []string
+	for _, binding := range splitArguments(match[1]) {
+		name, value, ok := parseAssignment(binding)
+		if !ok {
+			return nil, nil, "", false
+		}
+		names = append(names, name)
+		values = append(values, value)
+	}
+	return names, values, match[2], true
+}
+
+// compileLet compiles a let expression with the names of its bindings in
+// scope, each binding after the ones before it.
+func (c *Calculator) compileLet(names, values []string, body string) (node, error) {
+	for _, name := range names {
+		if _, ok := c.Functions[name]; isReserved(name) || ok || name == ansName || name == ansShortName {
+			return nil, fmt.Errorf("cannot bind '%s' in let, it is a built-in or function name", name)
+		}
+	}
+
+	let := letNode{names: names, values: make([]node, len(names))}
+	err := c.withBindings(nil, nil, func() error {
+		for i, name := range names {
+			value, err := c.compileExpression(values[i])
+			if err != nil {
+				return err
+			}
+			let.values[i] = value
+			c.Variables[name] = placeholders(1)[0]
+		}
+		var err error
+		let.body, err = c.compileExpression(body)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return let, nil
+}
+
+// evaluateLet evaluates the body of a let expression with its bindings in
+// scope. Each binding can use the ones before it, and none of them outlive
+// the expression.
+func (c *Calculator) evaluateLet(n letNode) (Value, error) {
+	var result Value
+	err := c.withBindings(nil, nil, func() error {
+		for i, name := range n.names {
+			value, err := c.evaluateNode(n.values[i])
+			if err != nil {
+				return err
+			}
+			c.Variables[name] = value
+		}
+		var err error
+		result, err = c.evaluateNode(n.body)
+		return err
+	})
+	return result, err
+}
+
+// placeholders returns count zeros, which stand in for the values of
+// variables when an expression in them is only checked.
+func placeholders(count int) []Value {
+	values := make([]Value, count)
+	for i := range values {
+		values[i] = Int{big.NewInt(0)}
+	}
+	return values
+}
+
+// withBindings runs fn with each of names set as a variable to the matching
+// value, on top of the variables in scope, restoring them afterwards.
+func (c *Calculator) withBindings(names []string, values []Value, fn func() error) error {
+	return c.withScope(c.Variables, names, values, fn)
+}
+
+// withScope runs fn with the variables of base and each of names set as a
+// variable to the matching value, restoring the variables afterwards.
+func (c *Calculator) withScope(base map[string]Value, names []string, values []Value, fn func() error) error {
+	saved := c.Variables
+	if c.session == nil {
+		c.session = saved
+		defer func() { c.session = nil }()
+	}
+	c.Variables = make(map[string]Value, len(base)+len(names))
+	for name, value := range base {
+		c.Variables[name] = value
+	}
+	for i, name := range names {
+		c.Variables[name] = values[i]
+	}
+	defer func() { c.Variables = saved }()
+	return fn()
+}
@@ -0,0 +1,62 @@
+package calc
+
+import (
+	"fmt"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Version is the semantic version of the calculator. Release builds can set
+// it with -ldflags "-X github.com/XeinTDM/Go-Calculator/calc.Version=1.2.3".
+var Version = "1.0.0"
+
+// Grammar names the dialect of the input the calculator reads. It changes
+// whenever the same input can mean something else, as when an operator is
+// added or its precedence changes, so that scripts can be pinned to it.
+const Grammar = "gocalc-2025.1"
+
+// BuildInfo describes the calculator that evaluates expressions, for bug
+// reports and for programs that need to pin its exact behavior.
+type BuildInfo struct {
+	Version   string   `json:"version"`
+	Grammar   string   `json:"grammar"`
+	Packs     []string `json:"packs"`
+	GoVersion string   `json:"go_version"`
+	// Module and Sum are the path and checksum of the main module of the
+	// program, when it was built from a module download.
+	Module string `json:"module,omitempty"`
+	Sum    string `json:"sum,omitempty"`
+}
+
+func (b BuildInfo) String() string {
+	lines := []string{
+		"Version: " + b.Version,
+		"Grammar: " + b.Grammar,
+		"Packs: " + strings.Join(b.Packs, ", "),
+		"Go: " + b.GoVersion,
+	}
+	if len(b.Packs) == 0 {
+		lines[2] = "Packs: none"
+	}
+	if b.Module != "" {
+		lines = append(lines, fmt.Sprintf("Module: %s %s", b.Module, b.Sum))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Info returns the version and grammar of c, the packs loaded into it with
+// Register and LoadPack, and how the program was built.
+func (c *Calculator) Info() BuildInfo {
+	info := BuildInfo{
+		Version:   Version,
+		Grammar:   Grammar,
+		Packs:     append([]string{}, c.packs...),
+		GoVersion: runtime.Version(),
+	}
+	if build, ok := debug.ReadBuildInfo(); ok && build.Main.Sum != "" {
+		info.Module = build.Main.Path + "@" + build.Main.Version
+		info.Sum = build.Main.Sum
+	}
+	return info
+}
@@ -0,0 +1,376 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// The exit codes of the calculator when it evaluates an expression given on
+// the command line, or stops at a failed assertion.
+const (
+	exitSuccess         = 0
+	exitAssertion       = 1
+	exitParseError      = 2
+	exitEvaluationError = 3
+	exitDomainError     = 4
+	exitTimeout         = 5
+)
+
+// printKeyword starts a line of a -f script whose result is printed, as in
+// print r^2.
+const printKeyword = "print"
+
+// maxEchoedInput is the number of characters of a failed line that the
+// summary of failures repeats.
+const maxEchoedInput = 60
+
+// errNotReal is the error of a calculation whose result is NaN, outside the
+// prompt where NaN is shown as the result.
+var errNotReal = errors.New("the result is not a real number")
+
+// Verbosity levels of command line mode.
+const (
+	quietLevel = iota
+	normalLevel
+	verboseLevel
+)
+
+// evaluateArguments evaluates each of args as an expression, in order and
+// sharing variables and functions, prints the result of the last one or, with
+// all, of every one at the given verbosity, and returns the exit code. It
+// stops at the first error.
+func (c *Calculator) evaluateArguments(args []string, verbosity int, all bool, timeout time.Duration) int {
+	type outcome struct {
+		result  calc.Result
+		err     error
+		elapsed time.Duration
+	}
+	outcomes := make(chan outcome, len(args))
+	go func() {
+		for _, arg := range args {
+			start := time.Now()
+			result, err := c.engine.EvaluateInput(arg)
+			if err == nil && result.Numeric && math.IsNaN(result.Value) {
+				err = errNotReal
+			}
+			outcomes <- outcome{result, err, time.Since(start)}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	deadline := time.After(timeout)
+	for i := range args {
+		var o outcome
+		select {
+		case o = <-outcomes:
+		case <-deadline:
+			fmt.Fprintf(os.Stderr, "Error: the calculation did not finish within %s\n", timeout)
+			return exitTimeout
+		}
+		if o.err != nil {
+			fmt.Fprintln(os.Stderr, "Error:", o.err)
+			printErrorPosition(os.Stderr, o.err)
+			return exitCode(o.err, o.result)
+		}
+		if all || i == len(args)-1 {
+			printArgumentResult(o.result, verbosity, o.elapsed)
+		}
+	}
+	return exitSuccess
+}
+
+// printArgumentResult prints the result of a command line expression.
+func printArgumentResult(result calc.Result, verbosity int, elapsed time.Duration) {
+	if verbosity == quietLevel {
+		fmt.Println(result.Text)
+		return
+	}
+	if result.Interpretation != "" {
+		fmt.Println("Interpreted as:", result.Interpretation)
+	}
+	if verbosity == verboseLevel {
+		for _, warning := range result.Warnings {
+			fmt.Println("Warning:", warning)
+		}
+	}
+	fmt.Println("Result:", result.Text)
+	if verbosity == verboseLevel {
+		fmt.Println("Time:", elapsed)
+	}
+}
+
+// printErrorPosition prints the expression of a syntax error with a caret
+// under the token at fault, or under each of them, with its problem, when
+// there are several.
+func printErrorPosition(w io.Writer, err error) {
+	var several *calc.SyntaxErrors
+	var syntax *calc.SyntaxError
+	switch {
+	case errors.As(err, &several):
+		fmt.Fprintln(w, "  "+several.Errors[0].Input)
+		for _, problem := range several.Errors {
+			fmt.Fprintf(w, "  %s^ column %d: %s\n", strings.Repeat(" ", problem.Column()-1), problem.Column(), problem)
+		}
+	case errors.As(err, &syntax) && syntax.Input != "":
+		fmt.Fprintln(w, "  "+syntax.Input)
+		fmt.Fprintf(w, "  %s^ column %d\n", strings.Repeat(" ", syntax.Column()-1), syntax.Column())
+	}
+}
+
+// batchFailure is a line of batch input or of a script that failed.
+type batchFailure struct {
+	line  int
+	input string
+	err   error
+}
+
+// runBatch evaluates the lines of input that is not a terminal, one
+// expression or command per line, without the banner and prompts, and returns
+// the exit code of the first failure. Unless failFast is set it goes on after
+// a failure and sums up the failed lines at the end. It stops at a line that
+// is not a math expression at all, such as binary data, as what follows is
+// most likely more of it.
+func (c *Calculator) runBatch(verbosity int) int {
+	c.batch, c.verbosity = true, verbosity
+	count := 0
+	for c.line = 1; ; c.line++ {
+		line, err := c.reader.ReadString('\n')
+		input := strings.TrimSpace(line)
+		if strings.ToLower(input) == exitCommand {
+			break
+		}
+		if input != "" {
+			count++
+			c.input = input
+			c.recordInput(input)
+			c.execute(input)
+			if c.failFast && c.failure != exitSuccess {
+				return c.failure
+			}
+			if n := len(c.failures); n > 0 && c.failures[n-1].line == c.line && errors.Is(c.failures[n-1].err, calc.ErrNotExpression) {
+				fmt.Fprintf(os.Stderr, "Stopped reading input at line %d, which is not a math expression\n", c.line)
+				break
+			}
+		}
+		if err != nil {
+			if err != io.EOF {
+				fmt.Fprintln(os.Stderr, "Error reading input:", err)
+				return exitEvaluationError
+			}
+			break
+		}
+	}
+	c.printFailures(count)
+	return c.failure
+}
+
+// printFailures sums up on stderr the lines that failed out of count, if any
+// did.
+func (c *Calculator) printFailures(count int) {
+	if len(c.failures) == 0 {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "%d of %d lines failed:\n", len(c.failures), count)
+	for _, failure := range c.failures {
+		fmt.Fprintf(os.Stderr, "  line %d: %s: %s\n", failure.line, echoed(failure.input), failure.err)
+	}
+}
+
+// echoed returns input as the summary of failures repeats it: shortened to
+// maxEchoedInput characters, with any that cannot be printed as ?.
+func echoed(input string) string {
+	runes := []rune(strings.Map(func(r rune) rune {
+		if !unicode.IsPrint(r) || r == utf8.RuneError {
+			return '?'
+		}
+		return r
+	}, input))
+	if len(runes) > maxEchoedInput {
+		return string(runes[:maxEchoedInput]) + "…"
+	}
+	return string(runes)
+}
+
+// runScript evaluates the lines of the script file at path in order, sharing
+// variables and functions, and prints the result of the lines starting with
+// print and, with printLast, of the last line. Empty lines and lines starting
+// with # are skipped. It returns the exit code of the first error, after
+// summing up the failed lines, or stops there when failFast is set.
+func (c *Calculator) runScript(path string, verbosity int, printLast bool) int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: cannot read %s\n", path)
+		return exitEvaluationError
+	}
+	var lines []string
+	var numbers []int
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+			numbers = append(numbers, i+1)
+		}
+	}
+
+	c.batch, c.verbosity = true, verbosity
+	defer func() { c.silent = false }()
+	for i, line := range lines {
+		if strings.ToLower(line) == exitCommand {
+			break
+		}
+		c.line, c.input = numbers[i], line
+		c.silent = i < len(lines)-1 || !printLast
+		if fields := strings.Fields(line); len(fields) > 1 && fields[0] == printKeyword && fields[1] != "=" {
+			line = strings.TrimSpace(line[len(printKeyword):])
+			c.silent = false
+		}
+		c.execute(line)
+		if c.failFast && c.failure != exitSuccess {
+			return c.failure
+		}
+	}
+	c.printFailures(len(lines))
+	return c.failure
+}
+
+// reportError prints the error of an input: at the prompt on stdout, and in
+// batch mode on stderr, where the first error decides the exit code.
+func (c *Calculator) reportError(err error, result calc.Result) {
+	var assertion *calc.AssertionError
+	switch {
+	case !c.batch:
+		fmt.Println("Error:", err)
+	case errors.As(err, &assertion):
+		fmt.Fprintln(os.Stderr, "Assertion failed:", assertion.Message)
+		os.Exit(exitAssertion)
+	default:
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		printErrorPosition(os.Stderr, err)
+		if c.failure == exitSuccess {
+			c.failure = exitCode(err, result)
+		}
+		c.failures = append(c.failures, batchFailure{line: c.line, input: c.input, err: err})
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// exitCode returns the exit code for the error of a calculation.
+func exitCode(err error, result calc.Result) int {
+	var assertion *calc.AssertionError
+	var syntax *calc.SyntaxError
+	switch {
+	case errors.As(err, &assertion):
+		return exitAssertion
+	case errors.As(err, &syntax):
+		return exitParseError
+	case errors.Is(err, calc.ErrDivideByZero), errors.Is(err, calc.ErrDomain), result.Numeric && math.IsNaN(result.Value):
+		return exitDomainError
+	default:
+		return exitEvaluationError
+	}
+}
+
+// definitions collects the -D flags, each a variable assignment such as r=5.
+type definitions []string
+
+func (d *definitions) String() string {
+	return strings.Join(*d, ", ")
+}
+
+func (d *definitions) Set(value string) error {
+	if !strings.Contains(value, "=") {
+		return fmt.Errorf("expected name=value, got '%s'", value)
+	}
+	*d = append(*d, value)
+	return nil
+}
+
+// runCommandLine sets the variables of the -D flags and evaluates the -f
+// script and the expressions on the command line, if there are any, or else
+// the lines of standard input when it is not a terminal, and exits with its
+// exit code.
+// Otherwise it returns so that the interactive calculator can start with
+// those variables.
+func (c *Calculator) runCommandLine() {
+	quiet := flag.Bool("q", false, "print only the result")
+	verbose := flag.Bool("v", false, "also print warnings and the time the calculation took")
+	all := flag.Bool("all", false, "print the result of every expression rather than only the last")
+	interactive := flag.Bool("i", false, "start the interactive calculator even when standard input is not a terminal")
+	history := flag.Bool("history", false, "keep the history of the interactive calculator between runs in ~/"+historyFileName)
+	script := flag.String("f", "", "evaluate the lines of a script file, printing the result of the last one and of those starting with print")
+	version := flag.Bool("version", false, "print the version, grammar, and build of the calculator and exit")
+	failFast := flag.Bool("fail-fast", false, "stop piped input and -f scripts at the first error rather than summing up the failed lines at the end")
+	timeout := flag.Duration("timeout", 10*time.Second, "the longest a calculation may take")
+	decimals := flag.Int("round", c.engine.Decimals, "the number of decimal places results are shown with; calculations keep full precision")
+	serve := flag.String("serve", "", "answer POST /evaluate requests with JSON at an address such as :8080 rather than calculating here")
+	rates := flag.String("rates", os.Getenv("GOCALC_RATES"), "the URL of live exchange rates as JSON with the rates by currency code under \"rates\"; defaults to $GOCALC_RATES, and without it the bundled rates are used")
+	var defines definitions
+	flag.Var(&defines, "D", "set a variable before calculating, as in -D r=5; may be repeated")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "Usage: calculator [-version] [-q | -v] [-all] [-i] [-history] [-f script] [-fail-fast] [-round places] [-rates url] [-timeout duration] [-serve addr] [-D name=value ...] [--] [expression ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *version {
+		fmt.Println(c.engine.Info())
+		os.Exit(exitSuccess)
+	}
+	if *decimals < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -round needs a number of decimal places of 0 or more")
+		os.Exit(exitParseError)
+	}
+	c.engine.Decimals = *decimals
+	c.failFast = *failFast
+	if *rates != "" {
+		c.engine.Rates = &calc.HTTPRates{URL: *rates}
+	}
+	for _, define := range defines {
+		if _, err := c.engine.EvaluateInput(define); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: -D %s: %s\n", define, err)
+			os.Exit(exitCode(err, calc.Result{}))
+		}
+	}
+
+	if *serve != "" {
+		os.Exit(c.serve(*serve, *timeout))
+	}
+
+	verbosity := normalLevel
+	if *quiet {
+		verbosity = quietLevel
+	} else if *verbose {
+		verbosity = verboseLevel
+	}
+	if *script != "" {
+		if code := c.runScript(*script, verbosity, flag.NArg() == 0); code != exitSuccess || flag.NArg() == 0 {
+			os.Exit(code)
+		}
+	}
+	if flag.NArg() == 0 {
+		if *interactive || isTerminal(os.Stdin) {
+			if *history {
+				c.openHistory()
+			}
+			return
+		}
+		os.Exit(c.runBatch(verbosity))
+	}
+	os.Exit(c.evaluateArguments(flag.Args(), verbosity, *all, *timeout))
+}
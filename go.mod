@@ -0,0 +1,3 @@
+module github.com/XeinTDM/Go-Calculator
+
+go 1.16
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+const (
+	// maxHistory is the number of calculations a workspace keeps in its
+	// history; older ones are dropped.
+	maxHistory = 1000
+	// historyFileName is the file in the home directory that keeps the history
+	// between runs when the calculator is started with -history.
+	historyFileName = ".gocalc_history"
+	// lastKeyword recalls the last calculation, like !!.
+	lastKeyword = "last"
+)
+
+// recallRegex matches a reference to a calculation in the history: !3 for the
+// third, !-2 for the one before the last, and !! for the last.
+var recallRegex = regexp.MustCompile(`^!(-?\d+|!)$`)
+
+// historyRecord is a session entry as exported by 'history export'.
+type historyRecord struct {
+	Expression string   `json:"expression"`
+	Result     string   `json:"result,omitempty"`
+	Value      *float64 `json:"value,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Timestamp  string   `json:"timestamp"`
+	Tags       []string `json:"tags"`
+}
+
+func newHistoryRecord(entry sessionEntry) historyRecord {
+	record := historyRecord{
+		Expression: entry.input,
+		Timestamp:  entry.timestamp.Format(time.RFC3339),
+		Tags:       entry.tags,
+	}
+	if record.Tags == nil {
+		record.Tags = []string{}
+	}
+	if entry.err != nil {
+		record.Error = entry.err.Error()
+		return record
+	}
+	record.Result = entry.result.Text
+	if entry.result.Numeric {
+		value := entry.result.Value
+		record.Value = &value
+	}
+	return record
+}
+
+// handleHistoryCommand lists the calculations of the workspace with 'history',
+// searches them with 'history search <text>', and exports them with
+// 'history export [--format csv|json] [file]'. Without a format the file
+// extension decides, and without a file the export is printed.
+func (c *Calculator) handleHistoryCommand(args []string) {
+	if len(args) == 0 {
+		if len(c.entries) == 0 {
+			fmt.Println("No calculations yet")
+		}
+		for i, entry := range c.entries {
+			printHistoryEntry(i, entry)
+		}
+		return
+	}
+	switch strings.ToLower(args[0]) {
+	case "search":
+		if len(args) == 1 {
+			fmt.Println("Error: use 'history search <text>'")
+			return
+		}
+		c.searchHistory(strings.Join(args[1:], " "))
+		return
+	case "export":
+	default:
+		fmt.Println("Error: use 'history', 'history search <text>', or 'history export [--format csv|json] [file]'")
+		return
+	}
+
+	format, path := "", ""
+	for i := 1; i < len(args); i++ {
+		switch {
+		case args[i] == "--format" && i+1 < len(args):
+			format = strings.ToLower(args[i+1])
+			i++
+		case path == "":
+			path = args[i]
+		default:
+			fmt.Println("Error: use 'history export [--format csv|json] [file]'")
+			return
+		}
+	}
+	if format == "" {
+		format = "csv"
+		if strings.ToLower(filepath.Ext(path)) == ".json" {
+			format = "json"
+		}
+	}
+
+	var data []byte
+	var err error
+	switch format {
+	case "csv":
+		data, err = historyCSV(c.entries)
+	case "json":
+		data, err = historyJSON(c.entries, c.engine.Info())
+	default:
+		fmt.Println("Error: the history can be exported as csv or json")
+		return
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	if path == "" {
+		fmt.Print(string(data))
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Wrote %s to %s\n", pluralize(len(c.entries), "calculation"), path)
+}
+
+func printHistoryEntry(i int, entry sessionEntry) {
+	if entry.restored {
+		fmt.Printf("%d. %s  (previous session)\n", i+1, entry.input)
+	} else if entry.err != nil {
+		fmt.Printf("%d. %s  (error: %s)\n", i+1, entry.input, entry.err)
+	} else {
+		fmt.Printf("%d. %s = %s\n", i+1, entry.input, entry.result.Text)
+	}
+}
+
+// addEntry adds a calculation to the history of the workspace, dropping the
+// oldest one when the history is full, and saves it to the history file.
+func (c *Calculator) addEntry(entry sessionEntry) {
+	c.entries = append(c.entries, entry)
+	if len(c.entries) > maxHistory {
+		c.entries = c.entries[len(c.entries)-maxHistory:]
+	}
+	if c.historyPath == "" || c.workspaceName != defaultWorkspace {
+		return
+	}
+	file, err := os.OpenFile(c.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = file.WriteString(historyLine(entry))
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		fmt.Println("Error: cannot save the history, it is kept for this session only:", err)
+		c.historyPath = ""
+	}
+}
+
+// historyLine writes an entry as a line of the history file: its time and its
+// input with its tags, separated by a tab.
+func historyLine(entry sessionEntry) string {
+	input := entry.input
+	if len(entry.tags) > 0 {
+		input += " #" + strings.Join(entry.tags, " #")
+	}
+	return entry.timestamp.Format(time.RFC3339) + "\t" + input + "\n"
+}
+
+// openHistory restores the calculations of previous runs from the history
+// file in the home directory and saves later ones to it. A file longer than
+// maxHistory is cut down to the latest calculations.
+func (c *Calculator) openHistory() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		fmt.Println("Error: cannot find the history file:", err)
+		return
+	}
+	path := filepath.Join(home, historyFileName)
+	file, err := os.Open(path)
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Println("Error: cannot read the history:", err)
+		return
+	}
+	var lines []string
+	if err == nil {
+		scanner := bufio.NewScanner(file)
+		for scanner.Scan() {
+			stamp := strings.SplitN(scanner.Text(), "\t", 2)
+			timestamp, err := time.Parse(time.RFC3339, stamp[0])
+			if len(stamp) != 2 || err != nil || strings.TrimSpace(stamp[1]) == "" {
+				continue
+			}
+			input, tags := splitTags(stamp[1])
+			c.entries = append(c.entries, sessionEntry{input: input, tags: tags, timestamp: timestamp, restored: true})
+			c.editor.history = append(c.editor.history, stamp[1])
+			lines = append(lines, scanner.Text()+"\n")
+		}
+		file.Close()
+	}
+	if len(c.entries) > maxHistory {
+		c.entries = c.entries[len(c.entries)-maxHistory:]
+		c.editor.history = c.editor.history[len(c.editor.history)-maxHistory:]
+		if err := os.WriteFile(path, []byte(strings.Join(lines[len(lines)-maxHistory:], "")), 0600); err != nil {
+			fmt.Println("Error: cannot save the history:", err)
+			return
+		}
+	}
+	c.historyPath = path
+}
+
+// expandRecall replaces input that refers to a calculation in the history,
+// such as !3, !-2, !!, or last, with the input of that calculation.
+func (c *Calculator) expandRecall(input string) (string, error) {
+	reference := input
+	if strings.ToLower(input) == lastKeyword {
+		reference = "!!"
+	}
+	match := recallRegex.FindStringSubmatch(reference)
+	if match == nil {
+		return input, nil
+	}
+	if len(c.entries) == 0 {
+		return "", fmt.Errorf("there are no calculations in the history yet")
+	}
+	n := len(c.entries)
+	if match[1] != "!" {
+		n, _ = strconv.Atoi(match[1])
+		if n < 0 {
+			n += len(c.entries) + 1
+		}
+	}
+	if n < 1 || n > len(c.entries) {
+		return "", fmt.Errorf("there is no calculation %s in the history, see 'history'", input)
+	}
+	return c.entries[n-1].input, nil
+}
+
+// searchHistory lists the calculations whose input or tags contain query,
+// followed by those that only match it fuzzily, with its characters in order
+// but not next to each other, so that sqt finds sqrt(2).
+func (c *Calculator) searchHistory(query string) {
+	query = strings.ToLower(query)
+	var exact, fuzzy []int
+	for i, entry := range c.entries {
+		text := strings.ToLower(entry.input)
+		if len(entry.tags) > 0 {
+			text += " #" + strings.ToLower(strings.Join(entry.tags, " #"))
+		}
+		if strings.Contains(text, query) {
+			exact = append(exact, i)
+		} else if isSubsequence(query, text) {
+			fuzzy = append(fuzzy, i)
+		}
+	}
+	if len(exact)+len(fuzzy) == 0 {
+		fmt.Printf("No calculations match '%s'\n", query)
+		return
+	}
+	for _, i := range append(exact, fuzzy...) {
+		printHistoryEntry(i, c.entries[i])
+	}
+}
+
+// isSubsequence reports whether the characters of query appear in text in
+// the same order, ignoring spaces in query.
+func isSubsequence(query, text string) bool {
+	remaining := []rune(strings.ReplaceAll(query, " ", ""))
+	for _, char := range text {
+		if len(remaining) > 0 && char == remaining[0] {
+			remaining = remaining[1:]
+		}
+	}
+	return len(remaining) == 0
+}
+
+func historyCSV(entries []sessionEntry) ([]byte, error) {
+	var b bytes.Buffer
+	w := csv.NewWriter(&b)
+	w.Write([]string{"expression", "result", "value", "error", "timestamp", "tags"})
+	for _, entry := range entries {
+		record := newHistoryRecord(entry)
+		value := ""
+		if record.Value != nil {
+			value = strconv.FormatFloat(*record.Value, 'g', -1, 64)
+		}
+		w.Write([]string{record.Expression, record.Result, value, record.Error, record.Timestamp, strings.Join(record.Tags, " ")})
+	}
+	w.Flush()
+	return b.Bytes(), w.Error()
+}
+
+// historyJSON writes the history of a session as a JSON object holding the
+// version information of the calculator that produced it and the records.
+func historyJSON(entries []sessionEntry, info calc.BuildInfo) ([]byte, error) {
+	records := make([]historyRecord, len(entries))
+	for i, entry := range entries {
+		records[i] = newHistoryRecord(entry)
+	}
+	data, err := json.MarshalIndent(struct {
+		Calculator calc.BuildInfo  `json:"calculator"`
+		History    []historyRecord `json:"history"`
+	}{info, records}, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
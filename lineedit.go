@@ -0,0 +1,330 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is the error of a line abandoned with Ctrl+C.
+var errInterrupted = errors.New("interrupted")
+
+// Control keys of the line editor.
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = '\t'
+	keyCtrlK     = 11
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEnter     = '\r'
+	keyNewline   = '\n'
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// lineEditor reads the lines of the interactive calculator. On a terminal it
+// moves the cursor with the arrow keys, Home, End, Ctrl+A, and Ctrl+E, edits
+// anywhere in the line, steps through the lines entered before with the up
+// and down arrows, searches them with Ctrl+R, and completes names with Tab.
+// Elsewhere it reads plain lines.
+type lineEditor struct {
+	in  *bufio.Reader
+	out io.Writer
+	// history holds the lines entered before, oldest first.
+	history []string
+	// complete returns the completions of the word before the cursor; first
+	// is set when the word starts the line.
+	complete func(word string, first bool) []string
+	// preload is the text the next line starts with, ready to be edited.
+	preload string
+}
+
+// readLine prints the prompt and reads a line, without its line break.
+func (e *lineEditor) readLine(prompt string) (string, error) {
+	preload := e.preload
+	e.preload = ""
+	restore, err := makeRaw(os.Stdin.Fd())
+	if err != nil {
+		if preload != "" {
+			fmt.Fprintln(e.out, "Was:", preload)
+		}
+		fmt.Fprint(e.out, prompt)
+		line, err := e.in.ReadString('\n')
+		return strings.TrimRight(line, "\r\n"), err
+	}
+	defer restore()
+
+	line, err := e.edit(prompt, preload)
+	if entered := strings.TrimSpace(line); err == nil && entered != "" && (len(e.history) == 0 || e.history[len(e.history)-1] != entered) {
+		e.history = append(e.history, entered)
+		if len(e.history) > maxHistory {
+			e.history = e.history[len(e.history)-maxHistory:]
+		}
+	}
+	return line, err
+}
+
+// edit handles the keys typed at the prompt, after the initial text of the
+// line, until Enter, Ctrl+C, or Ctrl+D on an empty line.
+func (e *lineEditor) edit(prompt, initial string) (string, error) {
+	line := []rune(initial)
+	cursor := len(line)
+	index, draft := len(e.history), ""
+	recall := func(to int) {
+		if to < 0 || to > len(e.history) {
+			return
+		}
+		if index == len(e.history) {
+			draft = string(line)
+		}
+		index = to
+		if index == len(e.history) {
+			line = []rune(draft)
+		} else {
+			line = []rune(e.history[index])
+		}
+		cursor = len(line)
+	}
+
+	// pending is a key read by the search that ended it, handled next.
+	var pending rune
+	e.render(prompt, line, cursor)
+	for {
+		key := pending
+		pending = 0
+		if key == 0 {
+			var err error
+			if key, _, err = e.in.ReadRune(); err != nil {
+				return "", err
+			}
+		}
+		switch key {
+		case keyEnter, keyNewline:
+			fmt.Fprint(e.out, "\r\n")
+			return string(line), nil
+		case keyCtrlC:
+			fmt.Fprint(e.out, "^C\r\n")
+			return "", errInterrupted
+		case keyCtrlD:
+			if len(line) == 0 {
+				return "", io.EOF
+			}
+			if cursor < len(line) {
+				line = append(line[:cursor], line[cursor+1:]...)
+			}
+		case keyCtrlA:
+			cursor = 0
+		case keyCtrlE:
+			cursor = len(line)
+		case keyCtrlB:
+			if cursor > 0 {
+				cursor--
+			}
+		case keyCtrlF:
+			if cursor < len(line) {
+				cursor++
+			}
+		case keyCtrlP:
+			recall(index - 1)
+		case keyCtrlN:
+			recall(index + 1)
+		case keyCtrlR:
+			if index == len(e.history) {
+				draft = string(line)
+			}
+			var err error
+			if line, cursor, index, pending, err = e.search(line, cursor, index); err != nil {
+				return "", err
+			}
+		case keyBackspace, keyCtrlH:
+			if cursor > 0 {
+				line = append(line[:cursor-1], line[cursor:]...)
+				cursor--
+			}
+		case keyCtrlK:
+			line = line[:cursor]
+		case keyCtrlU:
+			line = line[cursor:]
+			cursor = 0
+		case keyCtrlW:
+			start := cursor
+			for start > 0 && line[start-1] == ' ' {
+				start--
+			}
+			for start > 0 && line[start-1] != ' ' {
+				start--
+			}
+			line = append(line[:start], line[cursor:]...)
+			cursor = start
+		case keyTab:
+			line, cursor = e.completeWord(line, cursor)
+		case keyEscape:
+			switch e.escapeSequence() {
+			case "[A", "OA":
+				recall(index - 1)
+			case "[B", "OB":
+				recall(index + 1)
+			case "[C", "OC":
+				if cursor < len(line) {
+					cursor++
+				}
+			case "[D", "OD":
+				if cursor > 0 {
+					cursor--
+				}
+			case "[H", "OH", "[1~", "[7~":
+				cursor = 0
+			case "[F", "OF", "[4~", "[8~":
+				cursor = len(line)
+			case "[3~":
+				if cursor < len(line) {
+					line = append(line[:cursor], line[cursor+1:]...)
+				}
+			}
+		default:
+			if unicode.IsPrint(key) {
+				line = append(line[:cursor], append([]rune{key}, line[cursor:]...)...)
+				cursor++
+			}
+		}
+		e.render(prompt, line, cursor)
+	}
+}
+
+// search finds the newest line entered before that contains the text typed
+// after Ctrl+R, and Ctrl+R again finds the next older one. Any other key ends
+// the search with the line found, at the history index it was found at, and
+// is returned for edit to handle, so Enter runs the line and the arrows edit
+// it. Ctrl+G and Ctrl+C return the line as it was before the search.
+func (e *lineEditor) search(line []rune, cursor, index int) ([]rune, int, int, rune, error) {
+	var query []rune
+	found := len(e.history)
+	// find moves to the newest line at or before from that contains the
+	// query, and rings the bell if there is none.
+	find := func(from int) {
+		for i := from; i >= 0; i-- {
+			if strings.Contains(e.history[i], string(query)) {
+				found = i
+				return
+			}
+		}
+		fmt.Fprint(e.out, "\a")
+	}
+	for {
+		var match []rune
+		at, prompt := 0, "(reverse-i-search)`"+string(query)+"': "
+		if found < len(e.history) {
+			match = []rune(e.history[found])
+			if i := strings.Index(e.history[found], string(query)); i >= 0 {
+				at = len([]rune(e.history[found][:i]))
+			} else {
+				prompt = "(failed " + prompt[1:]
+			}
+		}
+		e.render(prompt, match, at)
+		key, _, err := e.in.ReadRune()
+		if err != nil {
+			return nil, 0, 0, 0, err
+		}
+		switch {
+		case key == keyCtrlR:
+			find(found - 1)
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+				find(len(e.history) - 1)
+			}
+		case key == keyCtrlG || key == keyCtrlC:
+			return line, cursor, index, 0, nil
+		case unicode.IsPrint(key):
+			query = append(query, key)
+			if found == len(e.history) {
+				find(found - 1)
+			} else {
+				find(found)
+			}
+		default:
+			if found == len(e.history) {
+				return line, cursor, index, key, nil
+			}
+			return match, at, found, key, nil
+		}
+	}
+}
+
+// completeWord completes the word before the cursor. A single completion
+// replaces the word; several extend it as far as they agree and are listed
+// when they do not extend it at all.
+func (e *lineEditor) completeWord(line []rune, cursor int) ([]rune, int) {
+	start := cursor
+	for start > 0 && isWordRune(line[start-1]) {
+		start--
+	}
+	if start == cursor || e.complete == nil {
+		return line, cursor
+	}
+	word := string(line[start:cursor])
+	completions := e.complete(word, strings.TrimSpace(string(line[:start])) == "")
+	if len(completions) == 0 {
+		fmt.Fprint(e.out, "\a")
+		return line, cursor
+	}
+	completion := completions[0]
+	for _, other := range completions[1:] {
+		for !strings.HasPrefix(other, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if completion == word && len(completions) > 1 {
+		fmt.Fprint(e.out, "\r\n"+strings.Join(completions, "  ")+"\r\n")
+		return line, cursor
+	}
+	completed := append(append(append([]rune(nil), line[:start]...), []rune(completion)...), line[cursor:]...)
+	return completed, start + len([]rune(completion))
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+// escapeSequence reads the rest of an escape sequence such as the [A of the
+// up arrow.
+func (e *lineEditor) escapeSequence() string {
+	first, err := e.in.ReadByte()
+	if err != nil || first != '[' && first != 'O' {
+		return ""
+	}
+	sequence := []byte{first}
+	for {
+		b, err := e.in.ReadByte()
+		if err != nil {
+			return ""
+		}
+		sequence = append(sequence, b)
+		if b >= 0x40 && b <= 0x7e {
+			return string(sequence)
+		}
+	}
+}
+
+// render redraws the prompt and the line and puts the cursor in place.
+func (e *lineEditor) render(prompt string, line []rune, cursor int) {
+	fmt.Fprintf(e.out, "\r%s%s\x1b[K", prompt, string(line))
+	if back := len(line) - cursor; back > 0 {
+		fmt.Fprintf(e.out, "\x1b[%dD", back)
+	}
+}
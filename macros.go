@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// parameterRegex matches a macro parameter such as $price, which asks for a
+// value whenever the input is run.
+var parameterRegex = regexp.MustCompile(`\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// macro is a recorded sequence of inputs that 'play' runs again.
+type macro struct {
+	name   string
+	inputs []string
+}
+
+// handleMacroCommand starts recording a macro with 'record <name>', ends it
+// with 'stop', and replays it with 'play <name>'. 'play' alone lists the
+// macros.
+func (c *Calculator) handleMacroCommand(command string, args []string) bool {
+	switch {
+	case command == recordCommand && len(args) == 1:
+		if c.recording != nil {
+			fmt.Printf("Error: already recording '%s', type 'stop' to finish it first\n", c.recording.name)
+			return true
+		}
+		if !workspaceNameRegex.MatchString(args[0]) {
+			fmt.Println("Error: macro names start with a letter or underscore and contain only letters, digits, and underscores")
+			return true
+		}
+		c.recording = &macro{name: args[0]}
+		fmt.Printf("Recording macro '%s'; type 'stop' to finish.\n", args[0])
+		return true
+
+	case command == stopCommand && len(args) == 0:
+		if c.recording == nil {
+			fmt.Println("Error: no macro is being recorded, start one with 'record <name>'")
+			return true
+		}
+		recorded := c.recording
+		c.recording = nil
+		if len(recorded.inputs) == 0 {
+			fmt.Printf("Nothing recorded; macro '%s' was not saved.\n", recorded.name)
+			return true
+		}
+		c.macros[recorded.name] = recorded.inputs
+		fmt.Printf("Recorded macro '%s' with %s; type 'play %s' to run it.\n", recorded.name, pluralize(len(recorded.inputs), "input"), recorded.name)
+		return true
+
+	case command == playCommand && len(args) == 0:
+		c.printMacros()
+		return true
+
+	case command == playCommand && len(args) == 1:
+		c.playMacro(args[0])
+		return true
+	}
+	return false
+}
+
+// recordInput adds input to the macro being recorded, if any.
+func (c *Calculator) recordInput(input string) {
+	fields := strings.Fields(strings.ToLower(input))
+	if c.recording == nil || len(fields) == 0 || fields[0] == recordCommand || fields[0] == stopCommand {
+		return
+	}
+	c.recording.inputs = append(c.recording.inputs, input)
+}
+
+// playMacro runs the inputs of the macro name.
+func (c *Calculator) playMacro(name string) {
+	inputs, ok := c.macros[name]
+	if !ok {
+		fmt.Printf("Error: no macro named '%s', see 'play'\n", name)
+		return
+	}
+	if c.playing[name] {
+		fmt.Printf("Error: macro '%s' cannot play itself\n", name)
+		return
+	}
+	c.playing[name] = true
+	defer delete(c.playing, name)
+	for _, input := range inputs {
+		if !c.batch {
+			fmt.Println("Playing:", input)
+		}
+		c.execute(input)
+	}
+}
+
+// printMacros lists the recorded macros with their inputs.
+func (c *Calculator) printMacros() {
+	if len(c.macros) == 0 {
+		fmt.Println("No macros; record one with 'record <name>'")
+		return
+	}
+	names := make([]string, 0, len(c.macros))
+	for name := range c.macros {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("%s: %s\n", name, strings.Join(c.macros[name], "; "))
+	}
+}
+
+// fillParameters asks for the value of each parameter in input, such as
+// $price, and replaces the parameter with the value as typed, in parentheses
+// so that $a * 2 with 1+2 for a is 6.
+func (c *Calculator) fillParameters(input string) (string, error) {
+	values := map[string]string{}
+	for _, match := range parameterRegex.FindAllStringSubmatch(input, -1) {
+		name := match[1]
+		if _, ok := values[name]; ok {
+			continue
+		}
+		if !c.batch {
+			fmt.Printf("Enter %s: ", name)
+		}
+		line, _ := c.reader.ReadString('\n')
+		value := strings.TrimSpace(line)
+		if value == "" {
+			return "", fmt.Errorf("no value given for $%s", name)
+		}
+		values[name] = value
+	}
+	return parameterRegex.ReplaceAllStringFunc(input, func(parameter string) string {
+		return "(" + values[parameter[1:]] + ")"
+	}), nil
+}
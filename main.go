@@ -0,0 +1,928 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+	"github.com/XeinTDM/Go-Calculator/calc/quaternion"
+	"github.com/XeinTDM/Go-Calculator/calc/special"
+)
+
+const (
+	exitCommand       = "exit"
+	implicitCommand   = "implicit"
+	parensCommand     = "parens"
+	modeCommand       = "mode"
+	scaleCommand      = "scale"
+	holidaysCommand   = "holidays"
+	gradeScaleCommand = "gradescale"
+	exportCommand     = "export"
+	reportCommand     = "report"
+	tapeCommand       = "tape"
+	totalCommand      = "total"
+	subtotalCommand   = "subtotal"
+	clearCommand      = "clear"
+	workspaceCommand  = "workspace"
+	rawCommand        = "raw"
+	historyCommand    = "history"
+	pinCommand        = "pin"
+	unpinCommand      = "unpin"
+	pinsCommand       = "pins"
+	recordCommand     = "record"
+	stopCommand       = "stop"
+	playCommand       = "play"
+	loadCommand       = "load"
+	formatCommand     = "format"
+	baseCommand       = "base"
+	versionCommand    = "version"
+	provenanceCommand = "provenance"
+	whyCommand        = "why"
+	varsCommand       = "vars"
+	editCommand       = "edit"
+)
+
+var callRegex = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\(`)
+
+// extensionPacks are the packs of new kinds of values that 'load <name>' adds.
+var extensionPacks = map[string]func() calc.Extension{
+	"quaternion": quaternion.Extension,
+	"special":    special.Extension,
+}
+
+type Calculator struct {
+	reader *bufio.Reader
+	editor *lineEditor
+	*workspace
+	workspaceName string
+	workspaces    map[string]*workspace
+	engines       map[string]*calc.Calculator
+	macros        map[string][]string
+	// recording is the macro being recorded, if any.
+	recording *macro
+	// playing holds the names of the macros being played.
+	playing map[string]bool
+	// batch is set when input comes from a pipe or file rather than a
+	// terminal. Results are then printed at verbosity without prompts, errors
+	// go to stderr, and failure keeps the exit code of the first one.
+	batch     bool
+	verbosity int
+	failure   int
+	// failFast stops batch mode and -f scripts at the first error rather
+	// than going on and summing up the failed lines at the end.
+	failFast bool
+	// line and input are the number and text of the line of batch input or
+	// of the script being executed, and failures the lines that failed.
+	line     int
+	input    string
+	failures []batchFailure
+	// silent keeps batch mode from printing results, as for the lines of a
+	// -f script other than the last and those starting with print.
+	silent bool
+	// historyPath is the file the calculations of the main workspace are
+	// saved to, if the history is kept between runs.
+	historyPath string
+}
+
+func NewCalculator() *Calculator {
+	reader := bufio.NewReader(os.Stdin)
+	c := &Calculator{
+		reader:     reader,
+		editor:     &lineEditor{in: reader, out: os.Stdout},
+		workspaces: map[string]*workspace{},
+		engines:    map[string]*calc.Calculator{},
+		macros:     map[string][]string{},
+		playing:    map[string]bool{},
+	}
+	c.editor.complete = c.completions
+	c.addWorkspace(defaultWorkspace)
+	c.switchWorkspace(defaultWorkspace)
+	return c
+}
+
+// commands are the commands completed at the start of a line.
+var commands = []string{exitCommand, implicitCommand, parensCommand, modeCommand, scaleCommand, holidaysCommand, gradeScaleCommand, exportCommand, reportCommand, tapeCommand, totalCommand, subtotalCommand, clearCommand, workspaceCommand, rawCommand, historyCommand, pinCommand, unpinCommand, pinsCommand, recordCommand, stopCommand, playCommand, loadCommand, formatCommand, baseCommand, versionCommand, varsCommand, editCommand}
+
+// completions returns the completions of a word at the prompt: the names of
+// the current workspace and, at the start of the line, the commands.
+func (c *Calculator) completions(word string, first bool) []string {
+	completions := c.engine.Completions(word)
+	if !first {
+		return completions
+	}
+	for _, command := range commands {
+		if strings.HasPrefix(command, word) {
+			completions = append(completions, command)
+		}
+	}
+	sort.Strings(completions)
+	return completions
+}
+
+func (c *Calculator) Run() {
+	fmt.Println("Welcome to the Go Calculator!")
+	fmt.Println("Please enter your calculation with multiple operations allowed, including parentheses.")
+	fmt.Println("Operators: + for addition, - for subtraction, * for multiplication, / for division, ^ for exponentiation, % for percent (120 - 10% is 108) or, between two numbers, modulo, // for floor division; & | xor << >> and ~ work on the bits of 64-bit integers; == != < <= > >= compare, giving true or false, and and, or, and not combine conditions; if(x > 0, sqrt(x), 0) evaluates only the branch it gives")
+	fmt.Println("Functions: sin, cos, tan, asin, acos, atan, atan2(y, x), sinh, cosh, tanh, sqrt, cbrt, root(x, n) or n√x, hypot(x, y), exp, ln, log(x) or log(x, base), log2, log10, log_2(x) for any base, abs, floor, ceil, round, trunc, sign, gamma, max(a, b, ...), min(a, b, ...), sum, mean, median, mode, variance, stddev; n! is the factorial of n")
+	fmt.Println("Whole numbers: 'nCr(5, 2)', 'nPr(5, 2)', 'gcd(12, 18)', 'lcm(4, 6)', 'isprime(97)', 'factor(360)', 'fib(50)'.")
+	fmt.Println("Random numbers: 'rand()' from 0 to 1, 'rand(10, 20)', 'randint(1, 6)', 'randnorm(0, 1)'; 'seed(42)' makes them repeat.")
+	fmt.Println("Type 'mode deg' to work with angles in degrees, 'mode rad' for radians, 'mode grad' or 'mode turn' for gradians or turns; deg(x) and rad(x) convert between them. Angles such as 30deg, 45°, 0.5rad, 100grad, or 0.25turn work in any mode.")
+	fmt.Println("Type 'mode fraction' to enter mixed numbers like 1 1/2 and see fractional results, 'mode decimal' to switch back.")
+	fmt.Println("Feet and inches can be written as 5' 3 1/2\" and are shown to the nearest 1/16\".")
+	fmt.Println("World clock: 'now in Asia/Tokyo', '09:00 America/New_York in Europe/Berlin', '2025-03-08 12:00 America/New_York + 1d'; dates: 'today + 45 days', '2025-01-01 - 1999-06-15'.")
+	fmt.Println("Business days: 'workdays(2025-01-01, 2025-03-01)', 'adddays(today, 10, business)'; 'holidays use <calendar>' skips holidays.")
+	fmt.Println("Anniversaries: 'age(1990-04-12)', 'until(2025-12-25)'.")
+	fmt.Println("Grades: 'weightedavg([90, 85, 77], [2, 3, 1])', 'gpa(A, B+:4, A-:3)'; 'gradescale' shows or changes the scale.")
+	fmt.Println("Ratios: 'proportion(3, 4, x, 20)' solves 3/4 = x/20, 'solve(x^2 - 4 = 0, x)' solves an equation, 'integrate(sin(x), x, 0, pi)' gives a definite integral, 'plot(sin(x), x, -pi, pi)' draws a chart, 'table(x^2, x, 0, 10, 1)' lists values (add csv for comma-separated values), 'splitratio(100, 2, 3, 5)' splits a total, 'cfrac(pi, 5)' gives continued fraction terms, 'approx_frac(0.333333, 1e-6)' the simplest close fraction.")
+	fmt.Println("Shopping: 'unitprice(4.99, 750ml)', 'better(4.99/750ml, 6.49/1l)'.")
+	fmt.Println("Spec checks: 'intol(9.98, 10, 0.05)', 'within(10.3, 10, 2%)'.")
+	fmt.Println("Kitchen conversions: '2 cups flour in grams'; type 'scale recipe by 1.5' to scale a recipe.")
+	fmt.Println("Units: '5 km + 300 m', '60 mph in km/h', '2 h * 30 km/h'; units that measure different things cannot be added or converted.")
+	fmt.Println("Currencies: '100 USD in EUR'; start the calculator with -rates <url> for live exchange rates.")
+	fmt.Println("Implicit multiplication such as 2(3+4) shares the precedence of * and /; type 'implicit tight' to make it bind first.")
+	fmt.Println("Type 'parens on' to see how expressions such as a/b*c and -x^2 are grouped and what the other reading gives.")
+	fmt.Println("Type 'provenance on' to record where each variable's value came from, and 'why x' to show how x was derived.")
+	fmt.Println("Type 'vars' to list the variables with their values and the expressions that defined them, and 'edit x' to change the assignment of x at the prompt.")
+	fmt.Println("Variables: 'x = 3.5' stores a value that later calculations such as 'x * 2 + 1' can use; 'ans' or '_' is the previous result and 'ans(2)' the one before; 'q, r = divmod(17, 5)' assigns several values; 's = summary(2, 4, 6)' gives a record with fields such as 's.mean'; pi, e, tau, and phi are predefined.")
+	fmt.Println("Lists and matrices: 'xs = [1, 2, 3]' with 'xs * 2', 'xs[1]', 'len(xs)', 'sort(xs)', and 'dot(xs, ys)'; 'a = [[1, 2], [3, 4]]' with 'a * a', 'det(a)', 'inv(a)', 'transpose(a)', and 'identity(n)'.")
+	fmt.Println("Functions: 'f(x) = x^2 + 1' or 'g(a, b) = a*b - 2' defines a function to call later as 'f(3)' or 'g(2, 5)'; 'f^-1(10)' inverts f, 'sin^-1(0.5)' is asin(0.5).")
+	fmt.Println("Calculations can also be written in words, e.g. 'two plus three times four' or 'what is 15% of 240'.")
+	fmt.Println("Type 'total on' to keep a running total of your results; tag entries with #category, e.g. '12.50 #food'. 'subtotal' breaks the total down by category, 'clear' resets it.")
+	fmt.Println("Type 'workspace create <name>' or 'workspace switch <name>' to keep separate variables, settings, and history; 'name::x' reads x from another workspace.")
+	fmt.Println("Checks: 'assert(x > 0, \"negative result\")' stops the calculator with exit code 1 when the condition does not hold.")
+	fmt.Println("Type 'load <file>' to load a formula pack of function definitions; its functions run sandboxed with a limited number of steps.")
+	fmt.Println("Type 'load quaternion' for quaternions: quat(w, x, y, z), conj, norm, unit, inverse, axisangle(x, y, z, angle), and rotate(q, x, y, z).")
+	fmt.Println("Type 'load special' for special functions: lgamma, beta(a, b), erf, erfc, besselj0, and besselj1.")
+	fmt.Println("Type 'version' for the version, input grammar, loaded packs, and build of the calculator, to include in bug reports.")
+	fmt.Println("Type 'export <file>' to save the session settings, variables, and functions as a script that can be replayed with: calculator < <file>")
+	fmt.Println("Type 'raw' to show the last result with full precision; start the calculator with -round <places> to change how many decimal places results show.")
+	fmt.Println("Integers can be written as 0xFF, 0o17, or 0b1010 and floats as 0x1.8p3; 'hex(255)', 'oct(8)', and 'bin(5)' show a number in another base, 'hex(0.1)' and 'bin(0.1)' the bits of a float, and 'base hex|oct|bin|dec' shows results in that base.")
+	fmt.Println("Type 'format fixed|scientific|shortest', 'format <places>', 'format trim', or 'format group' to change how results are written, e.g. 'format shortest group' shows 1,234.5.")
+	fmt.Println("Type 'tape' to show the results as an adding machine tape with running totals, 'tape save <file>.txt|.pdf' to save it.")
+	fmt.Println("Type 'history' to list the calculations of this session, 'history search <text>' to find them, 'history export --format csv|json [<file>]' to export them with timestamps and tags.")
+	fmt.Println("Type '!<number>' to run a calculation from the history again, '!-2' for the one before the last, and '!!' or 'last' for the last one; start the calculator with -history to keep the history between runs.")
+	fmt.Println("Type 'pin <number>' to pin a calculation from the history, 'pins' to list the pinned ones, and '@<number>' to run one again.")
+	fmt.Println("Type 'record <name>' to record the following inputs as a macro, 'stop' to end it, and 'play <name>' to replay it; $name in an input asks for a value each time.")
+	fmt.Println("Type 'report <file>.md' or 'report <file>.html' to save the calculations of this session as a document.")
+	fmt.Println("Use the arrow keys, Home, End, Ctrl+A, and Ctrl+E to move along the line, and the up and down arrows to step through earlier lines; Ctrl+K and Ctrl+U delete to the end or start of the line.")
+	fmt.Println("Press Tab to complete the name of a function, constant, variable, or command.")
+	fmt.Println("Type 'exit' to quit the program.")
+
+	for {
+		prompt := "Enter calculation: "
+		if c.recording != nil {
+			prompt = fmt.Sprintf("[recording %s] ", c.recording.name) + prompt
+		}
+		if c.workspaceName != defaultWorkspace {
+			prompt = fmt.Sprintf("[%s] ", c.workspaceName) + prompt
+		}
+		input, err := c.editor.readLine(prompt)
+		if err == io.EOF && strings.TrimSpace(input) == "" {
+			fmt.Println()
+			break
+		}
+		if err == errInterrupted {
+			continue
+		}
+		if err != nil && err != io.EOF {
+			fmt.Println("Error reading input:", err)
+			continue
+		}
+
+		input = strings.TrimSpace(input)
+		if strings.ToLower(input) == exitCommand {
+			fmt.Println("Exiting the calculator. Goodbye!")
+			break
+		}
+		c.recordInput(input)
+		c.execute(input)
+	}
+}
+
+// execute carries out one line of input, either a command or a calculation.
+func (c *Calculator) execute(input string) {
+	if c.handleCommand(input) {
+		return
+	}
+
+	input, err := c.fillParameters(input)
+	if err != nil {
+		c.reportError(err, calc.Result{})
+		return
+	}
+	input, tags := splitTags(input)
+	if pinned, err := c.expandPin(input); err != nil {
+		c.reportError(err, calc.Result{})
+		return
+	} else if pinned != input {
+		if !c.batch {
+			fmt.Println("Pinned:", pinned)
+		}
+		input = pinned
+	}
+	if recalled, err := c.expandRecall(input); err != nil {
+		c.reportError(err, calc.Result{})
+		return
+	} else if recalled != input {
+		if !c.batch {
+			fmt.Println("Recalled:", recalled)
+		}
+		input = recalled
+	}
+	start := time.Now()
+	result, err := c.engine.EvaluateInput(input)
+	if input != "" {
+		c.addEntry(sessionEntry{input: input, tags: tags, result: result, err: err, timestamp: time.Now()})
+	}
+	if c.batch {
+		if err == nil && result.Numeric && math.IsNaN(result.Value) {
+			err = errNotReal
+		}
+		if err != nil {
+			c.reportError(err, result)
+			return
+		}
+		if c.silent {
+			return
+		}
+		printArgumentResult(result, c.verbosity, time.Since(start))
+		if c.total.enabled && result.Numeric {
+			c.total.add(result.Value, tags)
+			fmt.Println("Total:", c.engine.Format(c.total.sum))
+		}
+		return
+	}
+
+	if result.Interpretation != "" {
+		fmt.Println("Interpreted as:", result.Interpretation)
+	}
+	for _, warning := range result.Warnings {
+		fmt.Println("Warning:", warning)
+	}
+	var assertion *calc.AssertionError
+	if errors.As(err, &assertion) {
+		fmt.Println("Assertion failed:", assertion.Message)
+		os.Exit(exitAssertion)
+	}
+	if err != nil {
+		fmt.Println("Error:", err)
+		printErrorPosition(os.Stdout, err)
+		fmt.Println("Please check your input and try again.")
+		return
+	}
+
+	fmt.Println("Result:", result.Text)
+	if c.total.enabled && result.Numeric {
+		c.total.add(result.Value, tags)
+		fmt.Println("Total:", c.engine.Format(c.total.sum))
+	}
+}
+
+func (c *Calculator) handleCommand(input string) bool {
+	fields := strings.Fields(strings.ToLower(input))
+	if len(fields) == 0 {
+		return false
+	}
+
+	switch fields[0] {
+	case implicitCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "tight":
+				c.engine.ImplicitTight = true
+			case "loose":
+				c.engine.ImplicitTight = false
+			default:
+				fmt.Println("Error: use 'implicit tight' or 'implicit loose'")
+				return true
+			}
+		}
+		if c.engine.ImplicitTight {
+			fmt.Println("Implicit multiplication binds tighter than * and /: 1/2(3) = 1/(2*3)")
+		} else {
+			fmt.Println("Implicit multiplication shares the precedence of * and /: 1/2(3) = (1/2)*3")
+		}
+		return true
+
+	case parensCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "on":
+				c.engine.ExplainGrouping = true
+			case "off":
+				c.engine.ExplainGrouping = false
+			default:
+				fmt.Println("Error: use 'parens on' or 'parens off'")
+				return true
+			}
+		}
+		if c.engine.ExplainGrouping {
+			fmt.Println("Expressions that could be read another way, such as a/b*c and -x^2, show how they were grouped.")
+		} else {
+			fmt.Println("Expressions are not checked for other readings; type 'parens on' to see how a/b*c and -x^2 are grouped.")
+		}
+		return true
+
+	case provenanceCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "on":
+				c.engine.TrackProvenance = true
+			case "off":
+				c.engine.TrackProvenance = false
+			default:
+				fmt.Println("Error: use 'provenance on' or 'provenance off'")
+				return true
+			}
+		}
+		if c.engine.TrackProvenance {
+			fmt.Println("Assignments record the expression and variables each value came from; type 'why x' to see them.")
+		} else {
+			fmt.Println("Assignments are not recorded; type 'provenance on' to record where each value came from.")
+		}
+		return true
+
+	case whyCommand:
+		if len(fields) != 2 {
+			return false
+		}
+		explanation, err := c.engine.Why(strings.Fields(input)[1])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return true
+		}
+		fmt.Println(explanation)
+		return true
+
+	case varsCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		c.listVariables()
+		return true
+
+	case editCommand:
+		if len(fields) != 2 {
+			return false
+		}
+		c.editVariable(strings.Fields(input)[1])
+		return true
+
+	case scaleCommand:
+		if len(fields) < 4 || fields[1] != "recipe" || fields[2] != "by" {
+			return false
+		}
+		factor, err := c.engine.ParseQuantity(strings.Join(strings.Fields(input)[3:], " "))
+		if err != nil || factor <= 0 {
+			fmt.Println("Error: the scale factor must be a positive number")
+			return true
+		}
+		c.scaleRecipe(factor)
+		return true
+
+	case holidaysCommand:
+		c.handleHolidaysCommand(fields[1:])
+		return true
+
+	case gradeScaleCommand:
+		c.handleGradeScaleCommand(strings.Fields(input)[1:])
+		return true
+
+	case reportCommand:
+		if len(fields) != 2 {
+			return false
+		}
+		c.writeReport(strings.Fields(input)[1])
+		return true
+
+	case tapeCommand:
+		c.handleTapeCommand(strings.Fields(input)[1:])
+		return true
+
+	case totalCommand, subtotalCommand, clearCommand:
+		return c.handleTotalCommand(fields)
+
+	case historyCommand:
+		c.handleHistoryCommand(strings.Fields(input)[1:])
+		return true
+
+	case pinCommand, unpinCommand:
+		c.handlePinCommand(fields[0], fields[1:])
+		return true
+
+	case recordCommand, stopCommand, playCommand:
+		return c.handleMacroCommand(fields[0], strings.Fields(input)[1:])
+
+	case pinsCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		c.printPins()
+		return true
+
+	case rawCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		c.printRaw()
+		return true
+
+	case workspaceCommand:
+		c.handleWorkspaceCommand(strings.Fields(input)[1:])
+		return true
+
+	case exportCommand:
+		if len(fields) != 2 {
+			return false
+		}
+		c.exportSession(strings.Fields(input)[1])
+		return true
+
+	case formatCommand:
+		c.handleFormatCommand(fields[1:])
+		return true
+
+	case baseCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			base, ok := calc.ParseBase(fields[1])
+			if !ok {
+				fmt.Println("Error: use 'base hex', 'base oct', 'base bin', or 'base dec'")
+				return true
+			}
+			c.engine.Base = base
+		}
+		fmt.Printf("Base: %s (e.g. %s)\n", baseName(c.engine.Base), c.engine.FormatValue(calc.Float(255)))
+		return true
+
+	case versionCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		fmt.Println(c.engine.Info())
+		return true
+
+	case loadCommand:
+		if len(fields) != 2 {
+			return false
+		}
+		c.loadPack(strings.Fields(input)[1])
+		return true
+
+	case modeCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "fraction":
+				c.engine.FractionMode = true
+			case "decimal":
+				c.engine.FractionMode = false
+			case "deg", "degrees":
+				c.engine.AngleUnit = "deg"
+			case "rad", "radians":
+				c.engine.AngleUnit = "rad"
+			case "grad", "gradians":
+				c.engine.AngleUnit = "grad"
+			case "turn", "turns":
+				c.engine.AngleUnit = "turn"
+			default:
+				fmt.Println("Error: use 'mode fraction', 'mode decimal', 'mode deg', 'mode rad', 'mode grad', or 'mode turn'")
+				return true
+			}
+		}
+		number := "decimal"
+		if c.engine.FractionMode {
+			number = "fraction"
+		}
+		angle := map[string]string{"deg": "degrees", "grad": "gradians", "turn": "turns"}[c.engine.AngleUnit]
+		if angle == "" {
+			angle = "radians"
+		}
+		fmt.Printf("Mode: %s, %s\n", number, angle)
+		return true
+	}
+	return false
+}
+
+// handleFormatCommand changes how results are shown, as in 'format sci 3' or
+// 'format shortest group', and shows the format.
+func (c *Calculator) handleFormatCommand(args []string) {
+	notation, decimals, trim, group := c.engine.Notation, c.engine.Decimals, c.engine.TrimZeros, c.engine.Grouping
+	for _, arg := range args {
+		if n, ok := calc.ParseNotation(arg); ok {
+			notation = n
+			continue
+		}
+		switch arg {
+		case "trim":
+			trim = true
+		case "notrim":
+			trim = false
+		case "group":
+			group = true
+		case "nogroup":
+			group = false
+		default:
+			places, err := strconv.Atoi(arg)
+			if err != nil || places < 0 {
+				fmt.Println("Error: use 'format [fixed|scientific|shortest] [<decimal places>] [trim|notrim] [group|nogroup]'")
+				return
+			}
+			decimals = places
+		}
+	}
+	c.engine.Notation, c.engine.Decimals, c.engine.TrimZeros, c.engine.Grouping = notation, decimals, trim, group
+
+	description := []string{notation.String()}
+	if notation != calc.ShortestNotation {
+		description = append(description, pluralize(decimals, "decimal place"))
+	}
+	if trim {
+		description = append(description, "trailing zeros trimmed")
+	}
+	if group {
+		description = append(description, "thousands grouped")
+	}
+	fmt.Printf("Format: %s (e.g. %s)\n", strings.Join(description, ", "), c.engine.Format(1234.5))
+}
+
+// baseName returns the name of the base whole-number results are shown in.
+func baseName(base int) string {
+	switch base {
+	case 16:
+		return "hex"
+	case 8:
+		return "oct"
+	case 2:
+		return "bin"
+	}
+	return "dec"
+}
+
+// formatScript returns the format command that restores the result format,
+// if it differs from the default.
+func (c *Calculator) formatScript() []string {
+	defaults := calc.New()
+	var args []string
+	if c.engine.Notation != defaults.Notation {
+		args = append(args, c.engine.Notation.String())
+	}
+	if c.engine.Decimals != defaults.Decimals {
+		args = append(args, strconv.Itoa(c.engine.Decimals))
+	}
+	if c.engine.TrimZeros {
+		args = append(args, "trim")
+	}
+	if c.engine.Grouping {
+		args = append(args, "group")
+	}
+	if len(args) == 0 {
+		return nil
+	}
+	return []string{formatCommand + " " + strings.Join(args, " ")}
+}
+
+// printRaw prints the last numeric result with full precision rather than
+// rounded for display.
+func (c *Calculator) printRaw() {
+	for i := len(c.entries) - 1; i >= 0; i-- {
+		if entry := c.entries[i]; entry.err == nil && entry.result.Numeric {
+			fmt.Println("Raw result:", strconv.FormatFloat(entry.result.Value, 'g', -1, 64))
+			return
+		}
+	}
+	fmt.Println("Error: there is no result yet")
+}
+
+// scaleRecipe reads recipe lines until a blank line and prints each one with
+// its leading quantity multiplied by factor.
+// listVariables prints the variables of the current workspace with their
+// values and, where it differs from the value, the expression that defined
+// each one.
+func (c *Calculator) listVariables() {
+	if len(c.engine.Variables) == 0 {
+		fmt.Println("No variables yet; assign one with x = 3.5")
+		return
+	}
+	names := make([]string, 0, len(c.engine.Variables))
+	for name := range c.engine.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		line := name + " = " + c.engine.FormatValue(c.engine.Variables[name])
+		// A number typed in says nothing that the value does not.
+		if expression, ok := c.engine.Definition(name); ok {
+			if _, err := strconv.ParseFloat(expression, 64); err != nil {
+				line += " from " + expression
+			}
+		}
+		fmt.Println(line)
+	}
+}
+
+// editVariable starts the next line with the assignment that defined the
+// variable name, to be changed and entered again.
+func (c *Calculator) editVariable(name string) {
+	value, ok := c.engine.Variables[name]
+	if !ok {
+		fmt.Println("Error: undefined variable:", name)
+		return
+	}
+	expression, ok := c.engine.Definition(name)
+	if !ok {
+		expression = c.engine.FormatValue(value)
+	}
+	c.editor.preload = name + " = " + expression
+}
+
+func (c *Calculator) scaleRecipe(factor float64) {
+	fmt.Println("Enter the recipe one ingredient per line, followed by an empty line:")
+	var lines []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if line == "" || err != nil {
+			if line != "" {
+				lines = append(lines, line)
+			}
+			break
+		}
+		lines = append(lines, line)
+	}
+
+	for _, line := range lines {
+		fmt.Println(c.engine.ScaleRecipeLine(line, factor))
+	}
+}
+
+func (c *Calculator) handleGradeScaleCommand(args []string) {
+	switch len(args) {
+	case 0:
+	case 1:
+		scale, ok := calc.GradeScale(args[0])
+		if !ok {
+			fmt.Println("Error: unknown grade scale, use 4.0 or 4.3")
+			return
+		}
+		c.engine.GradeScale = scale
+	case 2:
+		points, err := strconv.ParseFloat(args[1], 64)
+		if err != nil || points < 0 {
+			fmt.Println("Error: grade points must be a non-negative number")
+			return
+		}
+		c.engine.GradeScale[strings.ToUpper(args[0])] = points
+	default:
+		fmt.Println("Error: use 'gradescale', 'gradescale 4.0|4.3', or 'gradescale <grade> <points>'")
+		return
+	}
+
+	scale := c.engine.GradeScale
+	grades := make([]string, 0, len(scale))
+	for grade := range scale {
+		grades = append(grades, grade)
+	}
+	sort.Slice(grades, func(i, j int) bool {
+		if scale[grades[i]] != scale[grades[j]] {
+			return scale[grades[i]] > scale[grades[j]]
+		}
+		return grades[i] < grades[j]
+	})
+	var entries []string
+	for _, grade := range grades {
+		entries = append(entries, fmt.Sprintf("%s=%.1f", grade, scale[grade]))
+	}
+	fmt.Println("Grade scale:", strings.Join(entries, " "))
+}
+
+// exportSession writes the commands that reproduce the current session to
+// path, so it can be replayed later with: calculator < path
+func (c *Calculator) exportSession(path string) {
+	lines := c.sessionScript()
+	content := strings.Join(lines, "\n")
+	if len(lines) > 0 {
+		content += "\n"
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Exported %s to %s\n", pluralize(len(lines), "line"), path)
+}
+
+// sessionScript returns the minimal list of commands that bring a fresh
+// calculator to the current settings, variables, and functions. Settings left
+// at their defaults are omitted.
+func (c *Calculator) sessionScript() []string {
+	var lines []string
+	if c.engine.ImplicitTight {
+		lines = append(lines, implicitCommand+" tight")
+	}
+	if c.engine.ExplainGrouping {
+		lines = append(lines, parensCommand+" on")
+	}
+	if c.engine.FractionMode {
+		lines = append(lines, modeCommand+" fraction")
+	}
+	if unit := c.engine.AngleUnit; unit != "" && unit != "rad" {
+		lines = append(lines, modeCommand+" "+unit)
+	}
+	lines = append(lines, c.formatScript()...)
+	if baseName(c.engine.Base) != "dec" {
+		lines = append(lines, baseCommand+" "+baseName(c.engine.Base))
+	}
+	if c.engine.Holidays != nil {
+		lines = append(lines, holidaysCommand+" use "+c.engine.Holidays.Name())
+	}
+	lines = append(lines, c.gradeScaleScript()...)
+
+	lines = append(lines, c.engine.SessionAssignments()...)
+	for _, name := range c.functionOrder() {
+		for _, function := range c.engine.Functions[name] {
+			lines = append(lines, function.Definition(name))
+		}
+	}
+	return lines
+}
+
+// functionOrder sorts the user functions so that every function comes after
+// the functions its body calls, as a replayed definition needs them to exist.
+func (c *Calculator) functionOrder() []string {
+	var pending []string
+	for name := range c.engine.Functions {
+		pending = append(pending, name)
+	}
+	sort.Strings(pending)
+
+	var order []string
+	defined := map[string]bool{}
+	for len(pending) > 0 {
+		var rest []string
+		for _, name := range pending {
+			ready := true
+			for _, function := range c.engine.Functions[name] {
+				for _, call := range callRegex.FindAllStringSubmatch(function.String(), -1) {
+					if _, ok := c.engine.Functions[call[1]]; ok && call[1] != name && !defined[call[1]] {
+						ready = false
+					}
+				}
+			}
+			if ready {
+				order = append(order, name)
+				defined[name] = true
+			} else {
+				rest = append(rest, name)
+			}
+		}
+		if len(rest) == len(pending) {
+			return append(order, rest...)
+		}
+		pending = rest
+	}
+	return order
+}
+
+// gradeScaleScript describes the current grade scale as the built-in scale it
+// differs least from, followed by the grades that were changed or added.
+func (c *Calculator) gradeScaleScript() []string {
+	var best []string
+	for i, name := range []string{"4.0", "4.3"} {
+		base, _ := calc.GradeScale(name)
+		var lines []string
+		if i > 0 {
+			lines = append(lines, gradeScaleCommand+" "+name)
+		}
+		var grades []string
+		for grade := range c.engine.GradeScale {
+			grades = append(grades, grade)
+		}
+		sort.Strings(grades)
+		for _, grade := range grades {
+			if points, ok := base[grade]; !ok || points != c.engine.GradeScale[grade] {
+				lines = append(lines, fmt.Sprintf("%s %s %s", gradeScaleCommand, grade, strconv.FormatFloat(c.engine.GradeScale[grade], 'f', -1, 64)))
+			}
+		}
+		if i == 0 || len(lines) < len(best) {
+			best = lines
+		}
+	}
+	return best
+}
+
+func pluralize(count int, noun string) string {
+	if count == 1 {
+		return "1 " + noun
+	}
+	return fmt.Sprintf("%d %ss", count, noun)
+}
+
+// holidayDirectory is where holiday calendars live: one <name>.txt file per
+// calendar, each line holding a YYYY-MM-DD date and an optional description.
+func holidayDirectory() (string, error) {
+	if dir := os.Getenv("GOCALC_HOLIDAYS"); dir != "" {
+		return dir, nil
+	}
+	config, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(config, "gocalc", "holidays"), nil
+}
+
+// loadPack loads the functions of a formula pack and reports the lines that
+// were refused.
+func (c *Calculator) loadPack(path string) {
+	if extension, ok := extensionPacks[strings.ToLower(path)]; ok {
+		if err := c.engine.Register(extension()); err != nil {
+			fmt.Printf("Error: the %s pack is already loaded\n", extension().Name)
+			return
+		}
+		fmt.Printf("Loaded the %s pack\n", extension().Name)
+		return
+	}
+	loaded, violations, err := c.engine.LoadPack(path)
+	if err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	for _, definition := range loaded {
+		fmt.Println("Loaded:", definition)
+	}
+	for _, violation := range violations {
+		fmt.Printf("Refused %s: %s\n", path, violation)
+	}
+	fmt.Printf("Loaded %s from %s", pluralize(len(loaded), "function"), path)
+	if len(violations) > 0 {
+		fmt.Printf(", refused %s", pluralize(len(violations), "line"))
+	}
+	fmt.Println()
+}
+
+func (c *Calculator) handleHolidaysCommand(args []string) {
+	dir, dirErr := holidayDirectory()
+	switch {
+	case len(args) == 2 && args[0] == "use":
+		if dirErr != nil {
+			fmt.Println("Error:", dirErr)
+			return
+		}
+		calendar, err := calc.LoadHolidayCalendar(dir, args[1])
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		c.engine.Holidays = calendar
+	case len(args) == 1 && args[0] == "off":
+		c.engine.Holidays = nil
+	case len(args) != 0:
+		fmt.Println("Error: use 'holidays', 'holidays use <calendar>', or 'holidays off'")
+		return
+	}
+
+	if c.engine.Holidays == nil {
+		fmt.Println("Holidays: none (only weekends are skipped)")
+	} else {
+		fmt.Println("Holidays:", c.engine.Holidays.Name())
+	}
+	if dirErr != nil {
+		return
+	}
+	files, _ := filepath.Glob(filepath.Join(dir, "*.txt"))
+	var names []string
+	for _, file := range files {
+		names = append(names, strings.TrimSuffix(filepath.Base(file), ".txt"))
+	}
+	if len(names) > 0 {
+		fmt.Println("Available calendars:", strings.Join(names, ", "))
+	}
+}
+
+func main() {
+	calculator := NewCalculator()
+	calculator.runCommandLine()
+	calculator.Run()
+}
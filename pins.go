@@ -0,0 +1,68 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// pinRegex matches a reference to a pinned expression, such as @2.
+var pinRegex = regexp.MustCompile(`^@(\d+)$`)
+
+// handlePinCommand pins the expression of a history entry, as in 'pin 3', or
+// removes a pin with 'unpin 1'.
+func (c *Calculator) handlePinCommand(command string, args []string) {
+	if len(args) != 1 {
+		fmt.Printf("Error: use '%s <number>'\n", command)
+		return
+	}
+	n, err := strconv.Atoi(args[0])
+	if command == unpinCommand {
+		if err != nil || n < 1 || n > len(c.pins) {
+			fmt.Printf("Error: there is no pin %s, see 'pins'\n", args[0])
+			return
+		}
+		c.pins = append(c.pins[:n-1], c.pins[n:]...)
+		c.printPins()
+		return
+	}
+
+	if err != nil || n < 1 || n > len(c.entries) {
+		fmt.Printf("Error: there is no calculation %s in the history, see 'history'\n", args[0])
+		return
+	}
+	expression := c.entries[n-1].input
+	for i, pin := range c.pins {
+		if pin == expression {
+			fmt.Printf("Already pinned as @%d: %s\n", i+1, expression)
+			return
+		}
+	}
+	c.pins = append(c.pins, expression)
+	fmt.Printf("Pinned as @%d: %s\n", len(c.pins), expression)
+}
+
+// printPins lists the pinned expressions with the references that recall them.
+func (c *Calculator) printPins() {
+	if len(c.pins) == 0 {
+		fmt.Println("No pinned expressions; pin one from the history with 'pin <number>'")
+		return
+	}
+	for i, pin := range c.pins {
+		fmt.Printf("@%d  %s\n", i+1, pin)
+	}
+}
+
+// expandPin replaces input that refers to a pinned expression, such as @2,
+// with the expression.
+func (c *Calculator) expandPin(input string) (string, error) {
+	match := pinRegex.FindStringSubmatch(input)
+	if match == nil {
+		return input, nil
+	}
+	n, _ := strconv.Atoi(match[1])
+	if n < 1 || n > len(c.pins) {
+		return "", fmt.Errorf("there is no pin @%s, see 'pins'", match[1])
+	}
+	return c.pins[n-1], nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// sessionEntry is one calculation entered during the session, kept for reports
+// and the history.
+type sessionEntry struct {
+	input     string
+	tags      []string
+	result    calc.Result
+	err       error
+	timestamp time.Time
+	// restored is set for a calculation of a previous run read from the
+	// history file, which has an input but no result.
+	restored bool
+}
+
+// notes lists the tags, interpretation, warnings, and error of the entry.
+func (e sessionEntry) notes() []string {
+	var notes []string
+	if e.restored {
+		notes = append(notes, "From a previous session")
+	}
+	if len(e.tags) > 0 {
+		notes = append(notes, "Tags: #"+strings.Join(e.tags, " #"))
+	}
+	if e.result.Interpretation != "" {
+		notes = append(notes, "Interpreted as: "+e.result.Interpretation)
+	}
+	for _, warning := range e.result.Warnings {
+		notes = append(notes, "Warning: "+warning)
+	}
+	if e.err != nil {
+		notes = append(notes, "Error: "+e.err.Error())
+	}
+	return notes
+}
+
+// writeReport writes the calculations of the session to path as an HTML
+// document if path ends in .html or .htm, and as Markdown otherwise.
+func (c *Calculator) writeReport(path string) {
+	var report string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".html", ".htm":
+		report = htmlReport(c.entries, time.Now())
+	default:
+		report = markdownReport(c.entries, time.Now())
+	}
+	if err := os.WriteFile(path, []byte(report), 0644); err != nil {
+		fmt.Println("Error:", err)
+		return
+	}
+	fmt.Printf("Wrote a report of %s to %s\n", pluralize(len(c.entries), "calculation"), path)
+}
+
+func markdownReport(entries []sessionEntry, generated time.Time) string {
+	var b strings.Builder
+	b.WriteString("# Calculator Session\n\n")
+	fmt.Fprintf(&b, "Generated %s.\n\n", generated.Format("2006-01-02 15:04"))
+	if len(entries) == 0 {
+		b.WriteString("No calculations were entered.\n")
+		return b.String()
+	}
+
+	b.WriteString("| # | Calculation | Result | Notes |\n")
+	b.WriteString("|---|---|---|---|\n")
+	escape := strings.NewReplacer("|", "\\|", "`", "'")
+	// Results of several lines, such as plots, tables, and matrices, cannot
+	// be written in a cell; they follow the table in fenced blocks.
+	var blocks []string
+	for i, entry := range entries {
+		result := ""
+		if entry.err == nil && strings.Contains(entry.result.Text, "\n") {
+			result = fmt.Sprintf("see [result %d](#result-%d)", i+1, i+1)
+			blocks = append(blocks, fmt.Sprintf("### Result %d\n\n```\n%s\n```\n", i+1, strings.TrimRight(entry.result.Text, "\n")))
+		} else if entry.err == nil {
+			result = escape.Replace(entry.result.Text)
+		}
+		var notes []string
+		for _, note := range entry.notes() {
+			notes = append(notes, escape.Replace(note))
+		}
+		fmt.Fprintf(&b, "| %d | `%s` | %s | %s |\n", i+1, escape.Replace(entry.input), result, strings.Join(notes, "<br>"))
+	}
+	for _, block := range blocks {
+		b.WriteString("\n" + block)
+	}
+	return b.String()
+}
+
+func htmlReport(entries []sessionEntry, generated time.Time) string {
+	var b strings.Builder
+	b.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Calculator Session</title>\n")
+	b.WriteString("<style>body{font-family:sans-serif}table{border-collapse:collapse}th,td{border:1px solid #ccc;padding:4px 8px;text-align:left}code{font-size:1.1em}pre{margin:0}.error{color:#b00}</style>\n")
+	b.WriteString("</head>\n<body>\n<h1>Calculator Session</h1>\n")
+	fmt.Fprintf(&b, "<p>Generated %s.</p>\n", generated.Format("2006-01-02 15:04"))
+	if len(entries) == 0 {
+		b.WriteString("<p>No calculations were entered.</p>\n</body>\n</html>\n")
+		return b.String()
+	}
+
+	b.WriteString("<table>\n<tr><th>#</th><th>Calculation</th><th>Result</th><th>Notes</th></tr>\n")
+	for i, entry := range entries {
+		result := ""
+		if entry.err == nil && strings.Contains(entry.result.Text, "\n") {
+			result = "<pre>" + html.EscapeString(strings.TrimRight(entry.result.Text, "\n")) + "</pre>"
+		} else if entry.err == nil {
+			result = html.EscapeString(entry.result.Text)
+		}
+		var notes []string
+		for _, note := range entry.notes() {
+			notes = append(notes, html.EscapeString(note))
+		}
+		class := ""
+		if entry.err != nil {
+			class = " class=\"error\""
+		}
+		fmt.Fprintf(&b, "<tr%s><td>%d</td><td><code>%s</code></td><td>%s</td><td>%s</td></tr>\n", class, i+1, html.EscapeString(entry.input), result, strings.Join(notes, "<br>"))
+	}
+	b.WriteString("</table>\n</body>\n</html>\n")
+	return b.String()
+}
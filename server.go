@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// maxRequestBytes bounds the size of the body of an /evaluate request.
+const maxRequestBytes = 1 << 20
+
+// The timeouts of the connections of the server, apart from that of each
+// calculation: reading the headers and the body of a request, writing the
+// answer, and keeping an idle connection open.
+const (
+	readHeaderTimeout = 5 * time.Second
+	readTimeout       = 30 * time.Second
+	writeTimeout      = 30 * time.Second
+	idleTimeout       = 2 * time.Minute
+)
+
+// variableNameRegex matches the names the variables of a request may have.
+var variableNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// errorKinds name the kinds of errors of a calculation by their exit code in
+// command line mode.
+var errorKinds = map[int]string{exitAssertion: "assertion", exitParseError: "parse", exitEvaluationError: "evaluation", exitDomainError: "domain"}
+
+// evaluateRequest is the body of a POST to /evaluate.
+type evaluateRequest struct {
+	Expression string             `json:"expression"`
+	Variables  map[string]float64 `json:"variables"`
+}
+
+// evaluateResponse answers a POST to /evaluate with the result of the
+// expression or the error that stopped it.
+type evaluateResponse struct {
+	Result   string       `json:"result,omitempty"`
+	Kind     string       `json:"kind,omitempty"`
+	Value    *float64     `json:"value,omitempty"`
+	Warnings []string     `json:"warnings,omitempty"`
+	Error    *serverError `json:"error,omitempty"`
+}
+
+// serverError is the error of a request: its kind, such as parse or domain,
+// its message, and for a syntax error the column of the input at fault.
+type serverError struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	Column  int    `json:"column,omitempty"`
+}
+
+// serve answers the requests of handler at addr, such as :8080, until the
+// server fails, and returns the exit code.
+func (c *Calculator) serve(addr string, timeout time.Duration) int {
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           c.handler(timeout, newShareStore()),
+		ReadHeaderTimeout: readHeaderTimeout,
+		ReadTimeout:       readTimeout,
+		WriteTimeout:      timeout + writeTimeout,
+		IdleTimeout:       idleTimeout,
+	}
+	fmt.Fprintf(os.Stderr, "Serving the calculator on %s\n", addr)
+	if err := server.ListenAndServe(); err != nil {
+		fmt.Fprintln(os.Stderr, "Error:", err)
+		return exitEvaluationError
+	}
+	return exitSuccess
+}
+
+// handler answers POST /evaluate requests, each calculated apart from the
+// others with the settings and -D variables of the engine, and POST /share
+// requests, which keep a calculation in shares for GET /s/{id} to open in
+// the web page served at /.
+func (c *Calculator) handler(timeout time.Duration, shares *shareStore) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/evaluate", func(w http.ResponseWriter, r *http.Request) {
+		status, response := c.handleEvaluate(r, timeout)
+		writeJSON(w, status, response)
+	})
+	mux.HandleFunc("/share", func(w http.ResponseWriter, r *http.Request) {
+		status, response := handleShare(r, shares)
+		writeJSON(w, status, response)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		servePage(w, r, shares)
+	})
+	return mux
+}
+
+// writeJSON writes the answer to a POST request with status and body. The
+// body is encoded before the status is sent, so that a body that cannot be
+// encoded is answered with an internal error instead of an empty answer.
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusMethodNotAllowed {
+		w.Header().Set("Allow", http.MethodPost)
+	}
+	var buffer bytes.Buffer
+	if err := json.NewEncoder(&buffer).Encode(body); err != nil {
+		status = http.StatusInternalServerError
+		buffer.Reset()
+		json.NewEncoder(&buffer).Encode(evaluateResponse{Error: &serverError{Kind: "internal", Message: fmt.Sprintf("the answer could not be encoded: %s", err)}})
+	}
+	w.WriteHeader(status)
+	w.Write(buffer.Bytes())
+}
+
+// handleEvaluate evaluates the expression of an /evaluate request after
+// setting its variables, and returns the HTTP status and body of the answer.
+func (c *Calculator) handleEvaluate(r *http.Request, timeout time.Duration) (int, evaluateResponse) {
+	failure := func(status int, kind, message string) (int, evaluateResponse) {
+		return status, evaluateResponse{Error: &serverError{Kind: kind, Message: message}}
+	}
+	if r.Method != http.MethodPost {
+		return failure(http.StatusMethodNotAllowed, "request", "use POST with a JSON body such as {\"expression\": \"2 + 3\"}")
+	}
+	var request evaluateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return failure(http.StatusBadRequest, "request", fmt.Sprintf("the body must be a JSON object with an expression and optional numeric variables: %s", err))
+	}
+	if strings.TrimSpace(request.Expression) == "" {
+		return failure(http.StatusBadRequest, "request", "the expression is empty")
+	}
+
+	engine := calc.New()
+	copySettings(engine, c.engine)
+	for name, value := range c.engine.Variables {
+		engine.Variables[name] = value
+	}
+	names := make([]string, 0, len(request.Variables))
+	for name := range request.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if !variableNameRegex.MatchString(name) {
+			return failure(http.StatusBadRequest, "variable", fmt.Sprintf("'%s' is not a variable name", name))
+		}
+		if _, err := engine.EvaluateInput(name + " = " + strconv.FormatFloat(request.Variables[name], 'g', -1, 64)); err != nil {
+			return failure(http.StatusBadRequest, "variable", fmt.Sprintf("%s: %s", name, err))
+		}
+	}
+
+	// The engine gives up at its deadline, so that a calculation that takes
+	// too long does not go on after its answer.
+	engine.Deadline = time.Now().Add(timeout)
+	type outcome struct {
+		result calc.Result
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := engine.EvaluateInput(request.Expression)
+		if err == nil && result.Numeric && math.IsNaN(result.Value) {
+			err = errNotReal
+		}
+		done <- outcome{result, err}
+	}()
+	var o outcome
+	finished := true
+	select {
+	case o = <-done:
+	case <-time.After(timeout):
+		finished = false
+	}
+	if !finished || errors.Is(o.err, calc.ErrDeadline) {
+		return failure(http.StatusServiceUnavailable, "timeout", fmt.Sprintf("the calculation did not finish within %s", timeout))
+	}
+	if o.err != nil {
+		status, response := failure(http.StatusUnprocessableEntity, errorKinds[exitCode(o.err, o.result)], o.err.Error())
+		var syntax *calc.SyntaxError
+		if errors.As(o.err, &syntax) {
+			status = http.StatusBadRequest
+			if syntax.Input != "" {
+				response.Error.Column = syntax.Column()
+			}
+		}
+		return status, response
+	}
+	response := evaluateResponse{Result: o.result.Text, Warnings: o.result.Warnings}
+	if o.result.Typed != nil {
+		response.Kind = o.result.Typed.Kind()
+	}
+	// JSON has no infinities, so a result too large for a float64 is only
+	// given as text.
+	if o.result.Numeric && !math.IsInf(o.result.Value, 0) && !math.IsNaN(o.result.Value) {
+		response.Value = &o.result.Value
+	}
+	return http.StatusOK, response
+}
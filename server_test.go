@@ -0,0 +1,97 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// TestConcurrentEvaluate checks that /evaluate requests answered at the same
+// time from one engine keep their variables apart. Run it with -race.
+func TestConcurrentEvaluate(t *testing.T) {
+	c := NewCalculator()
+	if _, err := c.engine.EvaluateInput("k = 10"); err != nil {
+		t.Fatal(err)
+	}
+	const requests = 200
+	var wg sync.WaitGroup
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			body := fmt.Sprintf(`{"expression": "x * k + 1/3", "variables": {"x": %d}}`, i)
+			status, response := c.handleEvaluate(httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(body)), time.Minute)
+			if status != http.StatusOK || response.Value == nil {
+				t.Errorf("x = %d: status %d, response %+v", i, status, response)
+				return
+			}
+			if want := float64(i)*10 + 1.0/3; *response.Value != want {
+				t.Errorf("x = %d: got %v, want %v", i, *response.Value, want)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+// TestEvaluateTimeout checks that a calculation that does not finish in time
+// is answered as a timeout.
+func TestEvaluateTimeout(t *testing.T) {
+	c := NewCalculator()
+	body := `{"expression": "integrate(sin(x), x, 0, pi)"}`
+	status, response := c.handleEvaluate(httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(body)), time.Nanosecond)
+	if status != http.StatusServiceUnavailable || response.Error == nil || response.Error.Kind != "timeout" {
+		t.Errorf("status %d, response %+v, want a timeout", status, response)
+	}
+}
+
+// TestLargeResult checks that a result too large for a float64 is answered
+// with its text and no value, and that an answer that cannot be encoded as
+// JSON is an internal error rather than an empty answer.
+func TestLargeResult(t *testing.T) {
+	handler := NewCalculator().handler(time.Minute, newShareStore())
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/evaluate", strings.NewReader(`{"expression": "2^65536"}`)))
+	var response evaluateResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || recorder.Code != http.StatusOK || response.Value != nil || response.Result == "" {
+		t.Errorf("status %d, response %+v, error %v", recorder.Code, response, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	infinity := math.Inf(1)
+	writeJSON(recorder, http.StatusOK, evaluateResponse{Value: &infinity})
+	response = evaluateResponse{}
+	if err := json.NewDecoder(recorder.Body).Decode(&response); err != nil || recorder.Code != http.StatusInternalServerError || response.Error == nil {
+		t.Errorf("status %d, response %+v, error %v", recorder.Code, response, err)
+	}
+}
+
+// TestShare checks that a calculation shared with POST /share opens at its
+// /s/{id} page, and that an unknown ID is not found.
+func TestShare(t *testing.T) {
+	handler := NewCalculator().handler(time.Minute, newShareStore())
+	body := `{"expression": "r^2 * pi", "variables": {"r": 2}}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/share", strings.NewReader(body)))
+	var shared shareResponse
+	if err := json.NewDecoder(recorder.Body).Decode(&shared); err != nil || recorder.Code != http.StatusCreated || len(shared.ID) != shareIDLength {
+		t.Fatalf("status %d, response %+v, error %v", recorder.Code, shared, err)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, shared.URL, nil))
+	if page := recorder.Body.String(); recorder.Code != http.StatusOK || !strings.Contains(page, `"expression":"r^2 * pi"`) || !strings.Contains(page, `"r":2`) {
+		t.Errorf("status %d, page %s", recorder.Code, page)
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, sharePath+"unknown", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Errorf("unknown share: status %d, want %d", recorder.Code, http.StatusNotFound)
+	}
+}
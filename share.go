@@ -0,0 +1,206 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+const (
+	// shareIDLength is the number of characters of the ID of a shared
+	// calculation, drawn from shareIDAlphabet.
+	shareIDLength   = 8
+	shareIDAlphabet = "abcdefghijkmnopqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	// maxShares bounds the number of calculations the server keeps shared.
+	maxShares = 10000
+)
+
+// shareStore holds the calculations shared with POST /share by their IDs,
+// for as long as the server runs.
+type shareStore struct {
+	mu     sync.Mutex
+	shares map[string]evaluateRequest
+}
+
+func newShareStore() *shareStore {
+	return &shareStore{shares: map[string]evaluateRequest{}}
+}
+
+// add stores a calculation under a new ID, which it returns.
+func (s *shareStore) add(share evaluateRequest) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.shares) >= maxShares {
+		return "", fmt.Errorf("the server holds as many shared calculations as it can")
+	}
+	for {
+		id, err := newShareID()
+		if err != nil {
+			return "", err
+		}
+		if _, taken := s.shares[id]; !taken {
+			s.shares[id] = share
+			return id, nil
+		}
+	}
+}
+
+// get returns the calculation shared under id, if any.
+func (s *shareStore) get(id string) (evaluateRequest, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	share, ok := s.shares[id]
+	return share, ok
+}
+
+// newShareID returns a random ID for a shared calculation.
+func newShareID() (string, error) {
+	id := make([]byte, shareIDLength)
+	for i := range id {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(shareIDAlphabet))))
+		if err != nil {
+			return "", err
+		}
+		id[i] = shareIDAlphabet[n.Int64()]
+	}
+	return string(id), nil
+}
+
+// shareResponse answers a POST to /share with the ID of the shared
+// calculation and the path of the page that opens it, or the error that
+// kept it from being shared.
+type shareResponse struct {
+	ID    string       `json:"id,omitempty"`
+	URL   string       `json:"url,omitempty"`
+	Error *serverError `json:"error,omitempty"`
+}
+
+// handleShare stores the expression and variables of a /share request, which
+// has the body of an /evaluate request, and returns the HTTP status and body
+// of the answer.
+func handleShare(r *http.Request, shares *shareStore) (int, shareResponse) {
+	failure := func(status int, kind, message string) (int, shareResponse) {
+		return status, shareResponse{Error: &serverError{Kind: kind, Message: message}}
+	}
+	if r.Method != http.MethodPost {
+		return failure(http.StatusMethodNotAllowed, "request", "use POST with a JSON body such as {\"expression\": \"2 + 3\"}")
+	}
+	var request evaluateRequest
+	decoder := json.NewDecoder(http.MaxBytesReader(nil, r.Body, maxRequestBytes))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&request); err != nil {
+		return failure(http.StatusBadRequest, "request", fmt.Sprintf("the body must be a JSON object with an expression and optional numeric variables: %s", err))
+	}
+	if strings.TrimSpace(request.Expression) == "" {
+		return failure(http.StatusBadRequest, "request", "the expression is empty")
+	}
+	if len(request.Expression) > calc.MaxInputLength {
+		return failure(http.StatusBadRequest, "request", fmt.Sprintf("the expression is longer than %d bytes", calc.MaxInputLength))
+	}
+	for name := range request.Variables {
+		if !variableNameRegex.MatchString(name) {
+			return failure(http.StatusBadRequest, "variable", fmt.Sprintf("'%s' is not a variable name", name))
+		}
+	}
+	id, err := shares.add(request)
+	if err != nil {
+		return failure(http.StatusInsufficientStorage, "request", err.Error())
+	}
+	return http.StatusCreated, shareResponse{ID: id, URL: sharePath + id}
+}
+
+// sharePath is the path of the page of a shared calculation, before its ID.
+const sharePath = "/s/"
+
+// pageData fills in pageTemplate: the shared calculation the page opens with,
+// if any, and the error of a share that was not found.
+type pageData struct {
+	Shared *evaluateRequest
+	Error  string
+}
+
+// pageTemplate is the web page of the server, which evaluates expressions
+// with /evaluate and shares them with /share.
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Calculator</title>
+<style>body{font-family:sans-serif;max-width:40em;margin:2em auto}input,textarea{width:100%;font-family:monospace;font-size:1.1em}pre{background:#f4f4f4;padding:8px}.error{color:#b00}</style>
+</head>
+<body>
+<h1>Calculator</h1>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<p><label>Expression<br><input id="expression" autofocus></label></p>
+<p><label>Variables, one name = value per line<br><textarea id="variables" rows="3"></textarea></label></p>
+<p><button id="evaluate">Evaluate</button> <button id="share">Share</button></p>
+<pre id="result"></pre>
+<p id="link"></p>
+<script>
+const shared = {{.Shared}};
+const $ = id => document.getElementById(id);
+function request() {
+  const variables = {};
+  for (const line of $("variables").value.split("\n")) {
+    const [name, value] = line.split("=").map(s => s.trim());
+    if (name) variables[name] = Number(value);
+  }
+  return {expression: $("expression").value, variables};
+}
+async function post(path) {
+  const response = await fetch(path, {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify(request())});
+  return response.json();
+}
+async function evaluate() {
+  const answer = await post("/evaluate");
+  $("result").className = answer.error ? "error" : "";
+  $("result").textContent = answer.error ? answer.error.kind + " error: " + answer.error.message : answer.result;
+}
+async function share() {
+  const answer = await post("/share");
+  $("link").textContent = answer.error ? answer.error.message : location.origin + answer.url;
+}
+$("evaluate").onclick = evaluate;
+$("share").onclick = share;
+$("expression").onkeydown = e => { if (e.key === "Enter") evaluate(); };
+if (shared) {
+  $("expression").value = shared.expression;
+  $("variables").value = Object.entries(shared.variables || {}).map(([name, value]) => name + " = " + value).join("\n");
+  evaluate();
+}
+</script>
+</body>
+</html>
+`))
+
+// servePage writes the web page, opened with the calculation shared under
+// the ID in the path of a GET to /s/{id}.
+func servePage(w http.ResponseWriter, r *http.Request, shares *shareStore) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "use GET", http.StatusMethodNotAllowed)
+		return
+	}
+	var data pageData
+	status := http.StatusOK
+	if id := strings.TrimPrefix(r.URL.Path, sharePath); id != r.URL.Path {
+		if share, ok := shares.get(id); ok {
+			data.Shared = &share
+		} else {
+			status, data.Error = http.StatusNotFound, fmt.Sprintf("There is no shared calculation %s.", id)
+		}
+	} else if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	pageTemplate.Execute(w, data)
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// tapeLines lays out the numeric results of the session like the paper tape of
+// an adding machine: each calculation with its amount and the running total,
+// followed by the total.
+func (c *Calculator) tapeLines() []string {
+	var labels, amounts, marks, totals []string
+	total := 0.0
+	for _, entry := range c.entries {
+		if entry.err != nil || !entry.result.Numeric {
+			continue
+		}
+		value := entry.result.Value
+		total += value
+		mark := "+"
+		if value < 0 {
+			mark = "-"
+		}
+		labels = append(labels, entry.input)
+		amounts = append(amounts, c.engine.Format(math.Abs(value)))
+		marks = append(marks, mark)
+		totals = append(totals, c.engine.Format(total))
+	}
+	if len(labels) == 0 {
+		return []string{"The tape is empty."}
+	}
+
+	labels = append(labels, "Total")
+	totalText := c.engine.Format(total)
+	labelWidth, amountWidth, totalWidth := columnWidth(labels), columnWidth(amounts), columnWidth(append(totals, totalText))
+	var lines []string
+	for i := range amounts {
+		lines = append(lines, fmt.Sprintf("%-*s  %*s %s  %*s", labelWidth, labels[i], amountWidth, amounts[i], marks[i], totalWidth, totals[i]))
+	}
+	lines = append(lines, strings.Repeat("-", labelWidth+amountWidth+totalWidth+6))
+	lines = append(lines, fmt.Sprintf("%-*s  %*s    %*s *", labelWidth, "Total", amountWidth, "", totalWidth, totalText))
+	return lines
+}
+
+func columnWidth(values []string) int {
+	width := 0
+	for _, value := range values {
+		if n := len([]rune(value)); n > width {
+			width = n
+		}
+	}
+	return width
+}
+
+func (c *Calculator) handleTapeCommand(args []string) {
+	lines := c.tapeLines()
+	switch {
+	case len(args) == 0:
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+	case len(args) == 2 && strings.ToLower(args[0]) == "save":
+		var err error
+		if strings.ToLower(filepath.Ext(args[1])) == ".pdf" {
+			err = writePDF(args[1], "Calculator Tape", lines)
+		} else {
+			err = os.WriteFile(args[1], []byte(strings.Join(lines, "\n")+"\n"), 0644)
+		}
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		fmt.Println("Saved the tape to", args[1])
+	default:
+		fmt.Println("Error: use 'tape' or 'tape save <file>.txt|.pdf'")
+	}
+}
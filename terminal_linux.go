@@ -0,0 +1,13 @@
+//go:build linux
+// +build linux
+
+package main
+
+import "syscall"
+
+// getTermios and setTermios are the ioctl requests that read and set the
+// terminal settings.
+const (
+	getTermios = syscall.TCGETS
+	setTermios = syscall.TCSETS
+)
@@ -0,0 +1,12 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package main
+
+import "errors"
+
+// makeRaw reports that line editing is not available on this system, where
+// the calculator reads plain lines.
+func makeRaw(fd uintptr) (func(), error) {
+	return nil, errors.New("line editing is not supported on this system")
+}
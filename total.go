@@ -0,0 +1,121 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// untaggedCategory groups the entries of the running total without a #tag.
+const untaggedCategory = "untagged"
+
+// runningTotal is the adding machine accumulator of total mode.
+type runningTotal struct {
+	enabled    bool
+	sum        float64
+	count      int
+	categories map[string]float64
+}
+
+// add adds value to the total and to each of the categories it is tagged with.
+func (t *runningTotal) add(value float64, tags []string) {
+	t.sum += value
+	t.count++
+	if t.categories == nil {
+		t.categories = map[string]float64{}
+	}
+	if len(tags) == 0 {
+		tags = []string{untaggedCategory}
+	}
+	for _, tag := range tags {
+		t.categories[tag] += value
+	}
+}
+
+func (t *runningTotal) clear() {
+	t.sum = 0
+	t.count = 0
+	t.categories = nil
+}
+
+// splitTags removes the trailing #category tags from input, as in
+// "12.50 #food #trip", and returns them in lower case.
+func splitTags(input string) (string, []string) {
+	fields := strings.Fields(input)
+	end := len(fields)
+	for end > 0 && len(fields[end-1]) > 1 && strings.HasPrefix(fields[end-1], "#") {
+		end--
+	}
+	if end == len(fields) {
+		return input, nil
+	}
+
+	var tags []string
+	for _, field := range fields[end:] {
+		tags = append(tags, strings.ToLower(strings.TrimPrefix(field, "#")))
+	}
+	sort.Strings(tags)
+	rest := input
+	for i := len(fields) - 1; i >= end; i-- {
+		rest = strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(rest), fields[i]))
+	}
+	return rest, tags
+}
+
+func (c *Calculator) handleTotalCommand(fields []string) bool {
+	switch fields[0] {
+	case totalCommand:
+		if len(fields) > 2 {
+			return false
+		}
+		if len(fields) == 2 {
+			switch fields[1] {
+			case "on":
+				c.total.enabled = true
+			case "off":
+				c.total.enabled = false
+			default:
+				fmt.Println("Error: use 'total on' or 'total off'")
+				return true
+			}
+		}
+		state := "off"
+		if c.total.enabled {
+			state = "on"
+		}
+		fmt.Printf("Running total %s: %s (%s)\n", state, c.engine.Format(c.total.sum), pluralize(c.total.count, "value"))
+	case subtotalCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		c.printSubtotal()
+	case clearCommand:
+		if len(fields) != 1 {
+			return false
+		}
+		c.total.clear()
+		fmt.Println("Total cleared.")
+	}
+	return true
+}
+
+// printSubtotal shows the running total broken down by category without
+// clearing it.
+func (c *Calculator) printSubtotal() {
+	var names []string
+	for name := range c.total.categories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) > 1 || len(names) == 1 && names[0] != untaggedCategory {
+		amounts := make([]string, len(names))
+		for i, name := range names {
+			amounts[i] = c.engine.Format(c.total.categories[name])
+		}
+		nameWidth, amountWidth := columnWidth(names), columnWidth(amounts)
+		for i, name := range names {
+			fmt.Printf("  %-*s  %*s\n", nameWidth, name, amountWidth, amounts[i])
+		}
+	}
+	fmt.Printf("Subtotal: %s (%s)\n", c.engine.Format(c.total.sum), pluralize(c.total.count, "value"))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/XeinTDM/Go-Calculator/calc"
+)
+
+// defaultWorkspace is the workspace the calculator starts in.
+const defaultWorkspace = "main"
+
+var workspaceNameRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// workspace is an independent set of variables, functions, settings, and
+// history. Its calculator can read the variables of the other workspaces as
+// name::variable.
+type workspace struct {
+	engine  *calc.Calculator
+	entries []sessionEntry
+	pins    []string
+	total   runningTotal
+}
+
+func (c *Calculator) addWorkspace(name string) {
+	engine := calc.New()
+	engine.Workspaces = c.engines
+	if c.workspace != nil {
+		copySettings(engine, c.engine)
+	}
+	c.engines[name] = engine
+	c.workspaces[name] = &workspace{engine: engine}
+}
+
+// copySettings gives engine the display settings and exchange rates of from.
+func copySettings(engine, from *calc.Calculator) {
+	engine.Decimals = from.Decimals
+	engine.Notation, engine.TrimZeros, engine.Grouping = from.Notation, from.TrimZeros, from.Grouping
+	engine.Base, engine.Rates = from.Base, from.Rates
+}
+
+func (c *Calculator) switchWorkspace(name string) {
+	c.workspace = c.workspaces[name]
+	c.workspaceName = name
+}
+
+func (c *Calculator) handleWorkspaceCommand(args []string) {
+	switch {
+	case len(args) == 0:
+	case len(args) == 2 && strings.ToLower(args[0]) == "create":
+		name := args[1]
+		if !workspaceNameRegex.MatchString(name) {
+			fmt.Println("Error: workspace names start with a letter or underscore and contain only letters, digits, and underscores")
+			return
+		}
+		if _, ok := c.workspaces[name]; ok {
+			fmt.Printf("Error: workspace '%s' already exists\n", name)
+			return
+		}
+		c.addWorkspace(name)
+		c.switchWorkspace(name)
+	case len(args) == 2 && strings.ToLower(args[0]) == "switch":
+		if _, ok := c.workspaces[args[1]]; !ok {
+			fmt.Printf("Error: no workspace named '%s', use 'workspace create %s' to add it\n", args[1], args[1])
+			return
+		}
+		c.switchWorkspace(args[1])
+	default:
+		fmt.Println("Error: use 'workspace', 'workspace create <name>', or 'workspace switch <name>'")
+		return
+	}
+
+	var names []string
+	for name := range c.workspaces {
+		if name == c.workspaceName {
+			name = "*" + name
+		}
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return strings.TrimPrefix(names[i], "*") < strings.TrimPrefix(names[j], "*")
+	})
+	fmt.Println("Workspace:", c.workspaceName)
+	fmt.Println("Workspaces:", strings.Join(names, ", "))
+}